@@ -0,0 +1,37 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package bouncetest provides a bounce instance for use in end-to-end HTTP
+tests, similar to the net/http/httptest package.
+
+NewServer starts a Server listening on a system-chosen port on the local
+loopback interface, which is shut down once the test completes. Client requests sent to the Server are captured and made
+available via the Requests channel and the Captured method. The responses
+returned for each route may be set via WithResponse or changed while the
+Server is running via SetResponse:
+
+	srv := bouncetest.NewServer(t,
+		bouncetest.WithResponse("echo-json", bouncetest.Response{
+			Status: http.StatusAccepted,
+		}),
+	)
+
+	// Submit a payload to srv.URL + "/api/v1/echo/json" using the code
+	// under test.
+
+	clientRequest := <-srv.Requests
+	fmt.Println(clientRequest.HTTPMethod, clientRequest.Body)
+
+Settings not covered by the options provided by this package may be
+specified using the same command-line flags accepted by bounce via WithArgs.
+
+This package is the supported way to embed bounce in other modules. The
+handlers and notifications manager used by the Server are internal to this
+module.
+*/
+package bouncetest
@@ -0,0 +1,298 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package bouncetest
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/notify"
+	"github.com/atc0005/bounce/internal/routes"
+	"github.com/atc0005/bounce/internal/server"
+)
+
+// RequestsQueueDepth is the number of captured client requests buffered by
+// the Requests channel. Once the buffer is full, further client requests are
+// not sent on the channel, but remain available via Captured.
+const RequestsQueueDepth int = 100
+
+// Response controls the response returned to clients for a route. Zero
+// values use the same defaults as response rules specified via the
+// configuration file.
+type Response struct {
+
+	// Headers is a collection of headers added to the response.
+	Headers map[string]string
+
+	// ContentType is the Content-Type of the response. If not specified,
+	// text/plain is used.
+	ContentType string
+
+	// Body is a static response body. If not specified, the client request
+	// details are echoed back to the client.
+	Body string
+
+	// Status is the HTTP status code returned for successfully processed
+	// requests. If not specified, 200 is used.
+	Status int
+}
+
+// rule converts the Response to the equivalent response rule.
+func (resp Response) rule() config.ResponseRule {
+	return config.ResponseRule{
+		Headers:     resp.Headers,
+		ContentType: resp.ContentType,
+		Body:        resp.Body,
+		Status:      resp.Status,
+	}
+}
+
+// Option is a functional option used to configure a Server.
+type Option func(*options)
+
+// options are the settings used to create a Server.
+type options struct {
+	args      []string
+	responses map[string]Response
+	output    io.Writer
+	tls       bool
+	accessLog bool
+}
+
+// WithArgs specifies command-line flags (e.g., "--config-file",
+// "bounce.json") used to configure the Server. The listening address
+// settings are ignored.
+func WithArgs(args ...string) Option {
+	return func(o *options) {
+		o.args = append(o.args, args...)
+	}
+}
+
+// WithResponse sets the response returned for the route with the given name
+// (e.g., "echo" or "echo-json").
+func WithResponse(routeName string, response Response) Option {
+	return func(o *options) {
+		o.responses[routeName] = response
+	}
+}
+
+// WithOutput specifies where captured client request details are written.
+// By default, these details are discarded.
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.output = w
+	}
+}
+
+// WithTLS serves requests using TLS with the self-signed certificate
+// provided by the net/http/httptest package. Use the Client method to
+// obtain a client configured to trust this certificate.
+func WithTLS() Option {
+	return func(o *options) {
+		o.tls = true
+	}
+}
+
+// WithAccessLog emits an access log entry for each request via the apex/log
+// package, as bounce does. By default, no access log entries are emitted so
+// that test output is not cluttered.
+func WithAccessLog() Option {
+	return func(o *options) {
+		o.accessLog = true
+	}
+}
+
+// Server is a bounce instance listening on a system-chosen port on the local
+// loopback interface. The embedded httptest.Server provides the URL and a
+// configured Client.
+type Server struct {
+	*httptest.Server
+
+	// Requests receives each captured client request, in the order received.
+	Requests <-chan capture.Request
+
+	requests   chan capture.Request
+	captured   []capture.Request
+	responses  *server.Overrides[config.ResponseRule]
+	cancel     context.CancelFunc
+	notifyDone chan struct{}
+	closeOnce  sync.Once
+	mu         sync.Mutex
+}
+
+// NewServer starts and returns a new Server which is shut down once the
+// given test (or benchmark) and its subtests complete. Close may be called
+// to shut it down earlier. The test fails immediately if the given options
+// do not specify a valid configuration.
+func NewServer(tb testing.TB, opts ...Option) *Server {
+
+	tb.Helper()
+
+	o := options{
+		responses: make(map[string]Response),
+		output:    io.Discard,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg, err := config.FromArgs(o.args)
+	if err != nil {
+		tb.Fatalf("bouncetest: invalid configuration: %v", err)
+	}
+
+	// The admin API (if enabled) is served alongside all other routes.
+	cfg.AdminListen = nil
+
+	ipResolver, err := clientip.NewResolver(cfg.TrustedProxies, cfg.ForwardedHeader)
+	if err != nil {
+		tb.Fatalf("bouncetest: failed to initialize client IP resolver: %v", err)
+	}
+
+	tmpls, err := server.LoadTemplates(cfg)
+	if err != nil {
+		tb.Fatalf("bouncetest: failed to load templates: %v", err)
+	}
+
+	requests := make(chan capture.Request, RequestsQueueDepth)
+	s := Server{
+		Requests:   requests,
+		requests:   requests,
+		responses:  server.NewOverrides[config.ResponseRule](),
+		notifyDone: make(chan struct{}, 1),
+	}
+	for routeName, response := range o.responses {
+		s.SetResponse(routeName, response)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	// The notifications manager drains the work queue and records the
+	// outcome of notifications, if any are enabled.
+	reqHistory := capture.NewStore(cfg.HistorySize, cfg.APIKeyHeaders()...)
+	notifyState := notify.NewStatus()
+	notifyWorkQueue := make(chan capture.Request, config.NotifyMgrQueueDepth)
+	go notify.StartManager(ctx, cfg, nil, nil, reqHistory, notifyState, notifyWorkQueue, s.notifyDone)
+
+	deps := server.Deps{
+		Templates:         tmpls,
+		Output:            o.output,
+		IPResolver:        ipResolver,
+		History:           reqHistory,
+		NotifyWorkQueue:   notifyWorkQueue,
+		FaultOverrides:    server.NewOverrides[config.FaultInjection](),
+		ResponseOverrides: s.responses,
+		NotifyStatus:      notifyState,
+		Expectations:      server.NewExpectationStore(reqHistory.RedactHeaders),
+		Feed:              capture.NewFeed(),
+		OnCapture:         s.record,
+	}
+
+	mux, _, err := server.NewRouter(ctx, cfg, deps)
+	if err != nil {
+		cancel()
+		<-s.notifyDone
+		tb.Fatalf("bouncetest: failed to setup routes: %v", err)
+	}
+
+	globalMiddleware := routes.NewChain(
+		routes.RequestID(),
+		routes.ConnectionRequests(),
+	)
+	if o.accessLog {
+		globalMiddleware = globalMiddleware.Append(routes.AccessLog(ipResolver.GetIP))
+	}
+	globalMiddleware = globalMiddleware.Append(routes.Recoverer())
+	if cfg.Compress {
+		globalMiddleware = globalMiddleware.Append(routes.Compress(gzip.DefaultCompression))
+	}
+
+	s.Server = httptest.NewUnstartedServer(globalMiddleware.Then(mux))
+	s.Server.Config.ConnContext = routes.ConnContext
+	if o.tls {
+		s.Server.StartTLS()
+	} else {
+		s.Server.Start()
+	}
+	tb.Cleanup(s.Close)
+
+	return &s
+}
+
+// record retains the captured client request and sends it on the Requests
+// channel if there is room.
+func (s *Server) record(clientRequest capture.Request) {
+	s.mu.Lock()
+	s.captured = append(s.captured, clientRequest)
+	s.mu.Unlock()
+
+	select {
+	case s.requests <- clientRequest:
+	default:
+	}
+}
+
+// Captured returns a copy of all client requests captured so far, in the
+// order received.
+func (s *Server) Captured() []capture.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	captured := make([]capture.Request, len(s.captured))
+	copy(captured, s.captured)
+
+	return captured
+}
+
+// Reset discards all client requests captured so far, including those not
+// yet received from the Requests channel.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.captured = nil
+	for {
+		select {
+		case <-s.requests:
+		default:
+			return
+		}
+	}
+}
+
+// SetResponse sets the response returned for the route with the given name
+// (e.g., "echo" or "echo-json"), replacing any response set previously.
+func (s *Server) SetResponse(routeName string, response Response) {
+	s.responses.Set(routeName, response.rule())
+}
+
+// ClearResponse restores the default response for the route with the given
+// name.
+func (s *Server) ClearResponse(routeName string) {
+	s.responses.Remove(routeName)
+}
+
+// Close shuts down the Server and blocks until all outstanding requests on
+// the Server have completed.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		// Cancelling the context first ends long-lived requests (e.g., SSE
+		// streams) which would otherwise block the shutdown.
+		s.cancel()
+		s.Server.Close()
+		<-s.notifyDone
+	})
+}
@@ -0,0 +1,194 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package bouncetest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atc0005/bounce/bouncetest"
+)
+
+// get sends a GET request using the given client and returns the response
+// status and body.
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: failed to read response body: %v", url, err)
+	}
+
+	return resp.StatusCode, string(body)
+}
+
+func TestServerCapturesRequests(t *testing.T) {
+
+	srv := bouncetest.NewServer(t)
+
+	if !strings.HasPrefix(srv.URL, "http://127.0.0.1:") {
+		t.Fatalf("URL = %q, want a loopback http URL", srv.URL)
+	}
+
+	resp, err := srv.Client().Post(srv.URL+"/api/v1/echo/json", "application/json", strings.NewReader(`{"alert": "disk full"}`))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	select {
+	case clientRequest := <-srv.Requests:
+		if clientRequest.HTTPMethod != http.MethodPost {
+			t.Errorf("HTTPMethod = %q, want %q", clientRequest.HTTPMethod, http.MethodPost)
+		}
+		if clientRequest.EndpointPath != "/api/v1/echo/json" {
+			t.Errorf("EndpointPath = %q, want %q", clientRequest.EndpointPath, "/api/v1/echo/json")
+		}
+		if clientRequest.Body != `{"alert": "disk full"}` {
+			t.Errorf("Body = %q", clientRequest.Body)
+		}
+		if clientRequest.RequestID != resp.Header.Get("X-Request-Id") {
+			t.Errorf("RequestID = %q, want %q", clientRequest.RequestID, resp.Header.Get("X-Request-Id"))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("captured client request not received")
+	}
+
+	if captured := srv.Captured(); len(captured) != 1 {
+		t.Fatalf("Captured() returned %d client requests, want 1", len(captured))
+	}
+
+	srv.Reset()
+	if captured := srv.Captured(); len(captured) != 0 {
+		t.Errorf("Captured() returned %d client requests after Reset, want 0", len(captured))
+	}
+}
+
+func TestServerResponses(t *testing.T) {
+
+	srv := bouncetest.NewServer(t,
+		bouncetest.WithResponse("echo", bouncetest.Response{
+			Status:      http.StatusAccepted,
+			ContentType: "application/json",
+			Body:        `{"ok": true}`,
+		}),
+	)
+	client := srv.Client()
+	url := srv.URL + "/api/v1/echo"
+
+	if status, body := get(t, client, url); status != http.StatusAccepted || body != `{"ok": true}` {
+		t.Errorf("WithResponse: got %d %q, want %d %q", status, body, http.StatusAccepted, `{"ok": true}`)
+	}
+
+	srv.SetResponse("echo", bouncetest.Response{
+		Status: http.StatusServiceUnavailable,
+		Body:   "down for maintenance",
+	})
+	if status, body := get(t, client, url); status != http.StatusServiceUnavailable || body != "down for maintenance" {
+		t.Errorf("SetResponse: got %d %q, want %d %q", status, body, http.StatusServiceUnavailable, "down for maintenance")
+	}
+
+	srv.ClearResponse("echo")
+	if status, body := get(t, client, url); status != http.StatusOK || !strings.Contains(body, "/api/v1/echo") {
+		t.Errorf("ClearResponse: got %d %q, want %d with the echoed request", status, body, http.StatusOK)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		opts       []bouncetest.Option
+		scheme     string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "defaults",
+			scheme:     "http",
+			path:       "/api/v1/history",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "WithArgs",
+			opts:       []bouncetest.Option{bouncetest.WithArgs("--history-size", "5")},
+			scheme:     "http",
+			path:       "/api/v1/history",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "WithTLS",
+			opts:       []bouncetest.Option{bouncetest.WithTLS()},
+			scheme:     "https",
+			path:       "/api/v1/echo",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			srv := bouncetest.NewServer(t, tt.opts...)
+
+			if !strings.HasPrefix(srv.URL, tt.scheme+"://") {
+				t.Errorf("URL = %q, want scheme %s", srv.URL, tt.scheme)
+			}
+
+			if status, _ := get(t, srv.Client(), srv.URL+tt.path); status != tt.wantStatus {
+				t.Errorf("GET %s: status = %d, want %d", tt.path, status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// fatalRecorder is a testing.TB which records the message passed to Fatalf.
+type fatalRecorder struct {
+	testing.TB
+	msg string
+}
+
+func (fr *fatalRecorder) Helper() {}
+
+func (fr *fatalRecorder) Cleanup(func()) {}
+
+func (fr *fatalRecorder) Fatalf(format string, args ...any) {
+	fr.msg = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func TestNewServerInvalidConfiguration(t *testing.T) {
+
+	fr := &fatalRecorder{TB: t}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bouncetest.NewServer(fr, bouncetest.WithArgs("--sse-interval", "1"))
+		t.Error("NewServer returned despite an invalid configuration")
+	}()
+	<-done
+
+	if !strings.Contains(fr.msg, "invalid configuration") {
+		t.Errorf("Fatalf message = %q, want an invalid configuration error", fr.msg)
+	}
+}
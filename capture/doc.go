@@ -0,0 +1,16 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package capture provides the details recorded for each client request
+received by bounce along with Store, which retains the most recent client
+requests in memory.
+
+Captured client requests are used to generate responses, notifications and
+the request history.
+*/
+package capture
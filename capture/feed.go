@@ -0,0 +1,73 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package capture
+
+import "sync"
+
+// Feed broadcasts captured client requests to subscribers (e.g., clients
+// streaming captured requests as they are received). Feed is safe for
+// concurrent use.
+type Feed struct {
+	subscribers map[chan Request]struct{}
+	mu          sync.Mutex
+}
+
+// NewFeed creates a new Feed without subscribers.
+func NewFeed() *Feed {
+	return &Feed{
+		subscribers: make(map[chan Request]struct{}),
+	}
+}
+
+// Subscribe returns a channel which receives each client request published
+// after subscribing, buffering up to the given number of client requests,
+// along with a function which ends the subscription. Client requests are
+// not sent to subscribers whose buffer is full.
+func (f *Feed) Subscribe(buffer int) (<-chan Request, func()) {
+
+	ch := make(chan Request, buffer)
+
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, ch)
+			f.mu.Unlock()
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish sends the client request to all current subscribers, indicating
+// the number of subscribers which did not receive it as their buffer was
+// full. Publishing to a nil Feed does nothing.
+func (f *Feed) Publish(clientRequest Request) int {
+
+	if f == nil {
+		return 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var dropped int
+	for ch := range f.subscribers {
+		select {
+		case ch <- clientRequest:
+		default:
+			dropped++
+		}
+	}
+
+	return dropped
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package capture
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Filter selects captured client requests. The zero value selects all
+// client requests.
+type Filter struct {
+
+	// Since selects client requests received at or after the given time.
+	Since time.Time
+
+	// Method selects client requests using the given HTTP method. The
+	// comparison is case-insensitive.
+	Method string
+
+	// Path is a pattern matched against the path requested by the client
+	// using the path.Match syntax (e.g., /api/v1/echo/*).
+	Path string
+
+	// Limit is the maximum number of client requests selected, keeping the
+	// most recent. A value of 0 selects all matching client requests.
+	Limit int
+}
+
+// Validate checks the filter settings.
+func (f Filter) Validate() error {
+
+	if f.Path != "" {
+		if _, err := path.Match(f.Path, "/"); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", f.Path, err)
+		}
+	}
+
+	if f.Limit < 0 {
+		return fmt.Errorf("invalid limit %d; value must not be negative", f.Limit)
+	}
+
+	return nil
+}
+
+// Match indicates whether the filter selects the given client request,
+// ignoring Limit.
+func (f Filter) Match(clientRequest Request) bool {
+
+	if !f.Since.IsZero() && clientRequest.ReceivedAt.Before(f.Since) {
+		return false
+	}
+
+	if f.Method != "" && !strings.EqualFold(f.Method, clientRequest.HTTPMethod) {
+		return false
+	}
+
+	if f.Path != "" {
+		if ok, _ := path.Match(f.Path, clientRequest.EndpointPath); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply returns the client requests selected by the filter, oldest first.
+func (f Filter) Apply(requests []Request) []Request {
+
+	selected := make([]Request, 0, len(requests))
+	for _, clientRequest := range requests {
+		if f.Match(clientRequest) {
+			selected = append(selected, clientRequest)
+		}
+	}
+
+	if f.Limit > 0 && len(selected) > f.Limit {
+		selected = selected[len(selected)-f.Limit:]
+	}
+
+	return selected
+}
+
+// Requests returns the client request recorded in each of the given entries.
+func Requests(entries []Entry) []Request {
+
+	requests := make([]Request, 0, len(entries))
+	for _, entry := range entries {
+		requests = append(requests, entry.Request)
+	}
+
+	return requests
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package capture
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// Notification outcomes recorded in the Store for each notifier.
+const (
+	NotifyOutcomePending     string = "pending"
+	NotifyOutcomeSuccess     string = "success"
+	NotifyOutcomeFailure     string = "failure"
+	NotifyOutcomeRateLimited string = "rate limited"
+	NotifyOutcomePaused      string = "paused"
+)
+
+// Request is used to bundle various client request details for processing by
+// templates or notification functions.
+type Request struct {
+
+	// RequestID is the ID assigned to the client request, either provided
+	// by the client or generated. The same ID is returned to the client,
+	// recorded in the access log and included in notifications.
+	RequestID string `json:"request_id"`
+
+	// CaptureID is a unique ID generated for each captured client request.
+	// Unlike RequestID, which may be provided (and repeated) by clients,
+	// CaptureID identifies the client request in the Store.
+	CaptureID string `json:"capture_id"`
+
+	// ReceivedAt is the time the client request was received.
+	ReceivedAt time.Time `json:"received_at"`
+
+	// RequestURL is the absolute URL requested by the client, reconstructed
+	// from the Host header and request URI.
+	RequestURL string `json:"request_url"`
+
+	// Protocol is the HTTP protocol version used for the request (e.g.,
+	// HTTP/1.1).
+	Protocol string `json:"protocol"`
+
+	// ConnectionReused indicates whether the request was received on a
+	// connection used for earlier requests, either via HTTP/1.x keep-alive
+	// or as an additional HTTP/2 stream.
+	ConnectionReused bool `json:"connection_reused"`
+
+	// TLS is the TLS connection state for requests received via an https
+	// listener. This field is nil for requests received without TLS.
+	TLS *TLSDetails `json:"tls,omitempty"`
+
+	Datestamp          string      `json:"datestamp"`
+	EndpointPath       string      `json:"endpoint_path"`
+	HTTPMethod         string      `json:"http_method"`
+	ClientIPAddress    string      `json:"client_ip_address"`
+	ClientIPSource     string      `json:"client_ip_source"`
+	Headers            http.Header `json:"headers"`
+	Body               string      `json:"body"`
+	BodyError          string      `json:"body_error,omitempty"`
+	FormattedBody      string      `json:"formatted_body,omitempty"`
+	FormattedBodyError string      `json:"formatted_body_error,omitempty"`
+	RequestError       string      `json:"request_error,omitempty"`
+	ContentTypeError   string      `json:"content_type_error,omitempty"`
+
+	// Fault records the faults injected for the request, if any.
+	Fault *FaultDetails `json:"fault,omitempty"`
+
+	// ProxyChain is the full list of addresses recorded for the request,
+	// starting with the originating client (as claimed by any forwarding
+	// headers) and ending with the immediate peer.
+	ProxyChain []string `json:"proxy_chain,omitempty"`
+
+	// PathParams is a collection of values matched by wildcards in the route
+	// pattern, keyed by wildcard name.
+	PathParams map[string]string `json:"path_params,omitempty"`
+
+	// Notifiers is the list of notifiers used for this request. A nil value
+	// indicates that all enabled notifiers are used.
+	Notifiers []string `json:"notifiers,omitempty"`
+
+	// RateLimitSummary is set only for summary notifications generated by
+	// the notification manager to report notifications suppressed by the
+	// notification rate limit.
+	RateLimitSummary *RateLimitSummary `json:"-"`
+}
+
+// TLSDetails is the TLS connection state recorded for a client request.
+type TLSDetails struct {
+
+	// Version is the TLS version used by the connection (e.g., TLS 1.3).
+	Version string `json:"version"`
+
+	// CipherSuite is the name of the cipher suite used by the connection.
+	CipherSuite string `json:"cipher_suite"`
+
+	// ALPN is the application protocol negotiated via ALPN (e.g., h2). This
+	// field is empty if no protocol was negotiated.
+	ALPN string `json:"alpn,omitempty"`
+
+	// ServerName is the server name requested by the client via SNI, if
+	// any.
+	ServerName string `json:"server_name,omitempty"`
+
+	// Resumed indicates whether the TLS session was resumed from an earlier
+	// connection.
+	Resumed bool `json:"resumed"`
+}
+
+// NewTLSDetails records the TLS connection state for a client request. nil
+// is returned for requests received without TLS.
+func NewTLSDetails(state *tls.ConnectionState) *TLSDetails {
+	if state == nil {
+		return nil
+	}
+
+	return &TLSDetails{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+		Resumed:     state.DidResume,
+	}
+}
+
+// NotifierSelected indicates whether the named notifier should be used for
+// the client request. All notifiers are used unless the route limits them.
+func (crd Request) NotifierSelected(name string) bool {
+	if crd.Notifiers == nil {
+		return true
+	}
+
+	for _, notifier := range crd.Notifiers {
+		if notifier == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NotifyTargets returns the names of the given enabled notifiers which are
+// selected for the client request.
+func (crd Request) NotifyTargets(enabledNotifiers []string) []string {
+	var targets []string
+	for _, notifier := range enabledNotifiers {
+		if crd.NotifierSelected(notifier) {
+			targets = append(targets, notifier)
+		}
+	}
+
+	return targets
+}
+
+// FaultDetails records the faults injected for a client request.
+type FaultDetails struct {
+
+	// Type is the type of fault injected, if any. This is empty if only
+	// latency was injected.
+	Type string `json:"type,omitempty"`
+
+	// LatencyMs is the number of milliseconds of latency injected before
+	// the request was handled.
+	LatencyMs int64 `json:"latency_ms,omitempty"`
+
+	// Status is the status code of the injected error response.
+	Status int `json:"status,omitempty"`
+}
+
+// RateLimitSummary records details for notifications suppressed by the
+// notification rate limit so that they can be reported using a single
+// summary notification instead of one notification per client request.
+type RateLimitSummary struct {
+
+	// First is when the first notification was suppressed.
+	First time.Time
+
+	// Last is when the most recent notification was suppressed.
+	Last time.Time
+
+	// Endpoints is the number of suppressed notifications per endpoint path.
+	Endpoints map[string]int
+
+	// Clients is the number of suppressed notifications per client IP
+	// Address.
+	Clients map[string]int
+
+	// Count is the total number of suppressed notifications.
+	Count int
+}
+
+// NewRateLimitSummary creates a new, empty RateLimitSummary.
+func NewRateLimitSummary() *RateLimitSummary {
+	now := time.Now()
+
+	return &RateLimitSummary{
+		First:     now,
+		Last:      now,
+		Endpoints: make(map[string]int),
+		Clients:   make(map[string]int),
+	}
+}
+
+// Record adds the given client request to the summary.
+func (rls *RateLimitSummary) Record(clientRequest Request) {
+	rls.Last = time.Now()
+	rls.Count++
+	rls.Endpoints[clientRequest.EndpointPath]++
+	rls.Clients[clientRequest.ClientIPAddress]++
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package capture
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RedactedHeaderValue replaces the values of credential headers (e.g.,
+// Authorization) in retained client requests.
+const RedactedHeaderValue string = "REDACTED"
+
+// credentialHeaders are the headers which are always redacted from retained
+// client requests.
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// Entry is a captured client request along with the outcome of each
+// notification generated for the request.
+type Entry struct {
+
+	// Notifications is the outcome of the notification for each notifier
+	// used for the request, keyed by notifier name.
+	Notifications map[string]string `json:"notifications"`
+
+	// Request is the captured client request.
+	Request Request `json:"request"`
+
+	// WebSocket summarizes the WebSocket connection established by the
+	// client request, if any.
+	WebSocket *WebSocketSession `json:"websocket,omitempty"`
+}
+
+// Store retains the most recent client requests in memory. Entries are
+// indexed by capture ID so that notification outcomes can be recorded as they
+// become available. Store is safe for concurrent use.
+type Store struct {
+	byID    map[string]*Entry
+	redact  []string
+	entries []*Entry
+	mu      sync.RWMutex
+	size    int
+}
+
+// NewStore creates a new Store which retains up to size entries. A size of 0
+// disables the Store; client requests are not retained. The values of
+// credential headers (e.g., Authorization and Cookie) and any of the given
+// additional headers (e.g., API key headers) are redacted from retained
+// client requests.
+func NewStore(size int, redactHeaders ...string) *Store {
+
+	redact := make([]string, 0, len(credentialHeaders)+len(redactHeaders))
+	for _, name := range append(credentialHeaders, redactHeaders...) {
+		redact = append(redact, http.CanonicalHeaderKey(name))
+	}
+
+	return &Store{
+		byID:    make(map[string]*Entry, size),
+		redact:  redact,
+		entries: make([]*Entry, 0, size),
+		size:    size,
+	}
+}
+
+// Enabled indicates whether client requests are retained.
+func (s *Store) Enabled() bool {
+	return s.size > 0
+}
+
+// Add records the given client request. Each of the given notifiers is
+// recorded with a pending notification outcome. Credential headers are
+// redacted (see Redact). The oldest entry is discarded once the Store is
+// full.
+func (s *Store) Add(clientRequest Request, notifiers []string) {
+
+	if !s.Enabled() {
+		return
+	}
+
+	clientRequest = s.Redact(clientRequest)
+
+	entry := Entry{
+		Request:       clientRequest,
+		Notifications: make(map[string]string, len(notifiers)),
+	}
+	for _, notifier := range notifiers {
+		entry.Notifications[notifier] = NotifyOutcomePending
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) == s.size {
+		oldest := s.entries[0]
+		delete(s.byID, oldest.Request.CaptureID)
+		s.entries = append(s.entries[:0], s.entries[1:]...)
+	}
+
+	s.entries = append(s.entries, &entry)
+	s.byID[clientRequest.CaptureID] = &entry
+}
+
+// Redact returns a copy of the client request with the values of credential
+// headers replaced by RedactedHeaderValue. The headers of the given client
+// request are not modified.
+func (s *Store) Redact(clientRequest Request) Request {
+	clientRequest.Headers = s.RedactHeaders(clientRequest.Headers)
+
+	return clientRequest
+}
+
+// RedactHeaders returns a copy of the given headers with the values of
+// credential headers replaced by RedactedHeaderValue. Header names are
+// expected to be in canonical form.
+func (s *Store) RedactHeaders(headers http.Header) http.Header {
+
+	headers = headers.Clone()
+	for _, name := range s.redact {
+		values, ok := headers[name]
+		if !ok {
+			continue
+		}
+		redacted := make([]string, len(values))
+		for i := range redacted {
+			redacted[i] = RedactedHeaderValue
+		}
+		headers[name] = redacted
+	}
+
+	return headers
+}
+
+// SetNotifyOutcome records the outcome of a notification for the client
+// request with the given capture ID. Outcomes for client requests no longer
+// retained are ignored.
+func (s *Store) SetNotifyOutcome(captureID string, notifier string, outcome string) {
+
+	if !s.Enabled() || captureID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.byID[captureID]; ok {
+		entry.Notifications[notifier] = outcome
+	}
+}
+
+// SetWebSocketSession records the current state of the WebSocket connection
+// established by the client request with the given capture ID. Sessions for
+// client requests no longer retained are ignored.
+func (s *Store) SetWebSocketSession(captureID string, session WebSocketSession) {
+
+	if !s.Enabled() || captureID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.byID[captureID]; ok {
+		entry.WebSocket = &session
+	}
+}
+
+// Clear discards all retained entries, returning the number of entries
+// discarded.
+func (s *Store) Clear() int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := len(s.entries)
+	s.byID = make(map[string]*Entry, s.size)
+	s.entries = make([]*Entry, 0, s.size)
+
+	return removed
+}
+
+// List returns a copy of all retained entries, oldest first.
+func (s *Store) List() []Entry {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry.copy())
+	}
+
+	return entries
+}
+
+// Get returns a copy of the entry for the given capture ID or, failing that,
+// the most recent entry for the given request ID.
+func (s *Store) Get(id string) (Entry, bool) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.byID[id]; ok {
+		return entry.copy(), true
+	}
+
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].Request.RequestID == id {
+			return s.entries[i].copy(), true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// copy returns a copy of the entry which is safe to use without holding the
+// Store lock.
+func (he Entry) copy() Entry {
+	notifications := make(map[string]string, len(he.Notifications))
+	for notifier, outcome := range he.Notifications {
+		notifications[notifier] = outcome
+	}
+	he.Notifications = notifications
+
+	if he.WebSocket != nil {
+		session := *he.WebSocket
+		he.WebSocket = &session
+	}
+
+	return he
+}
+
+// WebSocketSession summarizes a WebSocket connection. The session is
+// recorded in the Store along with the opening handshake.
+type WebSocketSession struct {
+
+	// ConnectedAt is the time the opening handshake completed.
+	ConnectedAt time.Time `json:"connected_at"`
+
+	// ClosedAt is the time the connection was closed. This field is nil
+	// while the connection is open.
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+
+	// CloseCode is the status code of the close frame received from the
+	// client (or sent by the server due to a protocol violation).
+	CloseCode int `json:"close_code,omitempty"`
+
+	// CloseReason is the reason given with CloseCode, if any.
+	CloseReason string `json:"close_reason,omitempty"`
+
+	// Error describes why the connection ended without a close frame, if
+	// applicable.
+	Error string `json:"error,omitempty"`
+
+	// Options are the echo settings used for the connection.
+	Options WebSocketSettings `json:"options"`
+
+	// FramesReceived and FramesSent count all frames, including control
+	// frames and the individual frames of fragmented messages.
+	FramesReceived int64 `json:"frames_received"`
+	FramesSent     int64 `json:"frames_sent"`
+
+	// BytesReceived and BytesSent count the size of data messages.
+	BytesReceived int64 `json:"bytes_received"`
+	BytesSent     int64 `json:"bytes_sent"`
+
+	TextMessagesReceived   int `json:"text_messages_received"`
+	BinaryMessagesReceived int `json:"binary_messages_received"`
+	MessagesSent           int `json:"messages_sent"`
+	PingsSent              int `json:"pings_sent"`
+	PongsReceived          int `json:"pongs_received"`
+}
+
+// WebSocketSettings are the echo settings used for a WebSocket connection,
+// recorded using the same units as the configuration settings and query
+// parameters.
+type WebSocketSettings struct {
+	DelayMilliseconds   int64 `json:"delay_ms"`
+	CloseAfter          int   `json:"close_after"`
+	PingIntervalSeconds int64 `json:"ping_interval_seconds"`
+	MaxMessageSize      int64 `json:"max_message_size"`
+}
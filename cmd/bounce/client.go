@@ -0,0 +1,268 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+)
+
+// Default settings shared by the subcommands which connect to a running
+// instance or another endpoint.
+const (
+	defaultInstanceURL    string = "http://localhost:8000"
+	defaultClientTimeout  int    = 30
+	clientTimeoutFlagHelp string = "Number of seconds allowed for each request, including reading the response body. A value of 0 disables this timeout."
+	insecureFlagHelp      string = "Skip verification of the TLS certificate presented by the server (e.g., a self-signed certificate)."
+	headerFlagHelp        string = "Header (\"Name: value\") added to requests (e.g., credentials required by an access policy). May be repeated."
+)
+
+// historyEndpointPath is the path of the API endpoint used to retrieve
+// captured client requests from a running instance.
+const historyEndpointPath string = "/api/v1/history"
+
+// headerFlag is a flag.Value collecting request headers specified as "Name:
+// value", one per flag.
+type headerFlag []string
+
+// String returns the specified headers as a comma-separated list.
+func (hf *headerFlag) String() string {
+	return strings.Join(*hf, ", ")
+}
+
+// Set validates and records a header.
+func (hf *headerFlag) Set(value string) error {
+	if name, _, ok := strings.Cut(value, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header %q; \"Name: value\" expected", value)
+	}
+	*hf = append(*hf, value)
+
+	return nil
+}
+
+// apply adds the headers to the given request.
+func (hf headerFlag) apply(req *http.Request) {
+	for _, header := range hf {
+		name, value, _ := strings.Cut(header, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+}
+
+// newHTTPClient creates the http client used by subcommands. A timeout of 0
+// seconds disables the timeout (e.g., for long-lived event streams).
+func newHTTPClient(timeoutSeconds int, insecure bool) *http.Client {
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecure {
+		// Explicitly requested, e.g., to test against instances using
+		// self-signed certificates.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(timeoutSeconds) * time.Second,
+	}
+}
+
+// closeBody closes the given response body, logging any error.
+func closeBody(resp *http.Response, caller string) {
+	if err := resp.Body.Close(); err != nil {
+		log.Errorf("%s: failed to close response body: %v", caller, err)
+	}
+}
+
+// newFlagSet creates the flag set used by the given subcommand. The usage
+// text describes the arguments accepted after the flags.
+func newFlagSet(name string, arguments string) *flag.FlagSet {
+
+	fs := flag.NewFlagSet(commandName(name), flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] %s\n", fs.Name(), arguments)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// sourceSettings select the captured client requests used by the export and
+// replay subcommands, retrieved from a running instance or a file.
+type sourceSettings struct {
+	url      string
+	file     string
+	since    string
+	headers  headerFlag
+	filter   capture.Filter
+	ids      []string
+	insecure bool
+}
+
+// addFlags registers the flags used to select captured client requests.
+func (ss *sourceSettings) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&ss.url, "url", defaultInstanceURL, "Base URL of the running instance from which captured client requests are retrieved.")
+	fs.StringVar(&ss.file, "file", "", "JSON file containing client requests saved from the history endpoints, used instead of retrieving them from a running instance.")
+	fs.StringVar(&ss.filter.Method, "method", "", "Only use client requests using this HTTP method.")
+	fs.StringVar(&ss.filter.Path, "path", "", "Only use client requests for paths matching this pattern (e.g., /api/v1/echo/*).")
+	fs.StringVar(&ss.since, "since", "", "Only use client requests received at or after this RFC 3339 timestamp.")
+	fs.IntVar(&ss.filter.Limit, "limit", 0, "Maximum number of client requests used, keeping the most recent. A value of 0 uses all matching client requests.")
+	fs.Var(&ss.headers, "header", headerFlagHelp)
+	fs.BoolVar(&ss.insecure, "insecure", false, insecureFlagHelp)
+}
+
+// validate checks the settings once the flags are parsed. The remaining
+// arguments are the request IDs of the client requests to use.
+func (ss *sourceSettings) validate(fs *flag.FlagSet) error {
+
+	ss.ids = fs.Args()
+
+	if ss.since != "" {
+		since, err := time.Parse(time.RFC3339, ss.since)
+		if err != nil {
+			return fmt.Errorf("invalid since value %q; RFC 3339 timestamp expected", ss.since)
+		}
+		ss.filter.Since = since
+	}
+
+	return ss.filter.Validate()
+}
+
+// load retrieves the selected client requests, oldest first unless specific
+// request IDs were given.
+func (ss sourceSettings) load() ([]capture.Request, error) {
+
+	var entries []capture.Entry
+	var err error
+	if ss.file != "" {
+		entries, err = readHistoryFile(ss.file)
+	} else {
+		entries, err = fetchHistory(ss.url, ss.headers, newHTTPClient(defaultClientTimeout, ss.insecure))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := selectRequests(capture.Requests(entries), ss.ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.filter.Apply(requests), nil
+}
+
+// decodeHistory decodes a list of captured client requests (as returned by
+// the history endpoint) or a single captured client request (as returned by
+// the history-entry endpoint).
+func decodeHistory(data []byte) ([]capture.Entry, error) {
+
+	var entries []capture.Entry
+	if err := json.Unmarshal(data, &entries); err == nil {
+		return entries, nil
+	}
+
+	var entry capture.Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode captured client requests: %w", err)
+	}
+
+	return []capture.Entry{entry}, nil
+}
+
+// readHistoryFile reads captured client requests from the given file.
+func readHistoryFile(path string) ([]capture.Entry, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return decodeHistory(data)
+}
+
+// fetchHistory retrieves the captured client requests retained by the
+// instance at the given base URL.
+func fetchHistory(baseURL string, headers headerFlag, client *http.Client) ([]capture.Entry, error) {
+
+	historyURL := strings.TrimSuffix(baseURL, "/") + historyEndpointPath
+
+	req, err := http.NewRequest(http.MethodGet, historyURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	headers.apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve captured client requests: %w", err)
+	}
+	defer closeBody(resp, "fetchHistory")
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve captured client requests: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf(
+			"failed to retrieve captured client requests from %s: %s (request history is disabled unless the history-size flag is specified)",
+			historyURL,
+			resp.Status,
+		)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf(
+			"failed to retrieve captured client requests from %s: %s",
+			historyURL,
+			resp.Status,
+		)
+	}
+
+	return decodeHistory(data)
+}
+
+// selectRequests returns the client requests with the given capture or
+// request IDs, in the order given. The most recent client request is used if
+// a request ID was used for more than one client request. All client
+// requests are returned if no IDs are given.
+func selectRequests(requests []capture.Request, ids []string) ([]capture.Request, error) {
+
+	if len(ids) == 0 {
+		return requests, nil
+	}
+
+	byID := make(map[string]capture.Request, 2*len(requests))
+	for _, clientRequest := range requests {
+		byID[clientRequest.RequestID] = clientRequest
+	}
+
+	// Capture IDs are unique and take precedence over request IDs.
+	for _, clientRequest := range requests {
+		byID[clientRequest.CaptureID] = clientRequest
+	}
+
+	selected := make([]capture.Request, 0, len(ids))
+	for _, id := range ids {
+		clientRequest, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("no client request recorded with request ID %q", id)
+		}
+		selected = append(selected, clientRequest)
+	}
+
+	return selected, nil
+}
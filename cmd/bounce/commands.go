@@ -0,0 +1,125 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/config"
+)
+
+// Names of the subcommands supported by this application.
+const (
+	serveCommand  string = "serve"
+	sendCommand   string = "send"
+	replayCommand string = "replay"
+	tailCommand   string = "tail"
+	exportCommand string = "export"
+	helpCommand   string = "help"
+)
+
+// Exit codes returned by subcommands.
+const (
+	exitCodeSuccess int = 0
+	exitCodeFailure int = 1
+	exitCodeUsage   int = 2
+)
+
+// command is a subcommand supported by this application.
+type command struct {
+	run         func(args []string) int
+	name        string
+	description string
+}
+
+// commands returns the subcommands supported by this application, in the
+// order listed by the help subcommand.
+func commands() []command {
+	return []command{
+		{
+			name:        serveCommand,
+			description: "Run the HTTP server (the default if no subcommand is given)",
+			run:         runServe,
+		},
+		{
+			name:        sendCommand,
+			description: "Submit payload files (e.g., contrib/splunk-test-payload-*.json) to an endpoint",
+			run:         runSend,
+		},
+		{
+			name:        replayCommand,
+			description: "Re-send captured client requests to a target",
+			run:         runReplay,
+		},
+		{
+			name:        tailCommand,
+			description: "Stream client requests captured by a running instance to the terminal",
+			run:         runTail,
+		},
+		{
+			name:        exportCommand,
+			description: "Export captured client requests as a HAR archive, curl or HTTPie commands or a .http file",
+			run:         runExport,
+		},
+		{
+			name:        helpCommand,
+			description: "List the available subcommands",
+			run:         runHelp,
+		},
+	}
+}
+
+// commandName returns the full name of the given subcommand as used in usage
+// text.
+func commandName(name string) string {
+	return config.MyAppName + " " + name
+}
+
+// runCommand runs the subcommand given as the first argument and returns the
+// exit code. For compatibility with earlier releases, the serve subcommand
+// is used if the first argument is a flag or no arguments are given.
+func runCommand(args []string) int {
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe(args)
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+
+	log.Errorf("Unknown subcommand %q", args[0])
+	printCommands(os.Stderr)
+
+	return exitCodeUsage
+}
+
+// runHelp lists the available subcommands. This is the help subcommand.
+func runHelp(_ []string) int {
+
+	fmt.Fprint(os.Stdout, config.Branding())
+	printCommands(os.Stdout)
+
+	return exitCodeSuccess
+}
+
+// printCommands writes the list of available subcommands to w.
+func printCommands(w io.Writer) {
+
+	fmt.Fprintf(w, "Usage: %s <subcommand> [flags]\n\nSubcommands:\n", config.MyAppName)
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(w, "\nUse \"%s <subcommand> -h\" for the flags supported by each subcommand.\n", config.MyAppName)
+}
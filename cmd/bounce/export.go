@@ -0,0 +1,103 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/export"
+)
+
+// defaultExportFormat is the export format used if not specified.
+const defaultExportFormat string = export.FormatHAR
+
+// exportSettings are the settings used by the export subcommand.
+type exportSettings struct {
+	format string
+	output string
+	source sourceSettings
+}
+
+// parseExportFlags parses the export subcommand flags. The remaining
+// arguments are the request IDs of the client requests to export.
+func parseExportFlags(args []string) (exportSettings, error) {
+
+	var settings exportSettings
+
+	fs := newFlagSet(exportCommand, "[request-id ...]")
+	settings.source.addFlags(fs)
+	fs.StringVar(&settings.format, "format", defaultExportFormat, "Export format. Supported formats: "+strings.Join(export.Formats, ", ")+".")
+	fs.StringVar(&settings.output, "output", "", "File the export is written to. The export is written to stdout if not specified.")
+
+	if err := fs.Parse(args); err != nil {
+		return exportSettings{}, err
+	}
+
+	if !export.ValidFormat(settings.format) {
+		return exportSettings{}, fmt.Errorf(
+			"unsupported export format %q; supported formats: %s",
+			settings.format,
+			strings.Join(export.Formats, ", "),
+		)
+	}
+
+	if err := settings.source.validate(fs); err != nil {
+		return exportSettings{}, err
+	}
+
+	return settings, nil
+}
+
+// runExport exports captured client requests retrieved from a running
+// instance (or a file) and returns the exit code. This is the export
+// subcommand.
+func runExport(args []string) int {
+
+	settings, err := parseExportFlags(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitCodeSuccess
+		}
+		log.Errorf("runExport: %v", err)
+		return exitCodeUsage
+	}
+
+	requests, err := settings.source.load()
+	if err != nil {
+		log.Errorf("runExport: %v", err)
+		return exitCodeFailure
+	}
+
+	var w io.Writer = os.Stdout
+	if settings.output != "" {
+		f, err := os.Create(settings.output)
+		if err != nil {
+			log.Errorf("runExport: failed to create output file: %v", err)
+			return exitCodeFailure
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Errorf("runExport: failed to close output file: %v", err)
+			}
+		}()
+		w = f
+	}
+
+	if err := export.Write(w, settings.format, requests); err != nil {
+		log.Errorf("runExport: %v", err)
+		return exitCodeFailure
+	}
+
+	return exitCodeSuccess
+}
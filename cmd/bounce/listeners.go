@@ -0,0 +1,179 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/listener"
+)
+
+// openListeners opens each of the given listeners. If maxConnections is
+// greater than zero, the connection limit is applied across all of the
+// listeners combined. Any listeners already opened are closed if an error
+// occurs.
+func openListeners(specs []listener.Spec, maxConnections int) ([]listener.Listener, error) {
+
+	var connLimit *listener.ConnectionLimit
+	if maxConnections > 0 {
+		connLimit = listener.NewConnectionLimit(maxConnections)
+	}
+
+	var listeners []listener.Listener
+	for _, spec := range specs {
+		opened, err := listener.Open(spec)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+
+		for i := range opened {
+			if connLimit != nil {
+				opened[i].Listener = connLimit.Wrap(opened[i].Listener)
+			}
+		}
+
+		listeners = append(listeners, opened...)
+	}
+
+	return listeners, nil
+}
+
+// closeListeners closes each of the given listeners.
+func closeListeners(listeners []listener.Listener) {
+	for _, l := range listeners {
+		if err := l.Close(); err != nil {
+			log.Errorf("closeListeners: failed to close listener for %s: %v", l.Spec, err)
+		}
+	}
+}
+
+// configureTLS loads the certificate used by https listeners (including admin
+// API listeners), if any are specified, and applies it to each of the given
+// servers. The certificate is loaded once here so that problems are reported
+// before any requests are handled.
+func configureTLS(cfg *config.Config, servers ...*http.Server) error {
+
+	var useTLS bool
+	for _, spec := range append(cfg.ListenerSpecs(), cfg.AdminListenerSpecs()...) {
+		useTLS = useTLS || spec.TLS
+	}
+
+	if !useTLS {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	for _, server := range servers {
+		server.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	return nil
+}
+
+// newProtocols returns the protocols accepted by the http server. HTTP/1.x
+// is always accepted, as is HTTP/2 via https listeners. HTTP/2 without TLS
+// (h2c) is only accepted if requested.
+func newProtocols(cfg *config.Config) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(cfg.H2C)
+
+	return protocols
+}
+
+// serveListeners serves requests from each of the given listeners using the
+// provided http server. The result of serving each listener is sent on the
+// results channel once the listener is closed. Calling Shutdown() on the
+// server closes all listeners. The given name identifies the server in log
+// messages.
+func serveListeners(server *http.Server, name string, listeners []listener.Listener, results chan<- error) {
+
+	for _, l := range listeners {
+		switch addr := listenerAddr(l); {
+		case addr == l.Spec.Address:
+			log.Infof("%s is listening on %s", name, l.Spec)
+		default:
+			log.Infof("%s is listening on %s (%s)", name, l.Spec, addr)
+		}
+
+		go func(l listener.Listener) {
+			var err error
+			switch {
+			case l.Spec.TLS:
+				// The certificate is provided by the server TLS config.
+				err = server.ServeTLS(l, "", "")
+			default:
+				err = server.Serve(l)
+			}
+
+			// Calling Shutdown() will immediately return ErrServerClosed,
+			// which can be treated as a "successful shutdown" message of
+			// sorts.
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			if err != nil {
+				err = fmt.Errorf("failed to serve %s: %w", l.Spec, err)
+			}
+
+			results <- err
+		}(l)
+	}
+}
+
+// listenerAddr returns the address a listener is bound to. For TCP
+// listeners this includes the port chosen by the operating system if port 0
+// was specified.
+func listenerAddr(l listener.Listener) string {
+	addr := l.Addr()
+	if addr.Network() == "unix" && addr.String() == "" {
+		return "unnamed unix socket"
+	}
+
+	return addr.String()
+}
+
+// browseURL returns a URL suitable for viewing the index page in a web
+// browser, if a TCP listener is available.
+func browseURL(listeners []listener.Listener) (string, bool) {
+	for _, l := range listeners {
+		tcpAddr, ok := l.Addr().(*net.TCPAddr)
+		if l.Spec.Kind != listener.KindTCP || !ok {
+			continue
+		}
+
+		host, _, err := net.SplitHostPort(l.Spec.Address)
+		if err != nil || host == "" || tcpAddr.IP.IsUnspecified() {
+			host = "localhost"
+		}
+
+		scheme := "http"
+		if l.Spec.TLS {
+			scheme = "https"
+		}
+
+		return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, fmt.Sprint(tcpAddr.Port))), true
+	}
+
+	return "", false
+}
@@ -13,15 +13,19 @@ import (
 	"context"
 	"errors"
 	"flag"
-	"fmt"
-	htmlTemplate "html/template"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
-	textTemplate "text/template"
+	"syscall"
 
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/clientip"
 	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/listener"
+	"github.com/atc0005/bounce/internal/notify"
 	"github.com/atc0005/bounce/internal/routes"
+	"github.com/atc0005/bounce/internal/server"
 	goteamsnotify "github.com/atc0005/go-teams-notify/v2"
 
 	"github.com/apex/log"
@@ -31,8 +35,8 @@ import (
 // multi papertrail text delta; do go get
 // github.com/apex/log/handlers/${handler}; done
 
-// see templates.go for the hard-coded HTML/CSS template used for the index
-// page
+// see server/templates.go for the hard-coded HTML/CSS template used for the
+// index page
 
 func main() {
 
@@ -53,12 +57,20 @@ func main() {
 		os.Exit(exitCode)
 	}(&appExitCode)
 
+	appExitCode = runCommand(os.Args[1:])
+}
+
+// runServe runs the http server using the given command-line flags until
+// the application is terminated and returns the exit code. This is the serve
+// subcommand.
+func runServe(args []string) (appExitCode int) {
+
 	// This will use default logging settings (level filter, destination)
 	// as the application hasn't "booted up" far enough to apply custom
 	// choices yet.
 	log.Debug("Initializing application")
 
-	appConfig, err := config.NewConfig()
+	appConfig, err := config.NewConfig(args)
 	if err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			appExitCode = 0
@@ -71,19 +83,100 @@ func main() {
 
 	log.Debugf("AppConfig: %+v", appConfig)
 
-	mux := http.NewServeMux()
+	// Log messages and captured client request details are written to files
+	// instead of the standard outputs if requested.
+	files, err := openOutputFiles(appConfig)
+	if err != nil {
+		log.Errorf("Failed to open output files: %s", err)
+		appExitCode = 1
+		return
+	}
+	defer func() {
+		// Any further log messages are written to the standard log output
+		// target.
+		if files.Log != nil {
+			appConfig.SetLogOutput(appConfig.LogOutputTarget())
+		}
+		files.close()
+	}()
+
+	if files.Log != nil {
+		appConfig.SetLogOutput(files.Log)
+	}
+
+	var echoOutput io.Writer = os.Stdout
+	if files.Output != nil {
+		echoOutput = files.Output
+	}
+
+	// Trusted proxy settings were validated as part of initializing our
+	// configuration, so an error here is unexpected.
+	ipResolver, err := clientip.NewResolver(appConfig.TrustedProxies, appConfig.ForwardedHeader)
+	if err != nil {
+		log.Errorf("Failed to initialize client IP resolver: %s", err)
+		appExitCode = 1
+		return
+	}
+
+	// Pre-process bundled templates (or user-supplied template files) in
+	// string/text format to Templates that our handlers can execute. Based
+	// on brief testing, this seems to provide a significant performance boost
+	// at the cost of a little more startup time. Templates are validated
+	// here so that problems are reported before any requests are handled.
+	tmpls, err := server.LoadTemplates(appConfig)
+	if err != nil {
+		log.Errorf("Failed to load templates: %s", err)
+		appExitCode = 1
+		return
+	}
+
+	// Captured client requests are retained in memory for later review.
+	reqHistory := capture.NewStore(appConfig.HistorySize, appConfig.APIKeyHeaders()...)
+
+	// The state of the notifications manager is shared with the admin API.
+	notifyState := notify.NewStatus()
 
-	// Apply "default" timeout settings provided by Simon Frey; override the
-	// default "wait forever" configuration.
-	// FIXME: Refine these settings to apply values more appropriate for a
-	// small-to-medium on-premise API (e.g., not over a public Internet link
-	// where clients are expected to be slow)
+	// Route handlers, along with the middleware applied to all requests,
+	// are served by a router which is replaced as a whole when the
+	// configuration is reloaded.
+	router := &server.Router{}
+	adminRouter := &server.Router{}
+
+	// Apply configured timeout settings (by default those provided by Simon
+	// Frey); override the default "wait forever" configuration.
 	httpServer := &http.Server{
-		ReadHeaderTimeout: config.HTTPServerReadHeaderTimeout,
-		ReadTimeout:       config.HTTPServerReadTimeout,
-		WriteTimeout:      config.HTTPServerWriteTimeout,
-		Handler:           mux,
-		Addr:              fmt.Sprintf("%s:%d", appConfig.LocalIPAddress, appConfig.LocalTCPPort),
+		ReadHeaderTimeout: appConfig.HTTPServerReadHeaderTimeout(),
+		ReadTimeout:       appConfig.HTTPServerReadTimeout(),
+		WriteTimeout:      appConfig.HTTPServerWriteTimeout(),
+		IdleTimeout:       appConfig.HTTPServerIdleTimeout(),
+		MaxHeaderBytes:    appConfig.MaxHeaderBytes,
+		Handler:           router,
+		ConnContext:       routes.ConnContext,
+		Protocols:         newProtocols(appConfig),
+	}
+
+	// The admin API uses a separate http server if dedicated admin listeners
+	// are specified so that it can be firewalled separately.
+	servers := []*http.Server{httpServer}
+	var adminServer *http.Server
+	if appConfig.AdminEnabled() && len(appConfig.AdminListen) > 0 {
+		adminServer = &http.Server{
+			ReadHeaderTimeout: httpServer.ReadHeaderTimeout,
+			ReadTimeout:       httpServer.ReadTimeout,
+			WriteTimeout:      httpServer.WriteTimeout,
+			IdleTimeout:       httpServer.IdleTimeout,
+			MaxHeaderBytes:    httpServer.MaxHeaderBytes,
+			Handler:           adminRouter,
+			ConnContext:       routes.ConnContext,
+			Protocols:         newProtocols(appConfig),
+		}
+		servers = append(servers, adminServer)
+	}
+
+	if err := configureTLS(appConfig, servers...); err != nil {
+		log.Errorf("Failed to configure TLS: %s", err)
+		appExitCode = 1
+		return
 	}
 
 	// Create context that can be used to cancel background jobs.
@@ -112,110 +205,154 @@ func main() {
 	// for when the http server has been shutdown.
 	// done := make(chan struct{}, 1)
 	httpDone := make(chan struct{}, 1)
+	adminDone := make(chan struct{}, 1)
 	notifyDone := make(chan struct{}, 1)
 	quit := make(chan os.Signal, 1)
 
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
-	// Where clientRequestDetails values will be sent for processing. We use a
+	// Where captured client requests will be sent for processing. We use a
 	// buffered channel in an effort to reduce the delay for client requests
 	// as much as possible.
-	notifyWorkQueue := make(chan clientRequestDetails, config.NotifyMgrQueueDepth)
+	notifyWorkQueue := make(chan capture.Request, config.NotifyMgrQueueDepth)
+
+	// Reloaded configurations are passed to the notifications manager in
+	// order to apply updated notifier settings.
+	notifyCfgUpdates := make(chan *config.Config, 1)
+
+	// The notifications manager uses a separate context which is cancelled
+	// only after the http server has been shutdown, giving it the chance to
+	// send pending notifications first if requested.
+	notifyCtx, notifyCancel := context.WithCancel(context.Background())
+	defer notifyCancel()
+	notifyFlushRequests := make(chan notify.FlushRequest)
 
 	// Create "notifications manager" function as persistent goroutine to
 	// process incoming notification requests.
-	go StartNotifyMgr(ctx, appConfig, notifyWorkQueue, notifyDone)
+	go notify.StartManager(notifyCtx, appConfig, notifyCfgUpdates, notifyFlushRequests, reqHistory, notifyState, notifyWorkQueue, notifyDone)
 
 	// Setup "listener" to cancel the parent context when Signal.Notify()
-	// indicates that SIGINT has been received
+	// indicates that SIGINT or SIGTERM has been received
 	go shutdownListener(ctx, quit, cancel)
 
 	// Setup "listener" to shutdown the running http server when
 	// the parent context has been cancelled
-	go gracefulShutdown(ctx, httpServer, config.HTTPServerShutdownTimeout, httpDone)
+	go gracefulShutdown(ctx, httpServer, appConfig.HTTPServerShutdownTimeout(), httpDone)
+	if adminServer != nil {
+		go gracefulShutdown(ctx, adminServer, appConfig.HTTPServerShutdownTimeout(), adminDone)
+	}
 
-	// Pre-process bundled templates in string/text format to Templates that
-	// our handlers can execute. Based on brief testing, this seems to provide
-	// a significant performance boost at the cost of a little more startup
-	// time.
-	indexPageHandleTemplate := htmlTemplate.Must(
-		htmlTemplate.New("indexPage").Parse(handleIndexTemplateText))
-	echoHandlerTemplate := textTemplate.Must(
-		textTemplate.New("echoHandler").Parse(handleEchoTemplateText))
+	if appConfig.TemplateReload {
+		go server.TemplateReloader(ctx, tmpls, server.TemplateReloadInterval)
+	}
 
 	// SETUP ROUTES
-	// See handlers.go for handler definitions
-
-	var ourRoutes routes.Routes
-	ourRoutes.Add(routes.Route{
-		Name:           "index",
-		Description:    "Main page, fallback for unspecified routes",
-		Pattern:        "/",
-		AllowedMethods: []string{http.MethodGet},
-		HandlerFunc:    handleIndex(indexPageHandleTemplate, &ourRoutes),
-	})
-
-	ourRoutes.Add(routes.Route{
-		Name:           "echo",
-		Description:    "Prints received values as-is to stdout and returns them via HTTP response",
-		Pattern:        apiV1EchoEndpointPattern,
-		AllowedMethods: []string{http.MethodGet, http.MethodPost},
-		HandlerFunc: echoHandler(
-			ctx,
-			echoHandlerTemplate,
-			appConfig.ColorizedJSON,
-			appConfig.ColorizedJSONIndent,
-			notifyWorkQueue,
-		),
-	})
-
-	ourRoutes.Add(routes.Route{
-		Name:           "echo-json",
-		Description:    "Prints formatted JSON response to stdout and via HTTP response",
-		Pattern:        apiV1EchoJSONEndpointPattern,
-		AllowedMethods: []string{http.MethodPost},
-		HandlerFunc: echoHandler(
-			ctx,
-			echoHandlerTemplate,
-			appConfig.ColorizedJSON,
-			appConfig.ColorizedJSONIndent,
-			notifyWorkQueue,
-		),
-	})
-
-	ourRoutes.RegisterWithServeMux(mux)
-
-	// listen on specified port and IP Address, block until app is terminated
-	log.Infof("%s is listening on %s port %d",
-		config.MyAppName, appConfig.LocalIPAddress, appConfig.LocalTCPPort)
-
-	log.Infof("Visit http://%s:%d in your web browser for details",
-		appConfig.LocalIPAddress, appConfig.LocalTCPPort)
-
-	// TODO: This can be handled in a cleaner fashion?
-	if err := httpServer.ListenAndServe(); err != nil {
-
-		// Calling Shutdown() will immediately return ErrServerClosed, but
-		// based on reading the docs it sounds like any errors from closing
-		// connections will instead overwrite this default error message with
-		// a real one, so receiving ErrServerClosed can be treated as a
-		// "successful shutdown" message of sorts, so ignore it and look for
-		// any other error message.
-		if !errors.Is(err, http.ErrServerClosed) {
-			log.Errorf("error occurred while running httpServer: %v", err)
+	// See the server package for route definitions and handlers.
+	deps := server.Deps{
+		Templates:         tmpls,
+		Output:            echoOutput,
+		IPResolver:        ipResolver,
+		History:           reqHistory,
+		NotifyWorkQueue:   notifyWorkQueue,
+		FaultOverrides:    server.NewOverrides[config.FaultInjection](),
+		ResponseOverrides: server.NewOverrides[config.ResponseRule](),
+		NotifyStatus:      notifyState,
+		Files:             files.all(),
+		Expectations:      server.NewExpectationStore(reqHistory.RedactHeaders),
+		Feed:              capture.NewFeed(),
+	}
+	mux, adminMux, err := server.NewRouter(ctx, appConfig, deps)
+	if err != nil {
+		log.Errorf("Failed to setup routes: %s", err)
+		appExitCode = 1
+		return
+	}
+	router.Swap(newHandler(appConfig, ipResolver, mux))
+	if adminMux != nil {
+		adminRouter.Swap(newHandler(appConfig, ipResolver, adminMux))
+	}
+
+	// Output files are reopened and the configuration is reloaded when
+	// SIGHUP is received. Reopening output files supports rotation by
+	// external tools (e.g., logrotate).
+	reloader := &configReloader{
+		ctx:         ctx,
+		args:        args,
+		files:       files,
+		router:      router,
+		adminRouter: adminRouter,
+		deps:        deps,
+		cfgUpdates:  notifyCfgUpdates,
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go hupListener(ctx, hup, files, reloader)
+
+	// listen on each specified address
+	listeners, err := openListeners(appConfig.ListenerSpecs(), appConfig.MaxConnections)
+	if err != nil {
+		log.Errorf("error occurred while opening listeners: %v", err)
+		appExitCode = 1
+		return
+	}
+
+	// Connection limits are not applied to admin listeners so that the
+	// admin API remains available while the public listeners are busy.
+	var adminListeners []listener.Listener
+	if adminServer != nil {
+		adminListeners, err = openListeners(appConfig.AdminListenerSpecs(), 0)
+		if err != nil {
+			closeListeners(listeners)
+			log.Errorf("error occurred while opening admin listeners: %v", err)
 			appExitCode = 1
 			return
 		}
 	}
 
+	serveResults := make(chan error, len(listeners)+len(adminListeners))
+	serveListeners(httpServer, config.MyAppName, listeners, serveResults)
+	serveListeners(adminServer, config.MyAppName+" admin API", adminListeners, serveResults)
+
+	if url, ok := browseURL(listeners); ok {
+		log.Infof("Visit %s in your web browser for details", url)
+	}
+
+	// block until app is terminated. The http server is shutdown (closing
+	// all listeners) if any listener fails.
+	for range len(listeners) + len(adminListeners) {
+		if err := <-serveResults; err != nil {
+			log.Errorf("error occurred while running httpServer: %v", err)
+			appExitCode = 1
+			cancel()
+		}
+	}
+
 	log.Debug("Waiting on gracefulShutdown completion signal")
 	<-httpDone
+	if adminServer != nil {
+		<-adminDone
+	}
 	log.Debug("Received gracefulShutdown completion signal")
 
-	log.Debug("Waiting on StartNotifyMgr completion signal")
+	// No further client requests are accepted at this point, so pending
+	// notifications may be sent before the notifications manager is
+	// shutdown.
+	if appConfig.NotifyFlush {
+		flushed := make(chan struct{})
+		notifyFlushRequests <- notify.FlushRequest{
+			Done:    flushed,
+			Timeout: appConfig.NotifyMgrFlushTimeout(),
+		}
+		log.Debug("Waiting on StartManager flush completion signal")
+		<-flushed
+	}
+	notifyCancel()
+
+	log.Debug("Waiting on StartManager completion signal")
 	<-notifyDone
-	log.Debug("Received StartNotifyMgr completion signal")
+	log.Debug("Received StartManager completion signal")
 
 	log.Infof("%s successfully shutdown", config.MyAppName)
 
+	return appExitCode
 }
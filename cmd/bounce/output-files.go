@@ -0,0 +1,115 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/logfile"
+	"github.com/atc0005/bounce/internal/server"
+)
+
+// outputFiles is the collection of files opened for log messages and
+// captured client request details. The same file is used for both if the
+// user specifies the same path.
+type outputFiles struct {
+
+	// Log is the file log messages are written to, if any.
+	Log *logfile.File
+
+	// Output is the file captured client request details are written to, if
+	// any.
+	Output *logfile.File
+}
+
+// openOutputFiles opens the log and output files specified by the user, if
+// any, applying the requested rotation settings.
+func openOutputFiles(cfg *config.Config) (outputFiles, error) {
+
+	var files outputFiles
+
+	rotation := func(path string) logfile.Options {
+		return logfile.Options{
+			Path:       path,
+			MaxSize:    int64(cfg.RotateMaxSize) * server.MB,
+			MaxAge:     time.Duration(cfg.RotateMaxAge) * time.Hour,
+			MaxBackups: cfg.RotateMaxBackups,
+			Compress:   cfg.RotateCompress,
+		}
+	}
+
+	if cfg.LogFile != "" {
+		f, err := logfile.Open(rotation(cfg.LogFile))
+		if err != nil {
+			return outputFiles{}, err
+		}
+		files.Log = f
+	}
+
+	switch {
+	case cfg.OutputFile == "":
+	case files.Log != nil && samePath(cfg.OutputFile, cfg.LogFile):
+		files.Output = files.Log
+	default:
+		f, err := logfile.Open(rotation(cfg.OutputFile))
+		if err != nil {
+			files.close()
+			return outputFiles{}, err
+		}
+		files.Output = f
+	}
+
+	return files, nil
+}
+
+// samePath indicates whether the given paths refer to the same file.
+func samePath(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+
+	return absA == absB
+}
+
+// all returns each distinct open file.
+func (of outputFiles) all() []*logfile.File {
+	var files []*logfile.File
+	if of.Log != nil {
+		files = append(files, of.Log)
+	}
+	if of.Output != nil && of.Output != of.Log {
+		files = append(files, of.Output)
+	}
+
+	return files
+}
+
+// reopen closes and reopens each open file.
+func (of outputFiles) reopen() {
+	for _, f := range of.all() {
+		if err := f.Reopen(); err != nil {
+			log.Errorf("outputFiles: failed to reopen %s: %v", f.Path(), err)
+			continue
+		}
+		log.Infof("outputFiles: reopened %s", f.Path())
+	}
+}
+
+// close closes each open file.
+func (of outputFiles) close() {
+	for _, f := range of.all() {
+		if err := f.Close(); err != nil {
+			log.Errorf("outputFiles: failed to close %s: %v", f.Path(), err)
+		}
+	}
+}
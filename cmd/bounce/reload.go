@@ -0,0 +1,120 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/server"
+)
+
+// configReloader applies a reloaded configuration to the running
+// application. The logging settings, notifier settings, client IP resolution
+// settings (trusted proxies and forwarding header), routes (including
+// response rules, per-route middleware and the middleware applied to all
+// requests) and templates are replaced. The listening addresses (including
+// admin API listeners), output files and request history size are not
+// changed by a reload.
+type configReloader struct {
+	ctx         context.Context
+	args        []string
+	files       outputFiles
+	router      *server.Router
+	adminRouter *server.Router
+	cfgUpdates  chan<- *config.Config
+	deps        server.Deps
+}
+
+// reload reads and validates the configuration using the command-line flags
+// given when the application was started and, if valid, applies it.
+// Everything derived from the new configuration is prepared before any
+// changes are made so that the running configuration is kept as-is if the
+// new configuration is invalid.
+func (cr *configReloader) reload() error {
+
+	newCfg, err := config.FromArgs(cr.args)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	newTmpls, err := server.LoadTemplates(newCfg)
+	if err != nil {
+		return err
+	}
+
+	// Trusted proxy settings were validated along with the configuration,
+	// so an error here is unexpected.
+	ipResolver, err := clientip.NewResolver(newCfg.TrustedProxies, newCfg.ForwardedHeader)
+	if err != nil {
+		return fmt.Errorf("failed to initialize client IP resolver: %w", err)
+	}
+
+	// The new ServeMux uses the long-lived TemplateSet, which is updated
+	// below once all other changes have been prepared.
+	deps := cr.deps
+	deps.IPResolver = ipResolver
+	mux, adminMux, err := server.NewRouter(cr.ctx, newCfg, deps)
+	if err != nil {
+		return err
+	}
+
+	if err := newCfg.ConfigureLogging(); err != nil {
+		return fmt.Errorf("failed to configure logging: %w", err)
+	}
+	if cr.files.Log != nil {
+		newCfg.SetLogOutput(cr.files.Log)
+	}
+
+	cr.deps.Templates.Replace(newTmpls)
+	cr.router.Swap(newHandler(newCfg, ipResolver, mux))
+
+	// Admin listeners are not changed by a reload. If the admin API is no
+	// longer served via dedicated listeners, these listeners serve no routes.
+	if adminMux == nil {
+		adminMux = http.NewServeMux()
+	}
+	cr.adminRouter.Swap(newHandler(newCfg, ipResolver, adminMux))
+
+	select {
+	case cr.cfgUpdates <- newCfg:
+	case <-cr.ctx.Done():
+	}
+
+	log.Debugf("reload: AppConfig: %+v", newCfg)
+
+	return nil
+}
+
+// hupListener reopens the given output files and reloads the configuration
+// each time a signal is received on the provided channel (e.g., SIGHUP sent
+// by logrotate or an administrator). This is intended to be run as a
+// goroutine.
+func hupListener(ctx context.Context, hup <-chan os.Signal, files outputFiles, reloader *configReloader) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case osSignal := <-hup:
+			log.Debugf("hupListener: Received signal: %v", osSignal)
+			files.reopen()
+
+			if err := reloader.reload(); err != nil {
+				log.Errorf("hupListener: Failed to reload configuration, keeping current configuration: %v", err)
+				continue
+			}
+
+			log.Info("hupListener: Configuration reloaded")
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/export"
+)
+
+// replaySettings are the settings used by the replay subcommand.
+type replaySettings struct {
+	target  string
+	source  sourceSettings
+	delay   int
+	timeout int
+}
+
+// parseReplayFlags parses the replay subcommand flags. The remaining
+// arguments are the request IDs of the client requests to re-send.
+func parseReplayFlags(args []string) (replaySettings, error) {
+
+	var settings replaySettings
+
+	fs := newFlagSet(replayCommand, "[request-id ...]")
+	settings.source.addFlags(fs)
+	fs.StringVar(&settings.target, "target", "", "Base URL (e.g., https://staging.example.com) the captured client requests are re-sent to. The original path and query string are appended. Required.")
+	fs.IntVar(&settings.delay, "delay", 0, "Number of milliseconds to wait between re-sent client requests.")
+	fs.IntVar(&settings.timeout, "timeout", defaultClientTimeout, clientTimeoutFlagHelp)
+
+	if err := fs.Parse(args); err != nil {
+		return replaySettings{}, err
+	}
+
+	switch {
+	case settings.target == "":
+		fs.Usage()
+		return replaySettings{}, fmt.Errorf("target URL not specified")
+	case settings.delay < 0:
+		return replaySettings{}, fmt.Errorf("invalid delay %d; value must not be negative", settings.delay)
+	case settings.timeout < 0:
+		return replaySettings{}, fmt.Errorf("invalid timeout %d; value must not be negative", settings.timeout)
+	}
+
+	if err := settings.source.validate(fs); err != nil {
+		return replaySettings{}, err
+	}
+
+	return settings, nil
+}
+
+// runReplay re-sends captured client requests retrieved from a running
+// instance (or a file) to the target, oldest first, and returns the exit
+// code. The exit code indicates a failure if any client request could not
+// be re-sent or did not receive a 2xx response. This is the replay
+// subcommand.
+func runReplay(args []string) int {
+
+	settings, err := parseReplayFlags(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitCodeSuccess
+		}
+		log.Errorf("runReplay: %v", err)
+		return exitCodeUsage
+	}
+
+	requests, err := settings.source.load()
+	if err != nil {
+		log.Errorf("runReplay: %v", err)
+		return exitCodeFailure
+	}
+
+	if len(requests) == 0 {
+		log.Warn("runReplay: no captured client requests selected")
+		return exitCodeSuccess
+	}
+
+	// Replays may be interrupted (e.g., Ctrl+C) between or during requests.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := newHTTPClient(settings.timeout, settings.source.insecure)
+	delay := time.Duration(settings.delay) * time.Millisecond
+
+	var failed int
+	for i, clientRequest := range requests {
+		if i > 0 && delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			log.Warnf("runReplay: interrupted; %d of %d client requests re-sent", i, len(requests))
+			return exitCodeFailure
+		}
+
+		if err := replayRequest(ctx, client, clientRequest, settings.target); err != nil {
+			log.WithField("request_id", clientRequest.RequestID).Errorf("runReplay: %v", err)
+			failed++
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"replayed": len(requests),
+		"failed":   failed,
+	}).Info("runReplay: replay complete")
+
+	if failed > 0 {
+		return exitCodeFailure
+	}
+
+	return exitCodeSuccess
+}
+
+// replayRequest re-sends a single captured client request to the target.
+// The response body is discarded.
+func replayRequest(ctx context.Context, client *http.Client, clientRequest capture.Request, target string) error {
+
+	req, err := export.NewRequest(ctx, clientRequest, target)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to re-send client request: %w", err)
+	}
+	defer closeBody(resp, "replayRequest")
+
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"request_id": clientRequest.RequestID,
+		"method":     req.Method,
+		"url":        req.URL.String(),
+		"status":     resp.Status,
+	}).Info("replayRequest: client request re-sent")
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/log"
+)
+
+// Default settings for the send subcommand.
+const (
+	defaultSendURL         string = defaultInstanceURL + "/api/v1/echo/json"
+	defaultSendMethod      string = http.MethodPost
+	defaultSendContentType string = "application/json"
+)
+
+// stdinFileName is the file name used to read a payload from stdin.
+const stdinFileName string = "-"
+
+// sendSettings are the settings used by the send subcommand.
+type sendSettings struct {
+	url         string
+	method      string
+	contentType string
+	headers     headerFlag
+	files       []string
+	timeout     int
+	insecure    bool
+}
+
+// parseSendFlags parses the send subcommand flags. The remaining arguments
+// are the payload files to submit.
+func parseSendFlags(args []string) (sendSettings, error) {
+
+	var settings sendSettings
+
+	fs := newFlagSet(sendCommand, "<payload-file> ...")
+	fs.StringVar(&settings.url, "url", defaultSendURL, "URL of the endpoint the payload files are submitted to.")
+	fs.StringVar(&settings.method, "method", defaultSendMethod, "HTTP method used to submit the payload files.")
+	fs.StringVar(&settings.contentType, "content-type", "", "Content-Type of the payload files. If not specified, the type is determined by the file extension, falling back to "+defaultSendContentType+".")
+	fs.Var(&settings.headers, "header", headerFlagHelp)
+	fs.IntVar(&settings.timeout, "timeout", defaultClientTimeout, clientTimeoutFlagHelp)
+	fs.BoolVar(&settings.insecure, "insecure", false, insecureFlagHelp)
+
+	if err := fs.Parse(args); err != nil {
+		return sendSettings{}, err
+	}
+
+	settings.files = fs.Args()
+	if len(settings.files) == 0 {
+		fs.Usage()
+		return sendSettings{}, fmt.Errorf("no payload files specified; use %q to read from stdin", stdinFileName)
+	}
+
+	if settings.timeout < 0 {
+		return sendSettings{}, fmt.Errorf("invalid timeout %d; value must not be negative", settings.timeout)
+	}
+
+	return settings, nil
+}
+
+// payloadContentType returns the Content-Type used for the given payload
+// file.
+func payloadContentType(settings sendSettings, file string) string {
+
+	if settings.contentType != "" {
+		return settings.contentType
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(file)); contentType != "" {
+		return contentType
+	}
+
+	return defaultSendContentType
+}
+
+// readPayload reads the given payload file, or stdin if the file name is
+// stdinFileName.
+func readPayload(file string) ([]byte, error) {
+	if file == stdinFileName {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(file)
+}
+
+// runSend submits each payload file to the endpoint and writes each response
+// body to stdout. The exit code indicates a failure if any payload could not
+// be submitted or did not receive a 2xx response. This is the send
+// subcommand.
+func runSend(args []string) int {
+
+	settings, err := parseSendFlags(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitCodeSuccess
+		}
+		log.Errorf("runSend: %v", err)
+		return exitCodeUsage
+	}
+
+	client := newHTTPClient(settings.timeout, settings.insecure)
+
+	exitCode := exitCodeSuccess
+	for _, file := range settings.files {
+		ctxLog := log.WithFields(log.Fields{
+			"file": file,
+			"url":  settings.url,
+		})
+
+		if err := sendPayload(client, settings, file); err != nil {
+			ctxLog.Errorf("runSend: %v", err)
+			exitCode = exitCodeFailure
+		}
+	}
+
+	return exitCode
+}
+
+// sendPayload submits a single payload file to the endpoint.
+func sendPayload(client *http.Client, settings sendSettings, file string) error {
+
+	payload, err := readPayload(file)
+	if err != nil {
+		return fmt.Errorf("failed to read payload: %w", err)
+	}
+
+	req, err := http.NewRequest(settings.method, settings.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", payloadContentType(settings, file))
+	settings.headers.apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit payload: %w", err)
+	}
+	defer closeBody(resp, "sendPayload")
+
+	log.WithFields(log.Fields{
+		"file":   file,
+		"url":    settings.url,
+		"status": resp.Status,
+		"bytes":  len(payload),
+	}).Info("sendPayload: payload submitted")
+
+	if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -8,15 +8,36 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/clientip"
 	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
 )
 
+// newHandler wraps the given ServeMux with the middleware applied to all
+// requests, regardless of route. Request IDs are assigned first so that they
+// are available to the access log and all later handlers.
+func newHandler(cfg *config.Config, ipResolver *clientip.Resolver, mux *http.ServeMux) http.Handler {
+
+	globalMiddleware := routes.NewChain(
+		routes.RequestID(),
+		routes.ConnectionRequests(),
+		routes.AccessLog(ipResolver.GetIP),
+		routes.Recoverer(),
+	)
+	if cfg.Compress {
+		globalMiddleware = globalMiddleware.Append(routes.Compress(gzip.DefaultCompression))
+	}
+
+	return globalMiddleware.Then(mux)
+}
+
 // shutdownListener listens for an os.Signal on the provided quit channel.
 // When this signal is received, the provided parent context cancel() function
 // is used to cancel all child contexts. This is intended to be run as a
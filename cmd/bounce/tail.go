@@ -0,0 +1,258 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TylerBrock/colorjson"
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/server"
+	"github.com/atc0005/bounce/internal/sse"
+)
+
+// historyStreamEndpointPath is the path of the API endpoint used to stream
+// captured client requests from a running instance.
+const historyStreamEndpointPath string = "/api/v1/history/stream"
+
+// Default settings for the tail subcommand.
+const (
+	defaultTailColor          bool          = true
+	defaultTailIndent         int           = 2
+	defaultTailReconnect      bool          = true
+	defaultTailReconnectDelay time.Duration = 2 * time.Second
+)
+
+// errStreamRejected indicates that the running instance rejected the request
+// to stream captured client requests (e.g., due to an access policy).
+var errStreamRejected = errors.New("stream request rejected")
+
+// tailSettings are the settings used by the tail subcommand.
+type tailSettings struct {
+	url       string
+	method    string
+	path      string
+	headers   headerFlag
+	indent    int
+	color     bool
+	reconnect bool
+	insecure  bool
+}
+
+// parseTailFlags parses the tail subcommand flags.
+func parseTailFlags(args []string) (tailSettings, error) {
+
+	var settings tailSettings
+
+	fs := newFlagSet(tailCommand, "")
+	fs.StringVar(&settings.url, "url", defaultInstanceURL, "Base URL of the running instance from which captured client requests are streamed.")
+	fs.StringVar(&settings.method, "method", "", "Only show client requests using this HTTP method.")
+	fs.StringVar(&settings.path, "path", "", "Only show client requests for paths matching this pattern (e.g., /api/v1/echo/*).")
+	fs.Var(&settings.headers, "header", headerFlagHelp)
+	fs.BoolVar(&settings.color, "color", defaultTailColor, "Whether captured client requests are shown as colorized JSON.")
+	fs.IntVar(&settings.indent, "indent-lvl", defaultTailIndent, "Number of spaces used to indent JSON output.")
+	fs.BoolVar(&settings.reconnect, "reconnect", defaultTailReconnect, "Whether to reconnect if the connection to the running instance is lost or cannot be established.")
+	fs.BoolVar(&settings.insecure, "insecure", false, insecureFlagHelp)
+
+	if err := fs.Parse(args); err != nil {
+		return tailSettings{}, err
+	}
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return tailSettings{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if settings.indent < 0 {
+		return tailSettings{}, fmt.Errorf("invalid indent level %d; value must not be negative", settings.indent)
+	}
+
+	filter := capture.Filter{Method: settings.method, Path: settings.path}
+	if err := filter.Validate(); err != nil {
+		return tailSettings{}, err
+	}
+
+	return settings, nil
+}
+
+// streamURL returns the URL used to stream captured client requests,
+// including the filter settings.
+func (ts tailSettings) streamURL() string {
+
+	query := url.Values{}
+	if ts.method != "" {
+		query.Set("method", ts.method)
+	}
+	if ts.path != "" {
+		query.Set("path", ts.path)
+	}
+
+	streamURL := strings.TrimSuffix(ts.url, "/") + historyStreamEndpointPath
+	if len(query) > 0 {
+		streamURL += "?" + query.Encode()
+	}
+
+	return streamURL
+}
+
+// runTail streams client requests captured by a running instance to stdout
+// until interrupted and returns the exit code. This is the tail subcommand.
+func runTail(args []string) int {
+
+	settings, err := parseTailFlags(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitCodeSuccess
+		}
+		log.Errorf("runTail: %v", err)
+		return exitCodeUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Event streams are long-lived, so no timeout is applied.
+	client := newHTTPClient(0, settings.insecure)
+
+	for {
+		err := streamRequests(ctx, client, settings, os.Stdout)
+
+		switch {
+		case ctx.Err() != nil:
+			return exitCodeSuccess
+
+		case errors.Is(err, errStreamRejected):
+			log.Errorf("runTail: %v", err)
+			return exitCodeFailure
+
+		case !settings.reconnect:
+			if err != nil {
+				log.Errorf("runTail: %v", err)
+				return exitCodeFailure
+			}
+			log.Info("runTail: stream ended")
+			return exitCodeSuccess
+		}
+
+		if err == nil {
+			err = io.EOF
+		}
+		log.Warnf("runTail: stream ended (%v); reconnecting in %v", err, defaultTailReconnectDelay)
+
+		select {
+		case <-time.After(defaultTailReconnectDelay):
+		case <-ctx.Done():
+			return exitCodeSuccess
+		}
+	}
+}
+
+// streamRequests connects to the running instance and writes each captured
+// client request received to w until the stream ends or the context is
+// cancelled.
+func streamRequests(ctx context.Context, client *http.Client, settings tailSettings, w io.Writer) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, settings.streamURL(), nil)
+	if err != nil {
+		return fmt.Errorf("%w: invalid URL: %v", errStreamRejected, err)
+	}
+	req.Header.Set("Accept", sse.ContentType)
+	settings.headers.apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer closeBody(resp, "streamRequests")
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w by %s: %s", errStreamRejected, req.URL.Redacted(), resp.Status)
+	}
+
+	log.WithField("url", req.URL.Redacted()).Info("streamRequests: connected; waiting for client requests")
+
+	events := sse.NewReader(resp.Body)
+	for {
+		event, err := events.Next()
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if event.Event != server.HistoryStreamEventType {
+			continue
+		}
+
+		if err := writeStreamedRequest(w, settings, []byte(event.Data)); err != nil {
+			log.Errorf("streamRequests: %v", err)
+		}
+	}
+}
+
+// writeStreamedRequest writes a summary line followed by the captured client
+// request as (optionally colorized) JSON.
+func writeStreamedRequest(w io.Writer, settings tailSettings, data []byte) error {
+
+	var clientRequest capture.Request
+	if err := json.Unmarshal(data, &clientRequest); err != nil {
+		return fmt.Errorf("failed to decode client request: %w", err)
+	}
+
+	var formatted []byte
+	switch settings.color {
+	case true:
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("failed to decode client request: %w", err)
+		}
+
+		f := colorjson.NewFormatter()
+		f.Indent = settings.indent
+
+		var err error
+		formatted, err = f.Marshal(document)
+		if err != nil {
+			return fmt.Errorf("failed to format client request: %w", err)
+		}
+
+	default:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", strings.Repeat(" ", settings.indent)); err != nil {
+			return fmt.Errorf("failed to format client request: %w", err)
+		}
+		formatted = buf.Bytes()
+	}
+
+	_, err := fmt.Fprintf(
+		w,
+		"==> %s %s %s (request ID %s)\n%s\n\n",
+		clientRequest.ReceivedAt.Format(time.RFC3339),
+		clientRequest.HTTPMethod,
+		clientRequest.RequestURL,
+		clientRequest.RequestID,
+		formatted,
+	)
+
+	return err
+}
@@ -0,0 +1,21 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package export converts captured client requests to formats which may be
+used to reproduce them: a HAR 1.2 archive, curl or HTTPie commands and the
+.http file format used by the REST Client extension for Visual Studio Code
+(see contrib/web-app-api-tests.http for an example).
+
+Exported commands use POSIX shell quoting. Headers which only apply to the
+original connection (e.g., Content-Length and Host) are omitted from the
+commands and .http files.
+
+NewRequest creates a request which re-sends a captured client request to
+another target, as used by the replay subcommand.
+*/
+package export
@@ -0,0 +1,250 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atc0005/bounce/capture"
+)
+
+// Supported export formats.
+const (
+
+	// FormatHAR is a HAR 1.2 archive containing all exported client
+	// requests.
+	FormatHAR string = "har"
+
+	// FormatCurl is a curl command for each exported client request.
+	FormatCurl string = "curl"
+
+	// FormatHTTPie is an HTTPie command for each exported client request.
+	FormatHTTPie string = "httpie"
+
+	// FormatRESTClient is the .http file format used by the REST Client
+	// extension for Visual Studio Code (and compatible tools).
+	FormatRESTClient string = "http"
+)
+
+// Formats is the list of supported export formats.
+var Formats = []string{
+	FormatHAR,
+	FormatCurl,
+	FormatHTTPie,
+	FormatRESTClient,
+}
+
+// ContentTypes maps each export format to the Content-Type used when the
+// export is returned via HTTP.
+var ContentTypes = map[string]string{
+	FormatHAR:        "application/json; charset=utf-8",
+	FormatCurl:       "text/plain; charset=utf-8",
+	FormatHTTPie:     "text/plain; charset=utf-8",
+	FormatRESTClient: "text/plain; charset=utf-8",
+}
+
+// skippedHeaders are request headers not included in exported commands.
+// These are either set by the client tools from the request itself or only
+// apply to the original connection.
+var skippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// shellSafeChars are the characters which do not need to be quoted in POSIX
+// shell command arguments.
+const shellSafeChars string = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,/:=@%+"
+
+// ValidFormat indicates whether the given export format is supported.
+func ValidFormat(format string) bool {
+	for _, supported := range Formats {
+		if format == supported {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write writes the given client requests to w using the specified export
+// format.
+func Write(w io.Writer, format string, requests []capture.Request) error {
+
+	bw := bufio.NewWriter(w)
+
+	var err error
+	switch format {
+	case FormatHAR:
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(NewHAR(requests))
+
+	case FormatCurl:
+		writeCommands(bw, requests, curlCommand)
+
+	case FormatHTTPie:
+		writeCommands(bw, requests, httpieCommand)
+
+	case FormatRESTClient:
+		for _, clientRequest := range requests {
+			writeRESTClientRequest(bw, clientRequest)
+		}
+
+	default:
+		return fmt.Errorf(
+			"unsupported export format %q; supported formats: %s",
+			format,
+			strings.Join(Formats, ", "),
+		)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to export client requests: %w", err)
+	}
+
+	return bw.Flush()
+}
+
+// summary describes the given client request for use as a comment preceding
+// each exported request.
+func summary(clientRequest capture.Request) string {
+	return fmt.Sprintf(
+		"%s %s | Request ID: %s | Received: %s",
+		clientRequest.HTTPMethod,
+		clientRequest.EndpointPath,
+		clientRequest.RequestID,
+		clientRequest.ReceivedAt.Format(time.RFC3339),
+	)
+}
+
+// exportedHeaders calls fn for each header value of the given client request
+// included in exports, sorted by header name.
+func exportedHeaders(clientRequest capture.Request, fn func(name string, value string)) {
+	for _, name := range sortedKeys(clientRequest.Headers) {
+		if skippedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		for _, value := range clientRequest.Headers[name] {
+			fn(name, value)
+		}
+	}
+}
+
+// writeCommands writes a shell command for each of the given client
+// requests, each preceded by a comment describing the request.
+func writeCommands(w io.Writer, requests []capture.Request, command func(capture.Request) []string) {
+	for i, clientRequest := range requests {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "# %s\n", summary(clientRequest))
+		fmt.Fprintln(w, strings.Join(command(clientRequest), " \\\n  "))
+	}
+}
+
+// curlCommand returns the arguments of a curl command which submits the
+// given client request, one line per argument.
+func curlCommand(clientRequest capture.Request) []string {
+
+	args := []string{"curl " + shellQuote(clientRequest.RequestURL)}
+
+	switch clientRequest.HTTPMethod {
+	case http.MethodGet:
+	case http.MethodHead:
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X "+shellQuote(clientRequest.HTTPMethod))
+	}
+
+	exportedHeaders(clientRequest, func(name string, value string) {
+		if value == "" {
+			// curl removes headers specified without a value; the
+			// semicolon form sends the header with an empty value.
+			args = append(args, "-H "+shellQuote(name+";"))
+			return
+		}
+		args = append(args, "-H "+shellQuote(name+": "+value))
+	})
+
+	if clientRequest.Body != "" {
+		args = append(args, "--data-raw "+shellQuote(clientRequest.Body))
+	}
+
+	return args
+}
+
+// httpieCommand returns the arguments of an HTTPie command which submits the
+// given client request, one line per argument.
+func httpieCommand(clientRequest capture.Request) []string {
+
+	args := []string{
+		"http --ignore-stdin " + shellQuote(clientRequest.HTTPMethod) + " " + shellQuote(clientRequest.RequestURL),
+	}
+
+	exportedHeaders(clientRequest, func(name string, value string) {
+		if value == "" {
+			// HTTPie uses the semicolon form for headers with an empty
+			// value.
+			args = append(args, shellQuote(name+";"))
+			return
+		}
+		args = append(args, shellQuote(name+":"+value))
+	})
+
+	if clientRequest.Body != "" {
+		args = append(args, "--raw "+shellQuote(clientRequest.Body))
+	}
+
+	return args
+}
+
+// writeRESTClientRequest writes the given client request using the .http
+// file format. Requests are separated by a ### line, which is also used to
+// describe the request.
+func writeRESTClientRequest(w io.Writer, clientRequest capture.Request) {
+
+	fmt.Fprintf(w, "### %s\n\n", summary(clientRequest))
+
+	// The original protocol version (e.g., HTTP/2.0) is not used as REST
+	// Client chooses the protocol when sending the request.
+	fmt.Fprintf(w, "%s %s HTTP/1.1\n", clientRequest.HTTPMethod, clientRequest.RequestURL)
+
+	exportedHeaders(clientRequest, func(name string, value string) {
+		fmt.Fprintf(w, "%s: %s\n", name, value)
+	})
+
+	if clientRequest.Body != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimRight(clientRequest.Body, "\r\n"))
+	}
+
+	fmt.Fprintln(w)
+}
+
+// shellQuote quotes the given value for use as a single argument in a POSIX
+// shell command. Values without special characters are not quoted.
+func shellQuote(value string) string {
+	if value != "" && strings.Trim(value, shellSafeChars) == "" {
+		return value
+	}
+
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
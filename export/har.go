@@ -0,0 +1,211 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package export
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/config"
+)
+
+// HARNameValue is a HAR 1.2 name/value pair used for headers, query string
+// parameters and cookies.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a HAR 1.2 postData object describing the request body.
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HARNameValue `json:"params"`
+}
+
+// HARRequest is a HAR 1.2 request object.
+type HARRequest struct {
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARContent is a HAR 1.2 content object describing the response body.
+type HARContent struct {
+	MimeType string `json:"mimeType"`
+	Size     int    `json:"size"`
+}
+
+// HARResponse is a HAR 1.2 response object.
+type HARResponse struct {
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	RedirectURL string         `json:"redirectURL"`
+	Comment     string         `json:"comment,omitempty"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	Status      int            `json:"status"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARTimings is a HAR 1.2 timings object.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARVersion is the version of the HAR format used for exported archives.
+const HARVersion string = "1.2"
+
+// HAR is a HAR 1.2 archive containing the exported client requests.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is a HAR 1.2 log object, the root of an archive.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator is a HAR 1.2 creator object identifying the application which
+// created the archive.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a HAR 1.2 entry object describing a single client request.
+type HAREntry struct {
+	Cache           struct{}    `json:"cache"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Comment         string      `json:"comment,omitempty"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Timings         HARTimings  `json:"timings"`
+	Time            float64     `json:"time"`
+}
+
+// harUnknownMimeType is used for the response content type as the response
+// is not recorded.
+const harUnknownMimeType string = "x-unknown"
+
+// NewHAREntry creates a HAR 1.2 entry from the given client request details.
+// Only the request is recorded by this application, so the response object
+// is populated with placeholder values as required by the HAR format.
+func NewHAREntry(clientRequest capture.Request) HAREntry {
+
+	request := HARRequest{
+		Method:      clientRequest.HTTPMethod,
+		URL:         clientRequest.RequestURL,
+		HTTPVersion: clientRequest.Protocol,
+		Cookies:     []HARNameValue{},
+		Headers:     []HARNameValue{},
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(clientRequest.Body),
+	}
+
+	u, err := url.Parse(clientRequest.RequestURL)
+	if err == nil {
+		if u.Host != "" {
+			request.Headers = append(request.Headers, HARNameValue{Name: "Host", Value: u.Host})
+		}
+
+		query := u.Query()
+		for _, name := range sortedKeys(query) {
+			for _, value := range query[name] {
+				request.QueryString = append(request.QueryString, HARNameValue{Name: name, Value: value})
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(clientRequest.Headers) {
+		for _, value := range clientRequest.Headers[name] {
+			request.Headers = append(request.Headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	for _, cookie := range (&http.Request{Header: clientRequest.Headers}).Cookies() {
+		request.Cookies = append(request.Cookies, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	if clientRequest.Body != "" {
+		request.PostData = &HARPostData{
+			MimeType: clientRequest.Headers.Get("Content-Type"),
+			Text:     clientRequest.Body,
+			Params:   []HARNameValue{},
+		}
+	}
+
+	entry := HAREntry{
+		StartedDateTime: clientRequest.ReceivedAt.Format(time.RFC3339Nano),
+		Request:         request,
+		Response: HARResponse{
+			HTTPVersion: clientRequest.Protocol,
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			Content:     HARContent{MimeType: harUnknownMimeType},
+			HeadersSize: -1,
+			BodySize:    -1,
+			Comment:     "Response details are not recorded",
+		},
+	}
+
+	if clientRequest.RequestID != "" {
+		entry.Comment = "Request ID: " + clientRequest.RequestID
+	}
+
+	return entry
+}
+
+// NewHAR creates a HAR 1.2 archive containing an entry for each of the given
+// client requests.
+func NewHAR(requests []capture.Request) HAR {
+
+	archive := HAR{
+		Log: HARLog{
+			Version: HARVersion,
+			Creator: HARCreator{
+				Name:    config.MyAppName,
+				Version: config.Version(),
+			},
+			Entries: make([]HAREntry, 0, len(requests)),
+		},
+	}
+
+	for _, clientRequest := range requests {
+		archive.Log.Entries = append(archive.Log.Entries, NewHAREntry(clientRequest))
+	}
+
+	return archive
+}
+
+// sortedKeys returns the keys of the given map in sorted order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
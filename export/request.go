@@ -0,0 +1,61 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/atc0005/bounce/capture"
+)
+
+// NewRequest creates a request which re-sends the given client request to
+// the target base URL (e.g., https://staging.example.com). The path and
+// query string requested by the client are appended to the target URL, and
+// the method, headers and body are preserved. Headers which only apply to
+// the original connection are omitted, as for exported commands, as are
+// header values redacted from the request history.
+func NewRequest(ctx context.Context, clientRequest capture.Request, target string) (*http.Request, error) {
+
+	targetURL, err := url.Parse(target)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	case targetURL.Scheme == "" || targetURL.Host == "":
+		return nil, fmt.Errorf("invalid target URL %q; scheme and host required", target)
+	case targetURL.RawQuery != "" || targetURL.Fragment != "":
+		return nil, fmt.Errorf("invalid target URL %q; query string and fragment not supported", target)
+	}
+
+	requestURL, err := url.Parse(clientRequest.RequestURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL recorded for client request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		clientRequest.HTTPMethod,
+		strings.TrimSuffix(targetURL.String(), "/")+requestURL.RequestURI(),
+		strings.NewReader(clientRequest.Body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	exportedHeaders(clientRequest, func(name string, value string) {
+		if value == capture.RedactedHeaderValue {
+			return
+		}
+		req.Header.Add(name, value)
+	})
+
+	return req, nil
+}
@@ -7,7 +7,7 @@
 
 module github.com/atc0005/bounce
 
-go 1.20
+go 1.24
 
 // $ go list -m -versions github.com/apex/log
 // github.com/apex/log v1.0.0 v1.1.0 v1.1.1 v1.1.2
@@ -16,12 +16,12 @@ require (
 	github.com/TylerBrock/colorjson v0.0.0-20200706003622-8a50f05110d2
 	github.com/apex/log v1.9.0
 	github.com/atc0005/go-teams-notify/v2 v2.8.0
+	github.com/go-logfmt/logfmt v0.6.0
 	github.com/golang/gddo v0.0.0-20210115222349-20d68f94ee1f
 )
 
 require (
 	github.com/fatih/color v1.15.0 // indirect
-	github.com/go-logfmt/logfmt v0.6.0 // indirect
 	github.com/hokaccha/go-prettyjson v0.0.0-20190818114111-108c894c2c0e // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
@@ -0,0 +1,272 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package access provides types and functions used to restrict access to
+// routes by client IP Address and by credentials (HTTP Basic authentication,
+// static bearer tokens or API keys).
+package access
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+)
+
+// Reasons recorded for rejected requests.
+const (
+	ReasonDenied             string = "denied"
+	ReasonNotAllowed         string = "not-allowed"
+	ReasonMissingCredentials string = "missing-credentials"
+	ReasonInvalidCredentials string = "invalid-credentials"
+)
+
+// Descriptions of the access controls enforced by a Policy.
+const (
+	ControlIPAllowList string = "IP allowlist"
+	ControlIPDenyList  string = "IP denylist"
+	ControlBasicAuth   string = "Basic auth"
+	ControlBearerToken string = "Bearer token"
+	ControlAPIKey      string = "API key"
+)
+
+// Realm is the authentication realm advertised to clients.
+const Realm string = config.MyAppName
+
+// Decision is the outcome of evaluating a request against a Policy.
+type Decision struct {
+
+	// Reason is the reason a request was rejected. This is empty for
+	// permitted requests.
+	Reason string
+
+	// Status is the HTTP status code that should be returned to the client
+	// for a rejected request.
+	Status int
+
+	// Allowed indicates whether the request is permitted.
+	Allowed bool
+}
+
+// Policy is the compiled form of a config.AccessPolicy.
+type Policy struct {
+	rejected     map[string]uint64
+	basicAuth    map[string][sha256.Size]byte
+	apiKeyHeader string
+	allow        []*net.IPNet
+	deny         []*net.IPNet
+	bearerTokens [][sha256.Size]byte
+	apiKeys      [][sha256.Size]byte
+	mu           sync.Mutex
+}
+
+// NewPolicy compiles the given access policy settings.
+func NewPolicy(ap config.AccessPolicy) (*Policy, error) {
+
+	allow, err := clientip.ParseCIDRs(ap.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allow entry: %w", err)
+	}
+
+	deny, err := clientip.ParseCIDRs(ap.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deny entry: %w", err)
+	}
+
+	policy := Policy{
+		allow:        allow,
+		deny:         deny,
+		apiKeyHeader: ap.APIKeyHeader,
+		basicAuth:    make(map[string][sha256.Size]byte, len(ap.BasicAuth)),
+		rejected:     make(map[string]uint64),
+	}
+
+	if policy.apiKeyHeader == "" {
+		policy.apiKeyHeader = config.DefaultAPIKeyHeader
+	}
+
+	// Secrets are hashed so that comparisons are performed against values of
+	// a fixed length, which avoids leaking the length of the secret via
+	// timing differences.
+	for username, password := range ap.BasicAuth {
+		policy.basicAuth[username] = sha256.Sum256([]byte(password))
+	}
+	for _, token := range ap.BearerTokens {
+		policy.bearerTokens = append(policy.bearerTokens, sha256.Sum256([]byte(token)))
+	}
+	for _, key := range ap.APIKeys {
+		policy.apiKeys = append(policy.apiKeys, sha256.Sum256([]byte(key)))
+	}
+
+	return &policy, nil
+}
+
+// Controls provides a list of the access controls enforced by the Policy.
+func (p *Policy) Controls() []string {
+
+	var controls []string
+	if len(p.allow) > 0 {
+		controls = append(controls, ControlIPAllowList)
+	}
+	if len(p.deny) > 0 {
+		controls = append(controls, ControlIPDenyList)
+	}
+	if len(p.basicAuth) > 0 {
+		controls = append(controls, ControlBasicAuth)
+	}
+	if len(p.bearerTokens) > 0 {
+		controls = append(controls, ControlBearerToken)
+	}
+	if len(p.apiKeys) > 0 {
+		controls = append(controls, ControlAPIKey)
+	}
+
+	return controls
+}
+
+// requiresCredentials indicates whether clients are required to provide
+// credentials.
+func (p *Policy) requiresCredentials() bool {
+	return len(p.basicAuth) > 0 || len(p.bearerTokens) > 0 || len(p.apiKeys) > 0
+}
+
+// Check evaluates the request and resolved client IP Address against the
+// Policy. Rejected requests are counted by reason.
+func (p *Policy) Check(r *http.Request, clientIP string) Decision {
+
+	decision := p.check(r, clientIP)
+	if !decision.Allowed {
+		p.mu.Lock()
+		p.rejected[decision.Reason]++
+		p.mu.Unlock()
+	}
+
+	return decision
+}
+
+func (p *Policy) check(r *http.Request, clientIP string) Decision {
+
+	ip := net.ParseIP(clientIP)
+
+	if clientip.Contains(p.deny, ip) {
+		return Decision{Reason: ReasonDenied, Status: http.StatusForbidden}
+	}
+
+	if len(p.allow) > 0 && !clientip.Contains(p.allow, ip) {
+		return Decision{Reason: ReasonNotAllowed, Status: http.StatusForbidden}
+	}
+
+	if !p.requiresCredentials() {
+		return Decision{Allowed: true}
+	}
+
+	var credentialsProvided bool
+
+	if username, password, ok := r.BasicAuth(); ok {
+		credentialsProvided = true
+		if expected, found := p.basicAuth[username]; found && matchDigest(password, expected) {
+			return Decision{Allowed: true}
+		}
+	}
+
+	if token, ok := bearerToken(r); ok {
+		credentialsProvided = true
+		if matchAny(token, p.bearerTokens) {
+			return Decision{Allowed: true}
+		}
+	}
+
+	if key := r.Header.Get(p.apiKeyHeader); key != "" {
+		credentialsProvided = true
+		if matchAny(key, p.apiKeys) {
+			return Decision{Allowed: true}
+		}
+	}
+
+	if !credentialsProvided {
+		return Decision{Reason: ReasonMissingCredentials, Status: http.StatusUnauthorized}
+	}
+
+	return Decision{Reason: ReasonInvalidCredentials, Status: http.StatusUnauthorized}
+}
+
+// Challenge sets the WWW-Authenticate response headers appropriate for the
+// credential types accepted by the Policy.
+func (p *Policy) Challenge(w http.ResponseWriter) {
+	if len(p.basicAuth) > 0 {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", Realm))
+	}
+	if len(p.bearerTokens) > 0 {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", Realm))
+	}
+}
+
+// Rejected provides a snapshot of the number of rejected requests, keyed by
+// reason.
+func (p *Policy) Rejected() map[string]uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := make(map[string]uint64, len(p.rejected))
+	for reason, count := range p.rejected {
+		snapshot[reason] = count
+	}
+
+	return snapshot
+}
+
+// RejectedTotal provides the total number of rejected requests.
+func (p *Policy) RejectedTotal() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var total uint64
+	for _, count := range p.rejected {
+		total += count
+	}
+
+	return total
+}
+
+// bearerToken returns the token provided via the Authorization header using
+// the Bearer scheme, if present.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "bearer "
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// matchDigest performs a constant-time comparison of the given value against
+// the expected digest.
+func matchDigest(value string, expected [sha256.Size]byte) bool {
+	digest := sha256.Sum256([]byte(value))
+	return subtle.ConstantTimeCompare(digest[:], expected[:]) == 1
+}
+
+// matchAny performs a constant-time comparison of the given value against
+// each of the expected digests.
+func matchAny(value string, expected [][sha256.Size]byte) bool {
+	var matched bool
+	for _, digest := range expected {
+		if matchDigest(value, digest) {
+			matched = true
+		}
+	}
+
+	return matched
+}
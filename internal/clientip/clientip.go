@@ -0,0 +1,325 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Sources used to determine a client IP Address. These values are recorded
+// in the Result to indicate where the resolved client IP Address was taken
+// from.
+const (
+	SourceRemoteAddr    string = "RemoteAddr"
+	SourceForwarded     string = "Forwarded"
+	SourceXForwardedFor string = "X-Forwarded-For"
+	SourceXRealIP       string = "X-Real-IP"
+)
+
+// Forwarding headers which may be consulted when the immediate peer is a
+// trusted proxy. Only the header set by the trusted proxies is consulted.
+const (
+	HeaderForwarded     string = "Forwarded"
+	HeaderXForwardedFor string = "X-Forwarded-For"
+	HeaderXRealIP       string = "X-Real-IP"
+)
+
+// ForwardingHeaders is the list of supported forwarding headers.
+var ForwardingHeaders = []string{HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP}
+
+// ValidForwardingHeader indicates whether the given forwarding header name is
+// supported. Header names are case-insensitive.
+func ValidForwardingHeader(name string) bool {
+	return canonicalForwardingHeader(name) != ""
+}
+
+// canonicalForwardingHeader returns the supported forwarding header matching
+// the given header name, or an empty string if not supported.
+func canonicalForwardingHeader(name string) string {
+	for _, header := range ForwardingHeaders {
+		if strings.EqualFold(name, header) {
+			return header
+		}
+	}
+
+	return ""
+}
+
+// Result is the outcome of resolving the client IP Address for a request.
+type Result struct {
+
+	// ClientIP is the resolved IP Address of the client, without a port.
+	ClientIP string
+
+	// Source indicates where ClientIP was taken from.
+	Source string
+
+	// ProxyChain is the full list of addresses recorded for the request in
+	// the order that they were added, starting with the originating client
+	// (as claimed by forwarding headers) and ending with the immediate peer
+	// (RemoteAddr). Entries which could not be parsed as an IP Address are
+	// recorded as-is.
+	ProxyChain []string
+}
+
+// Resolver resolves client IP Addresses for incoming requests, consulting
+// the forwarding header set by trusted proxies only for requests received
+// from those proxies.
+type Resolver struct {
+	trustedProxies []*net.IPNet
+	header         string
+}
+
+// NewResolver creates a new Resolver which trusts the given forwarding
+// header (e.g., X-Forwarded-For) provided by peers within the given list of
+// CIDR ranges. Other forwarding headers are ignored since trusted proxies
+// pass them through from clients as-is. Individual IP Addresses are also
+// accepted and treated as single-host ranges. An empty list results in
+// forwarding headers being ignored.
+func NewResolver(trustedProxies []string, forwardingHeader string) (*Resolver, error) {
+
+	nets, err := ParseCIDRs(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	header := canonicalForwardingHeader(forwardingHeader)
+	if header == "" {
+		return nil, fmt.Errorf(
+			"unsupported forwarding header %q; supported headers: %s",
+			forwardingHeader,
+			strings.Join(ForwardingHeaders, ", "),
+		)
+	}
+
+	return &Resolver{trustedProxies: nets, header: header}, nil
+}
+
+// ParseCIDRs converts the given list of CIDR ranges or individual IP
+// Addresses to a collection of networks. An error is returned for the first
+// invalid entry.
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, entry := range cidrs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP Address %q", entry)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// Contains indicates whether the given IP Address falls within any of the
+// provided networks.
+func Contains(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetIP returns the resolved IP Address of the client responsible for the
+// request.
+func (res *Resolver) GetIP(r *http.Request) string {
+	return res.Resolve(r).ClientIP
+}
+
+// Resolve evaluates the request peer address and, if the peer is a trusted
+// proxy, the configured forwarding header to determine the client IP Address
+// and the chain of proxies the request passed through.
+func (res *Resolver) Resolve(r *http.Request) Result {
+
+	remoteAddr := StripPort(r.RemoteAddr)
+
+	var hops []string
+	var source string
+
+	switch res.header {
+	case HeaderForwarded:
+		hops = parseForwarded(r.Header.Values(HeaderForwarded))
+		source = SourceForwarded
+
+	case HeaderXForwardedFor:
+		hops = splitList(r.Header.Values(HeaderXForwardedFor))
+		source = SourceXForwardedFor
+
+	case HeaderXRealIP:
+		if value := strings.TrimSpace(r.Header.Get(HeaderXRealIP)); value != "" {
+			hops = []string{value}
+		}
+		source = SourceXRealIP
+	}
+
+	chain := make([]string, 0, len(hops)+1)
+	for _, hop := range hops {
+		chain = append(chain, StripPort(hop))
+	}
+	chain = append(chain, remoteAddr)
+
+	result := Result{
+		ClientIP:   remoteAddr,
+		Source:     SourceRemoteAddr,
+		ProxyChain: chain,
+	}
+
+	// Forwarding headers supplied by untrusted peers cannot be relied upon.
+	if len(hops) == 0 || !Contains(res.trustedProxies, net.ParseIP(remoteAddr)) {
+		return result
+	}
+
+	// Walk the chain right-to-left, skipping over trusted proxies. The first
+	// untrusted address is the client. If we encounter an entry that we
+	// cannot parse, then we stop and use the last trusted hop since anything
+	// further left cannot be verified.
+	for i := len(chain) - 2; i >= 0; i-- {
+		ip := net.ParseIP(chain[i])
+		if ip == nil {
+			return result
+		}
+
+		result.ClientIP = chain[i]
+		result.Source = source
+
+		if !Contains(res.trustedProxies, ip) {
+			return result
+		}
+	}
+
+	// Every hop is a trusted proxy; the leftmost entry is the best we have.
+	return result
+}
+
+// StripPort removes an optional port, IPv6 brackets, quotes and zone
+// identifier from the given address. Values which do not contain an IP
+// Address (e.g., "unknown" or obfuscated identifiers) are returned trimmed,
+// but otherwise as-is.
+func StripPort(addr string) string {
+
+	addr = strings.Trim(strings.TrimSpace(addr), `"`)
+
+	switch {
+	case strings.HasPrefix(addr, "["):
+		if end := strings.Index(addr, "]"); end > 0 {
+			addr = addr[1:end]
+		}
+
+	// A single colon indicates a host:port pair. Bare IPv6 addresses have
+	// more than one.
+	case strings.Count(addr, ":") == 1:
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+	}
+
+	if zone := strings.Index(addr, "%"); zone > 0 {
+		addr = addr[:zone]
+	}
+
+	return addr
+}
+
+// splitList flattens one or more comma-separated header values into a list
+// of trimmed, non-empty entries.
+func splitList(values []string) []string {
+	var entries []string
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	return entries
+}
+
+// parseForwarded extracts the "for" parameter from each forwarded-element of
+// the given RFC 7239 Forwarded header values. Elements without a "for"
+// parameter are recorded as "unknown" to preserve their position in the
+// chain.
+func parseForwarded(values []string) []string {
+	var entries []string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			forValue := "unknown"
+			for _, pair := range splitQuoted(element, ';') {
+				key, val, found := strings.Cut(pair, "=")
+				if !found {
+					continue
+				}
+				if strings.EqualFold(strings.TrimSpace(key), "for") {
+					forValue = strings.Trim(strings.TrimSpace(val), `"`)
+				}
+			}
+			entries = append(entries, forValue)
+		}
+	}
+
+	return entries
+}
+
+// splitQuoted splits s on sep, ignoring separators found within
+// double-quoted strings. Empty entries are discarded.
+func splitQuoted(s string, sep rune) []string {
+	var entries []string
+	var inQuotes bool
+	start := 0
+
+	for i, c := range s {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == sep && !inQuotes:
+			if entry := strings.TrimSpace(s[start:i]); entry != "" {
+				entries = append(entries, entry)
+			}
+			start = i + 1
+		}
+	}
+
+	if entry := strings.TrimSpace(s[start:]); entry != "" {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
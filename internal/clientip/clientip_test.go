@@ -0,0 +1,221 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+
+	trusted := []string{"10.0.0.0/8", "2001:db8:ffff::1"}
+
+	tests := []struct {
+		name       string
+		header     string
+		remoteAddr string
+		headers    map[string][]string
+		wantIP     string
+		wantSource string
+		wantChain  []string
+	}{
+		{
+			name:       "no forwarding headers",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "192.0.2.10:51000",
+			wantIP:     "192.0.2.10",
+			wantSource: SourceRemoteAddr,
+			wantChain:  []string{"192.0.2.10"},
+		},
+		{
+			name:       "untrusted peer spoofing X-Forwarded-For",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "192.0.2.10:51000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.5"}},
+			wantIP:     "192.0.2.10",
+			wantSource: SourceRemoteAddr,
+			wantChain:  []string{"203.0.113.5", "192.0.2.10"},
+		},
+		{
+			name:       "trusted peer with client supplied Forwarded header",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"Forwarded": {"for=203.0.113.5"}},
+			wantIP:     "10.0.0.1",
+			wantSource: SourceRemoteAddr,
+			wantChain:  []string{"10.0.0.1"},
+		},
+		{
+			name:       "trusted peer with client supplied X-Real-IP header",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers: map[string][]string{
+				"X-Real-Ip":       {"198.51.100.7"},
+				"X-Forwarded-For": {"203.0.113.5"},
+			},
+			wantIP:     "203.0.113.5",
+			wantSource: SourceXForwardedFor,
+			wantChain:  []string{"203.0.113.5", "10.0.0.1"},
+		},
+		{
+			name:       "trusted peer single hop",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.5:4711"}},
+			wantIP:     "203.0.113.5",
+			wantSource: SourceXForwardedFor,
+			wantChain:  []string{"203.0.113.5", "10.0.0.1"},
+		},
+		{
+			name:       "trusted multi-hop ignores spoofed leftmost entry",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.7, 203.0.113.5, 10.0.0.2"}},
+			wantIP:     "203.0.113.5",
+			wantSource: SourceXForwardedFor,
+			wantChain:  []string{"198.51.100.7", "203.0.113.5", "10.0.0.2", "10.0.0.1"},
+		},
+		{
+			name:       "trusted multi-hop across repeated headers",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.5", "10.0.0.3, 10.0.0.2"}},
+			wantIP:     "203.0.113.5",
+			wantSource: SourceXForwardedFor,
+			wantChain:  []string{"203.0.113.5", "10.0.0.3", "10.0.0.2", "10.0.0.1"},
+		},
+		{
+			name:       "all hops trusted",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			wantIP:     "10.0.0.3",
+			wantSource: SourceXForwardedFor,
+			wantChain:  []string{"10.0.0.3", "10.0.0.2", "10.0.0.1"},
+		},
+		{
+			name:       "unparseable hop stops at last trusted hop",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.5, garbage, 10.0.0.2"}},
+			wantIP:     "10.0.0.2",
+			wantSource: SourceXForwardedFor,
+			wantChain:  []string{"203.0.113.5", "garbage", "10.0.0.2", "10.0.0.1"},
+		},
+		{
+			name:       "unparseable only hop",
+			header:     HeaderXForwardedFor,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"X-Forwarded-For": {"unknown"}},
+			wantIP:     "10.0.0.1",
+			wantSource: SourceRemoteAddr,
+			wantChain:  []string{"unknown", "10.0.0.1"},
+		},
+		{
+			name:       "X-Real-IP from trusted peer",
+			header:     HeaderXRealIP,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"X-Real-Ip": {" 203.0.113.5 "}},
+			wantIP:     "203.0.113.5",
+			wantSource: SourceXRealIP,
+			wantChain:  []string{"203.0.113.5", "10.0.0.1"},
+		},
+		{
+			name:       "X-Real-IP from untrusted peer",
+			header:     HeaderXRealIP,
+			remoteAddr: "192.0.2.10:51000",
+			headers:    map[string][]string{"X-Real-Ip": {"203.0.113.5"}},
+			wantIP:     "192.0.2.10",
+			wantSource: SourceRemoteAddr,
+			wantChain:  []string{"203.0.113.5", "192.0.2.10"},
+		},
+		{
+			name:       "Forwarded quoted IPv6 with port",
+			header:     HeaderForwarded,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`}},
+			wantIP:     "2001:db8::1",
+			wantSource: SourceForwarded,
+			wantChain:  []string{"2001:db8::1", "10.0.0.2", "10.0.0.1"},
+		},
+		{
+			name:       "Forwarded element without for parameter",
+			header:     HeaderForwarded,
+			remoteAddr: "10.0.0.1:51000",
+			headers:    map[string][]string{"Forwarded": {"proto=https;by=10.0.0.1"}},
+			wantIP:     "10.0.0.1",
+			wantSource: SourceRemoteAddr,
+			wantChain:  []string{"unknown", "10.0.0.1"},
+		},
+		{
+			name:       "trusted IPv6 peer",
+			header:     HeaderForwarded,
+			remoteAddr: "[2001:db8:ffff::1]:51000",
+			headers:    map[string][]string{"Forwarded": {"for=203.0.113.5"}},
+			wantIP:     "203.0.113.5",
+			wantSource: SourceForwarded,
+			wantChain:  []string{"203.0.113.5", "2001:db8:ffff::1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			res, err := NewResolver(trusted, tt.header)
+			if err != nil {
+				t.Fatalf("NewResolver() error = %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				r.Header[http.CanonicalHeaderKey(name)] = values
+			}
+
+			got := res.Resolve(r)
+
+			if got.ClientIP != tt.wantIP {
+				t.Errorf("ClientIP = %q, want %q", got.ClientIP, tt.wantIP)
+			}
+			if got.Source != tt.wantSource {
+				t.Errorf("Source = %q, want %q", got.Source, tt.wantSource)
+			}
+			if !slices.Equal(got.ProxyChain, tt.wantChain) {
+				t.Errorf("ProxyChain = %q, want %q", got.ProxyChain, tt.wantChain)
+			}
+		})
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		header         string
+		wantErr        bool
+	}{
+		{name: "valid", trustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}, header: HeaderXForwardedFor},
+		{name: "header is case-insensitive", header: "x-real-ip"},
+		{name: "unsupported header", header: "X-Client-IP", wantErr: true},
+		{name: "invalid CIDR range", trustedProxies: []string{"10.0.0.0/33"}, header: HeaderForwarded, wantErr: true},
+		{name: "invalid IP Address", trustedProxies: []string{"not-an-ip"}, header: HeaderForwarded, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewResolver(tt.trustedProxies, tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewResolver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
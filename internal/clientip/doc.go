@@ -0,0 +1,21 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package clientip provides types and functions used to resolve the IP Address
+of the client responsible for an incoming HTTP request.
+
+Forwarding headers (RFC 7239 Forwarded, X-Forwarded-For or X-Real-IP) are
+only consulted when the immediate peer is a configured trusted proxy. Only
+the header set by the trusted proxies is consulted; the others are passed
+through from the client as-is and cannot be relied upon. The X-Forwarded-For
+and Forwarded header values are evaluated right-to-left, skipping over
+trusted proxies until the first untrusted address is found. This prevents
+clients from spoofing their address by supplying their own forwarding
+headers.
+*/
+package clientip
@@ -12,7 +12,12 @@ package config
 import (
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apex/log"
@@ -22,6 +27,10 @@ import (
 	"github.com/apex/log/handlers/logfmt"
 	"github.com/apex/log/handlers/text"
 
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/listener"
+	"github.com/atc0005/bounce/internal/loghandler"
+
 	goteamsnotify "github.com/atc0005/go-teams-notify/v2"
 )
 
@@ -38,48 +47,151 @@ const MyAppName string = "bounce"
 const MyAppURL string = "https://github.com/atc0005/bounce"
 
 const (
-	portFlagHelp                = "TCP port that this application should listen on for incoming HTTP requests."
-	localIPAddressFlagHelp      = "Local IP Address that this application should listen on for incoming HTTP requests."
-	colorizedJSONFlagHelp       = "Whether JSON output should be colorized."
-	colorizedJSONIndentFlagHelp = "Number of spaces to use when indenting colorized JSON output. Has no effect unless colorized JSON mode is enabled."
-	logLevelFlagHelp            = "Log message priority filter. Log messages with a lower level are ignored."
-	logOutputFlagHelp           = "Log messages are written to this output target"
-	logFormatFlagHelp           = "Log messages are written in this format"
-	webhookURLFlagHelp          = "The Webhook URL provided by a preconfigured Connector. If specified, this application will attempt to send client request details to the Microsoft Teams channel associated with the webhook URL."
-	retriesFlagHelp             = "The number of attempts that this application will make to deliver messages before giving up."
-	retriesDelayFlagHelp        = "The number of seconds that this application will wait before making another delivery attempt."
+	portFlagHelp                  = "TCP port that this application should listen on for incoming HTTP requests."
+	localIPAddressFlagHelp        = "Local IP Address that this application should listen on for incoming HTTP requests."
+	colorizedJSONFlagHelp         = "Whether JSON output should be colorized."
+	colorizedJSONIndentFlagHelp   = "Number of spaces to use when indenting colorized JSON output. Has no effect unless colorized JSON mode is enabled."
+	logLevelFlagHelp              = "Log message priority filter. Log messages with a lower level are ignored."
+	logOutputFlagHelp             = "Log messages are written to this output target. The log format setting is not used for the syslog and journald targets."
+	logFormatFlagHelp             = "Log messages are written in this format"
+	webhookURLFlagHelp            = "The Webhook URL provided by a preconfigured Connector. If specified, this application will attempt to send client request details to the Microsoft Teams channel associated with the webhook URL."
+	retriesFlagHelp               = "The number of attempts that this application will make to deliver messages before giving up."
+	retriesDelayFlagHelp          = "The number of seconds that this application will wait before making another delivery attempt."
+	compressFlagHelp              = "Whether responses should be compressed using gzip for clients which accept it."
+	configFileFlagHelp            = "Path to an optional JSON configuration file used to specify settings not available via command-line flags (e.g., per-route access control)."
+	clientRateLimitFlagHelp       = "Maximum number of requests per minute accepted from each client IP Address for each route. Requests exceeding this limit receive a 429 response. A value of 0 disables this limit."
+	clientRateLimitBurstFlagHelp  = "Maximum burst size for the per-client rate limit. A value of 0 uses the per-client rate limit as the burst size."
+	routeRateLimitFlagHelp        = "Maximum number of requests per minute accepted for each route from all clients combined. Requests exceeding this limit receive a 429 response. A value of 0 disables this limit."
+	routeRateLimitBurstFlagHelp   = "Maximum burst size for the per-route rate limit. A value of 0 uses the per-route rate limit as the burst size."
+	notifyRateLimitFlagHelp       = "Maximum number of notifications per minute generated from client requests. Notifications exceeding this limit are suppressed and reported in a periodic summary notification. A value of 0 disables this limit."
+	notifyRateLimitBurstFlagHelp  = "Maximum burst size for the notification rate limit. A value of 0 uses the notification rate limit as the burst size."
+	logFileFlagHelp               = "Path to a file that log messages are written to instead of the log output target. May be the same file as the output file."
+	outputFileFlagHelp            = "Path to a file that captured client request details are written to instead of stdout. May be the same file as the log file."
+	rotateMaxSizeFlagHelp         = "Maximum size in megabytes of the log and output files before they are rotated. A value of 0 disables size-based rotation."
+	rotateMaxAgeFlagHelp          = "Maximum number of hours that the log and output files are written to before they are rotated. A value of 0 disables age-based rotation."
+	rotateMaxBackupsFlagHelp      = "Maximum number of rotated log and output files to retain. A value of 0 retains all rotated files."
+	rotateCompressFlagHelp        = "Whether rotated log and output files should be compressed using gzip."
+	syslogAddressFlagHelp         = "Address of the syslog daemon used when syslog is chosen as the log output target, in the form unix:///path/to/socket, udp://host:port or tcp://host:port."
+	syslogFacilityFlagHelp        = "Syslog facility used when syslog is chosen as the log output target."
+	responseFormatFlagHelp        = "Format used for client request details returned to clients which do not request a specific format via the format query parameter or Accept header."
+	outputFormatFlagHelp          = "Format used for client request details written to stdout or the output file."
+	echoTemplateFlagHelp          = "Path to a text/template file used in place of the built-in template when echoing client request details in the text format."
+	indexTemplateFlagHelp         = "Path to an html/template file used in place of the built-in index page template."
+	readHeaderTimeoutFlagHelp     = "Number of seconds allowed to read request headers. A value of 0 disables this timeout."
+	readTimeoutFlagHelp           = "Number of seconds allowed to read the entire request, including the body. A value of 0 disables this timeout."
+	writeTimeoutFlagHelp          = "Number of seconds allowed to write the response, measured from the end of the request headers. A value of 0 disables this timeout."
+	idleTimeoutFlagHelp           = "Number of seconds to wait for the next request on a keep-alive connection. A value of 0 uses the read timeout instead."
+	maxHeaderBytesFlagHelp        = "Maximum size in bytes of request headers, including the request line."
+	maxConnectionsFlagHelp        = "Maximum number of concurrent client connections. Further connections wait until an existing connection is closed. A value of 0 disables this limit."
+	shutdownTimeoutFlagHelp       = "Number of seconds that in-progress requests are given to complete during shutdown before their connections are closed."
+	notifyShutdownTimeoutFlagHelp = "Number of seconds to wait for each notifier to stop during shutdown."
+	notifyFlushFlagHelp           = "Whether pending notifications should be sent during shutdown instead of being discarded."
+	notifyFlushTimeoutFlagHelp    = "Number of seconds that pending notifications are given to be sent during shutdown if notification flushing is enabled. Notifications not sent within this time are discarded and logged."
+	templateReloadFlagHelp        = "Whether template files are reloaded automatically when they change."
+	historySizeFlagHelp           = "Maximum number of captured client requests retained in memory and made available via the history API. A value of 0 disables request history."
+	readyFailureThresholdFlagHelp = "Number of consecutive failed notification attempts after which the readiness endpoint reports this application as not ready. A value of 0 disables this check."
+	listenFlagHelp                = "Address that this application should listen on for incoming HTTP requests, in the form host:port, http://host:port, https://host:port, unix:///path/to/socket, systemd or systemd:name. IPv6 addresses are given in brackets (e.g., [::1]:8000). May be repeated or provided as a comma-separated list. If not specified, the IP Address and port settings are used."
+	adminListenFlagHelp           = "Address that the admin API should listen on, in the same forms as the listen flag (except systemd). May be repeated or provided as a comma-separated list. If specified, the admin API is only available via these addresses; otherwise it is available via the /admin/api path of the other listeners. The admin API also requires an admin access policy in the configuration file."
+	h2cFlagHelp                   = "Whether HTTP/2 without TLS (h2c) is accepted by plain HTTP listeners. Clients must use HTTP/2 with prior knowledge; upgrading HTTP/1.1 connections is not supported."
+	tlsCertFileFlagHelp           = "Path to a PEM encoded certificate (chain) file used by https listeners."
+	tlsKeyFileFlagHelp            = "Path to a PEM encoded private key file used by https listeners."
+	websocketDelayFlagHelp        = "Number of milliseconds to wait before echoing each WebSocket message. May be overridden per connection using the delay query parameter."
+	websocketCloseAfterFlagHelp   = "Number of WebSocket messages echoed before the server closes the connection. May be overridden per connection using the close_after query parameter. A value of 0 disables this limit."
+	websocketPingIntervalFlagHelp = "Number of seconds between ping frames sent to WebSocket clients. May be overridden per connection using the ping_interval query parameter. A value of 0 disables pings."
+	websocketMaxMessageFlagHelp   = "Maximum size in bytes of a WebSocket message received from a client. Connections sending larger messages are closed."
+	sseIntervalFlagHelp           = "Number of milliseconds between Server-Sent Events sent by the events endpoint (minimum 100). May be overridden per request using the interval query parameter."
+	sseEventFlagHelp              = "Event type of Server-Sent Events generated from the data template. May be overridden per request using the event query parameter. If empty, clients receive the events as message events."
+	sseRetryFlagHelp              = "Reconnection time in milliseconds requested from Server-Sent Events clients. May be overridden per request using the retry query parameter. A value of 0 omits the reconnection time."
+	sseDisconnectAfterFlagHelp    = "Number of Server-Sent Events sent before the server disconnects the client. May be overridden per request using the disconnect_after query parameter. A value of 0 disables disconnects."
+	sseDisconnectModeFlagHelp     = "How the server disconnects Server-Sent Events clients: close ends the response normally, reset aborts the connection. May be overridden per request using the disconnect_mode query parameter."
+	sseDataTemplateFlagHelp       = "Path to a text/template file used in place of the built-in template to generate the data of each Server-Sent Event."
+	sseEventsFileFlagHelp         = "Path to a file of recorded Server-Sent Events in the text/event-stream format which are replayed in place of events generated from the data template."
+	trustedProxyFlagHelp          = "IP Address or CIDR range of a trusted reverse proxy. The forwarding header set by trusted proxies (see forwarded-header) is only used to determine the client IP Address for requests received from a trusted proxy. May be repeated or provided as a comma-separated list."
+	forwardedHeaderFlagHelp       = "Forwarding header set by the trusted proxies (Forwarded, X-Forwarded-For or X-Real-IP). Only this header is used to determine the client IP Address; the others are ignored since trusted proxies pass them through from clients as-is."
 )
 
 // Default flag settings if not overridden by user input
 const (
-	defaultLocalTCPPort        int    = 8000
-	defaultLocalIP             string = "localhost"
-	defaultColorizedJSON       bool   = false
-	defaultColorizedJSONIntent int    = 2
-	defaultLogLevel            string = "info"
-	defaultLogOutput           string = "stdout"
-	defaultLogFormat           string = "text"
-	defaultWebhookURL          string = ""
-	defaultRetries             int    = 2
-	defaultRetriesDelay        int    = 2
+	defaultLocalTCPPort          int    = 8000
+	defaultLocalIP               string = "localhost"
+	defaultColorizedJSON         bool   = false
+	defaultColorizedJSONIntent   int    = 2
+	defaultLogLevel              string = "info"
+	defaultLogOutput             string = "stdout"
+	defaultLogFormat             string = "text"
+	defaultWebhookURL            string = ""
+	defaultRetries               int    = 2
+	defaultRetriesDelay          int    = 2
+	defaultConfigFile            string = ""
+	defaultForwardedHeader       string = clientip.HeaderXForwardedFor
+	defaultClientRateLimit       int    = 0
+	defaultClientRateLimitBurst  int    = 0
+	defaultRouteRateLimit        int    = 0
+	defaultRouteRateLimitBurst   int    = 0
+	defaultNotifyRateLimit       int    = 0
+	defaultNotifyRateLimitBurst  int    = 0
+	defaultCompress              bool   = false
+	defaultHistorySize           int    = 0
+	defaultReadyFailureThreshold int    = 5
+	defaultEchoTemplate          string = ""
+	defaultIndexTemplate         string = ""
+	defaultTemplateReload        bool   = false
+	defaultNotifyFlush           bool   = false
+	defaultResponseFormat        string = EchoFormatText
+	defaultOutputFormat          string = EchoFormatText
+	defaultSyslogAddress         string = loghandler.DefaultSyslogAddress
+	defaultSyslogFacility        string = "daemon"
+	defaultLogFile               string = ""
+	defaultOutputFile            string = ""
+	defaultRotateMaxSize         int    = 100
+	defaultRotateMaxAge          int    = 0
+	defaultRotateMaxBackups      int    = 0
+	defaultRotateCompress        bool   = false
+	defaultTLSCertFile           string = ""
+	defaultTLSKeyFile            string = ""
+	defaultH2C                   bool   = false
+	defaultWebSocketDelay        int    = 0
+	defaultWebSocketCloseAfter   int    = 0
+	defaultWebSocketPing         int    = 0
+	defaultWebSocketMaxMessage   int    = 1048576
+	defaultSSEInterval           int    = 1000
+	defaultSSEEvent              string = ""
+	defaultSSERetry              int    = 0
+	defaultSSEDisconnectAfter    int    = 0
+	defaultSSEDisconnectMode     string = SSEDisconnectModeClose
+	defaultSSEDataTemplate       string = ""
+	defaultSSEEventsFile         string = ""
 )
 
-// Timeout settings applied to our instance of http.Server
+// Default timeout (in seconds) and limit settings applied to our instance of
+// http.Server. The timeout settings are the "default" settings provided by
+// Simon Frey which override the default "wait forever" configuration.
 const (
-	HTTPServerReadHeaderTimeout time.Duration = 20 * time.Second
-	HTTPServerReadTimeout       time.Duration = 1 * time.Minute
-	HTTPServerWriteTimeout      time.Duration = 2 * time.Minute
+	defaultReadHeaderTimeout int = 20
+	defaultReadTimeout       int = 60
+	defaultWriteTimeout      int = 120
+	defaultIdleTimeout       int = 120
+	defaultMaxHeaderBytes    int = http.DefaultMaxHeaderBytes
+	defaultMaxConnections    int = 0
 )
 
-// HTTPServerShutdownTimeout is used by the graceful shutdown process to
-// control how long the shutdown process should wait before forcefully
-// terminating.
-const HTTPServerShutdownTimeout time.Duration = 30 * time.Second
+// minMaxHeaderBytes is the smallest permitted value for the maximum size of
+// request headers; smaller values would reject many legitimate requests.
+const minMaxHeaderBytes int = 4096
 
-// NotifyMgrServicesShutdownTimeout is used by the NotifyMgr to determine how
-// long it should wait for results from each notifier or notifier "service"
-// before continuing on with the shutdown process.
-const NotifyMgrServicesShutdownTimeout time.Duration = 2 * time.Second
+// defaultHTTPServerShutdownTimeout is used by the graceful shutdown process
+// to control how many seconds the shutdown process should wait before
+// forcefully terminating.
+const defaultHTTPServerShutdownTimeout int = 30
+
+// defaultNotifyMgrServicesShutdownTimeout is used by the NotifyMgr to
+// determine how many seconds it should wait for results from each notifier
+// or notifier "service" before continuing on with the shutdown process.
+const defaultNotifyMgrServicesShutdownTimeout int = 2
+
+// defaultNotifyFlushTimeout is the number of seconds that pending
+// notifications are given to be sent during shutdown if requested.
+const defaultNotifyFlushTimeout int = 30
 
 // Timing-related settings (delays, timeouts) used by our notification manager
 // and child goroutines to concurrently process notification requests.
@@ -122,6 +234,27 @@ const (
 	NotifyMgrEmailNotificationDelay time.Duration = 5 * time.Second
 )
 
+// NotifyRateLimitSummaryQuietPeriod is how long notifications must go
+// without being suppressed by the notification rate limit before a summary of
+// the suppressed notifications is sent.
+const NotifyRateLimitSummaryQuietPeriod time.Duration = 30 * time.Second
+
+// NotifyRateLimitSummaryMaxDelay is the longest that a summary of
+// notifications suppressed by the notification rate limit is held back while
+// suppression is ongoing. This ensures that a sustained flood still results
+// in periodic summaries.
+const NotifyRateLimitSummaryMaxDelay time.Duration = 5 * time.Minute
+
+// NotifyRateLimitSummaryCheckInterval is how often the notification manager
+// checks whether a summary of suppressed notifications is due.
+const NotifyRateLimitSummaryCheckInterval time.Duration = 5 * time.Second
+
+// NotifyMgrHeartbeatTimeout is how long the notification manager may go
+// without completing an iteration of its main loop before the readiness
+// endpoint reports it as unresponsive. The main loop runs at least once every
+// NotifyRateLimitSummaryCheckInterval.
+const NotifyMgrHeartbeatTimeout time.Duration = 30 * time.Second
+
 // NotifyMgrQueueDepth is the number of items allowed into the queue/channel
 // at one time. Senders with items for the notification "pipeline" that do not
 // fit within the allocated space will block until space in the queue opens.
@@ -204,6 +337,43 @@ const (
 	LogFormatDiscard string = "discard"
 )
 
+// Formats used to present client request details.
+const (
+
+	// EchoFormatText is the human-readable plain text format.
+	EchoFormatText string = "text"
+
+	// EchoFormatJSON is a JSON document mirroring the recorded client
+	// request details.
+	EchoFormatJSON string = "json"
+
+	// EchoFormatYAML is a YAML document mirroring the recorded client
+	// request details.
+	EchoFormatYAML string = "yaml"
+
+	// EchoFormatHAR is a HTTP Archive (HAR) 1.2 entry for the client
+	// request.
+	EchoFormatHAR string = "har"
+)
+
+// EchoFormats is the list of supported formats for client request details.
+var EchoFormats = []string{EchoFormatText, EchoFormatJSON, EchoFormatYAML, EchoFormatHAR}
+
+// Modes used to disconnect Server-Sent Events clients.
+const (
+
+	// SSEDisconnectModeClose ends the event stream response normally.
+	SSEDisconnectModeClose string = "close"
+
+	// SSEDisconnectModeReset aborts the connection without ending the event
+	// stream response.
+	SSEDisconnectModeReset string = "reset"
+)
+
+// MinSSEInterval is the minimum number of milliseconds between Server-Sent
+// Events. This prevents event streams from being sent without delay.
+const MinSSEInterval int = 100
+
 const (
 
 	// LogOutputStdout represents os.Stdout
@@ -211,6 +381,14 @@ const (
 
 	// LogOutputStderr represents os.Stderr
 	LogOutputStderr string = "stderr"
+
+	// LogOutputSyslog represents a local or remote syslog daemon. The log
+	// format setting is not used.
+	LogOutputSyslog string = "syslog"
+
+	// LogOutputJournald represents the systemd journal. The log format
+	// setting is not used.
+	LogOutputJournald string = "journald"
 )
 
 // MessageTrailer generates a branded "footer" for use with notifications.
@@ -224,6 +402,11 @@ func MessageTrailer() string {
 	)
 }
 
+// Version returns the version of this application.
+func Version() string {
+	return version
+}
+
 // Branding is responsible for emitting application name, version and origin
 func Branding() string {
 	return fmt.Sprintf("\n%s %s\n%s\n\n", MyAppName, version, MyAppURL)
@@ -250,10 +433,48 @@ func Usage(flagSet *flag.FlagSet) func() {
 // command-line flags
 type Config struct {
 
+	// AccessControl is a collection of access policies keyed by route name.
+	// This setting is only available via the configuration file.
+	AccessControl map[string]AccessPolicy
+
+	// RateLimits is a collection of rate limit settings keyed by route name,
+	// overriding DefaultRateLimit. This setting is only available via the
+	// configuration file.
+	RateLimits map[string]RateLimit
+
+	// CORS is a collection of CORS policies keyed by route name. This
+	// setting is only available via the configuration file.
+	CORS map[string]CORSPolicy
+
+	// AdminAccess is the access policy protecting the admin API. The admin
+	// API is disabled if no policy is specified. This setting is only
+	// available via the configuration file.
+	AdminAccess *AccessPolicy
+
+	// Faults is a collection of fault injection settings keyed by route
+	// name. Settings may also be changed at runtime via the admin API. This
+	// setting is only available via the configuration file.
+	Faults map[string]FaultInjection
+
+	// Routes is a collection of user-defined echo routes. This setting is
+	// only available via the configuration file.
+	Routes []RouteConfig
+
 	// LocalIPAddress is the IP Address that this application should listen on
 	// for incoming requests
 	LocalIPAddress string
 
+	// ConfigFile is the optional path to a JSON configuration file.
+	ConfigFile string
+
+	// TLSCertFile is the path to the certificate file used by https
+	// listeners.
+	TLSCertFile string
+
+	// TLSKeyFile is the path to the private key file used by https
+	// listeners.
+	TLSKeyFile string
+
 	// LogLevel is the chosen logging level
 	LogLevel string
 
@@ -261,6 +482,54 @@ type Config struct {
 	// FIXME: Needs better description
 	LogOutput string
 
+	// SyslogAddress is the address of the syslog daemon used when LogOutput
+	// is set to syslog.
+	SyslogAddress string
+
+	// SyslogFacility is the syslog facility used when LogOutput is set to
+	// syslog.
+	SyslogFacility string
+
+	// ResponseFormat is the format used for client request details returned
+	// to clients which do not request a specific format.
+	ResponseFormat string
+
+	// OutputFormat is the format used for client request details written to
+	// stdout or OutputFile.
+	OutputFormat string
+
+	// EchoTemplate is the optional path to a text/template file used in
+	// place of the built-in echo template.
+	EchoTemplate string
+
+	// IndexTemplate is the optional path to an html/template file used in
+	// place of the built-in index page template.
+	IndexTemplate string
+
+	// SSEEvent is the event type of Server-Sent Events generated from the
+	// data template.
+	SSEEvent string
+
+	// SSEDisconnectMode controls how Server-Sent Events clients are
+	// disconnected.
+	SSEDisconnectMode string
+
+	// SSEDataTemplate is the optional path to a text/template file used to
+	// generate the data of each Server-Sent Event.
+	SSEDataTemplate string
+
+	// SSEEventsFile is the optional path to a file of recorded Server-Sent
+	// Events which are replayed in place of generated events.
+	SSEEventsFile string
+
+	// LogFile is the optional path to a file that log messages are written
+	// to instead of LogOutput.
+	LogFile string
+
+	// OutputFile is the optional path to a file that captured client request
+	// details are written to instead of stdout.
+	OutputFile string
+
 	// LogFormat controls which output format is used for log messages
 	// generated by this application. This value is from a smaller subset
 	// of the formats supported by the third-party leveled-logging package
@@ -275,6 +544,24 @@ type Config struct {
 	// channel that you wish to submit messages to using this application.
 	WebhookURL string
 
+	// TrustedProxies is the list of IP Addresses or CIDR ranges for reverse
+	// proxies whose forwarding headers are trusted when determining the
+	// client IP Address for a request.
+	TrustedProxies multiValueStringFlag
+
+	// ForwardedHeader is the forwarding header (e.g., X-Forwarded-For) set
+	// by the trusted proxies. Other forwarding headers are ignored.
+	ForwardedHeader string
+
+	// Listen is the list of addresses that this application should listen
+	// on for incoming requests. LocalIPAddress and LocalTCPPort are used if
+	// no addresses are specified.
+	Listen multiValueStringFlag
+
+	// AdminListen is the list of addresses that the admin API should listen
+	// on. If empty, the admin API is served by the Listen addresses.
+	AdminListen multiValueStringFlag
+
 	// Retries is the number of attempts that this application will make
 	// to deliver messages before giving up.
 	Retries int
@@ -286,6 +573,102 @@ type Config struct {
 	// incoming requests
 	LocalTCPPort int
 
+	// DefaultRateLimit is the rate limit applied to routes without rate limit
+	// settings of their own in the configuration file.
+	DefaultRateLimit RateLimit
+
+	// NotifyRateLimit is the maximum number of notifications per minute
+	// generated from client requests. A value of 0 disables the limit.
+	NotifyRateLimit int
+
+	// NotifyRateLimitBurst is the maximum burst size for NotifyRateLimit.
+	NotifyRateLimitBurst int
+
+	// HistorySize is the maximum number of captured client requests retained
+	// in memory. A value of 0 disables request history.
+	HistorySize int
+
+	// ReadyFailureThreshold is the number of consecutive failed notification
+	// attempts after which this application is reported as not ready. A
+	// value of 0 disables this check.
+	ReadyFailureThreshold int
+
+	// ReadHeaderTimeout is the number of seconds allowed to read request
+	// headers. A value of 0 disables the timeout.
+	ReadHeaderTimeout int
+
+	// ReadTimeout is the number of seconds allowed to read the entire
+	// request. A value of 0 disables the timeout.
+	ReadTimeout int
+
+	// WriteTimeout is the number of seconds allowed to write the response. A
+	// value of 0 disables the timeout.
+	WriteTimeout int
+
+	// IdleTimeout is the number of seconds to wait for the next request on a
+	// keep-alive connection. A value of 0 uses ReadTimeout instead.
+	IdleTimeout int
+
+	// MaxHeaderBytes is the maximum size in bytes of request headers.
+	MaxHeaderBytes int
+
+	// MaxConnections is the maximum number of concurrent client connections.
+	// A value of 0 disables the limit.
+	MaxConnections int
+
+	// WebSocketDelay is the number of milliseconds to wait before echoing
+	// each WebSocket message.
+	WebSocketDelay int
+
+	// WebSocketCloseAfter is the number of WebSocket messages echoed before
+	// the connection is closed. A value of 0 disables the limit.
+	WebSocketCloseAfter int
+
+	// WebSocketPingInterval is the number of seconds between ping frames
+	// sent to WebSocket clients. A value of 0 disables pings.
+	WebSocketPingInterval int
+
+	// WebSocketMaxMessageSize is the maximum size in bytes of a WebSocket
+	// message received from a client.
+	WebSocketMaxMessageSize int
+
+	// SSEInterval is the number of milliseconds between Server-Sent Events.
+	SSEInterval int
+
+	// SSERetry is the reconnection time in milliseconds requested from
+	// Server-Sent Events clients. A value of 0 omits the reconnection time.
+	SSERetry int
+
+	// SSEDisconnectAfter is the number of Server-Sent Events sent before the
+	// client is disconnected. A value of 0 disables disconnects.
+	SSEDisconnectAfter int
+
+	// ShutdownTimeout is the number of seconds that in-progress requests are
+	// given to complete during shutdown.
+	ShutdownTimeout int
+
+	// NotifyShutdownTimeout is the number of seconds to wait for each
+	// notifier to stop during shutdown.
+	NotifyShutdownTimeout int
+
+	// NotifyFlushTimeout is the number of seconds that pending notifications
+	// are given to be sent during shutdown if NotifyFlush is enabled.
+	NotifyFlushTimeout int
+
+	// RotateMaxSize is the maximum size in megabytes of LogFile and
+	// OutputFile before they are rotated. A value of 0 disables size-based
+	// rotation.
+	RotateMaxSize int
+
+	// RotateMaxAge is the maximum number of hours that LogFile and OutputFile
+	// are written to before they are rotated. A value of 0 disables age-based
+	// rotation.
+	RotateMaxAge int
+
+	// RotateMaxBackups is the maximum number of rotated files to retain. A
+	// value of 0 retains all rotated files.
+	RotateMaxBackups int
+
 	// ColorizedJSONIndent controls how many spaces are used when indenting
 	// colorized JSON output. If ColorizedJSON is not enabled, this setting
 	// has no effect.
@@ -295,30 +678,156 @@ type Config struct {
 	// Coloring the output could aid in in quick visual evaluation of incoming
 	// payloads
 	ColorizedJSON bool
+
+	// Compress indicates whether responses should be compressed using gzip
+	// for clients which accept it.
+	Compress bool
+
+	// RotateCompress indicates whether rotated files are compressed using
+	// gzip.
+	RotateCompress bool
+
+	// NotifyFlush indicates whether pending notifications are sent during
+	// shutdown instead of being discarded.
+	NotifyFlush bool
+
+	// H2C indicates whether HTTP/2 without TLS (h2c) is accepted by plain
+	// HTTP listeners.
+	H2C bool
+
+	// TemplateReload indicates whether EchoTemplate and IndexTemplate are
+	// reloaded automatically when they change.
+	TemplateReload bool
 }
 
 func (c *Config) String() string {
 	return fmt.Sprintf(
 		"LocalTCPPort: %d, "+
 			"LocalIPAddress: %s, "+
+			"Listen: %v, "+
+			"AdminListen: %v, "+
+			"TLSCertFile: %q, "+
+			"TLSKeyFile: %q, "+
+			"H2C: %t, "+
 			"ColorizedJSON: %t, "+
 			"ColorizedJSONIndent: %d, "+
 			"LogLevel: %s, "+
 			"LogOutput: %s, "+
 			"LogFormat: %s, "+
+			"SyslogAddress: %s, "+
+			"SyslogFacility: %s, "+
 			"WebhookURL: %s, "+
 			"Retries: %d, "+
-			"RetriesDelay: %d",
+			"RetriesDelay: %d, "+
+			"TrustedProxies: %v, "+
+			"ForwardedHeader: %s, "+
+			"ConfigFile: %q, "+
+			"AccessControl: %d policies, "+
+			"DefaultRateLimit: %+v, "+
+			"RateLimits: %d routes, "+
+			"Routes: %d user-defined, "+
+			"CORS: %d policies, "+
+			"Faults: %d routes, "+
+			"Compress: %t, "+
+			"NotifyRateLimit: %d, "+
+			"NotifyRateLimitBurst: %d, "+
+			"HistorySize: %d, "+
+			"ReadyFailureThreshold: %d, "+
+			"ReadHeaderTimeout: %d, "+
+			"ReadTimeout: %d, "+
+			"WriteTimeout: %d, "+
+			"IdleTimeout: %d, "+
+			"MaxHeaderBytes: %d, "+
+			"MaxConnections: %d, "+
+			"WebSocketDelay: %d, "+
+			"WebSocketCloseAfter: %d, "+
+			"WebSocketPingInterval: %d, "+
+			"WebSocketMaxMessageSize: %d, "+
+			"SSEInterval: %d, "+
+			"SSEEvent: %q, "+
+			"SSERetry: %d, "+
+			"SSEDisconnectAfter: %d, "+
+			"SSEDisconnectMode: %s, "+
+			"SSEDataTemplate: %q, "+
+			"SSEEventsFile: %q, "+
+			"ShutdownTimeout: %d, "+
+			"NotifyShutdownTimeout: %d, "+
+			"NotifyFlush: %t, "+
+			"NotifyFlushTimeout: %d, "+
+			"ResponseFormat: %s, "+
+			"OutputFormat: %s, "+
+			"EchoTemplate: %q, "+
+			"IndexTemplate: %q, "+
+			"TemplateReload: %t, "+
+			"LogFile: %q, "+
+			"OutputFile: %q, "+
+			"RotateMaxSize: %d, "+
+			"RotateMaxAge: %d, "+
+			"RotateMaxBackups: %d, "+
+			"RotateCompress: %t",
 		c.LocalTCPPort,
 		c.LocalIPAddress,
+		c.Listen.String(),
+		c.AdminListen.String(),
+		c.TLSCertFile,
+		c.TLSKeyFile,
+		c.H2C,
 		c.ColorizedJSON,
 		c.ColorizedJSONIndent,
 		c.LogLevel,
 		c.LogOutput,
 		c.LogFormat,
+		c.SyslogAddress,
+		c.SyslogFacility,
 		c.WebhookURL,
 		c.Retries,
 		c.RetriesDelay,
+		c.TrustedProxies.String(),
+		c.ForwardedHeader,
+		c.ConfigFile,
+		len(c.AccessControl),
+		c.DefaultRateLimit,
+		len(c.RateLimits),
+		len(c.Routes),
+		len(c.CORS),
+		len(c.Faults),
+		c.Compress,
+		c.NotifyRateLimit,
+		c.NotifyRateLimitBurst,
+		c.HistorySize,
+		c.ReadyFailureThreshold,
+		c.ReadHeaderTimeout,
+		c.ReadTimeout,
+		c.WriteTimeout,
+		c.IdleTimeout,
+		c.MaxHeaderBytes,
+		c.MaxConnections,
+		c.WebSocketDelay,
+		c.WebSocketCloseAfter,
+		c.WebSocketPingInterval,
+		c.WebSocketMaxMessageSize,
+		c.SSEInterval,
+		c.SSEEvent,
+		c.SSERetry,
+		c.SSEDisconnectAfter,
+		c.SSEDisconnectMode,
+		c.SSEDataTemplate,
+		c.SSEEventsFile,
+		c.ShutdownTimeout,
+		c.NotifyShutdownTimeout,
+		c.NotifyFlush,
+		c.NotifyFlushTimeout,
+		c.ResponseFormat,
+		c.OutputFormat,
+		c.EchoTemplate,
+		c.IndexTemplate,
+		c.TemplateReload,
+		c.LogFile,
+		c.OutputFile,
+		c.RotateMaxSize,
+		c.RotateMaxAge,
+		c.RotateMaxBackups,
+		c.RotateCompress,
 	)
 }
 
@@ -338,6 +847,191 @@ func (c Config) NotifyTeams() bool {
 
 }
 
+// ListenerSpecs returns the listeners that this application should open.
+// Listen is assumed to have been validated.
+func (c Config) ListenerSpecs() []listener.Spec {
+
+	if len(c.Listen) == 0 {
+		return []listener.Spec{{
+			Kind:    listener.KindTCP,
+			Address: net.JoinHostPort(c.LocalIPAddress, strconv.Itoa(c.LocalTCPPort)),
+		}}
+	}
+
+	specs := make([]listener.Spec, 0, len(c.Listen))
+	for _, addr := range c.Listen {
+		spec, err := listener.Parse(addr)
+		if err != nil {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs
+}
+
+// AdminListenerSpecs returns the listener specifications for the admin API.
+// An empty list indicates that the admin API is served by the ListenerSpecs
+// listeners. AdminListen is assumed to have been validated.
+func (c Config) AdminListenerSpecs() []listener.Spec {
+
+	specs := make([]listener.Spec, 0, len(c.AdminListen))
+	for _, addr := range c.AdminListen {
+		spec, err := listener.Parse(addr)
+		if err != nil {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs
+}
+
+// RedactedValue replaces secrets in the output of Redacted.
+const RedactedValue string = "REDACTED"
+
+// Redacted returns a copy of the Config with secrets (e.g., the webhook URL
+// and access policy credentials) replaced by RedactedValue so that the
+// configuration may be displayed safely.
+func (c Config) Redacted() Config {
+
+	if c.WebhookURL != "" {
+		c.WebhookURL = RedactedValue
+	}
+
+	if c.AccessControl != nil {
+		policies := make(map[string]AccessPolicy, len(c.AccessControl))
+		for routeName, ap := range c.AccessControl {
+			policies[routeName] = ap.redacted()
+		}
+		c.AccessControl = policies
+	}
+
+	if c.AdminAccess != nil {
+		ap := c.AdminAccess.redacted()
+		c.AdminAccess = &ap
+	}
+
+	return c
+}
+
+// redacted returns a copy of the AccessPolicy with credentials replaced by
+// RedactedValue. Basic auth usernames are retained.
+func (ap AccessPolicy) redacted() AccessPolicy {
+
+	if ap.BasicAuth != nil {
+		basicAuth := make(map[string]string, len(ap.BasicAuth))
+		for username := range ap.BasicAuth {
+			basicAuth[username] = RedactedValue
+		}
+		ap.BasicAuth = basicAuth
+	}
+
+	redactAll := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		redacted := make([]string, len(values))
+		for i := range redacted {
+			redacted[i] = RedactedValue
+		}
+		return redacted
+	}
+	ap.BearerTokens = redactAll(ap.BearerTokens)
+	ap.APIKeys = redactAll(ap.APIKeys)
+
+	return ap
+}
+
+// APIKeyHeaders returns the request headers used to provide API keys by the
+// configured access policies, including the admin API access policy.
+func (c Config) APIKeyHeaders() []string {
+
+	headers := []string{DefaultAPIKeyHeader}
+
+	policies := make([]AccessPolicy, 0, len(c.AccessControl)+1)
+	for _, ap := range c.AccessControl {
+		policies = append(policies, ap)
+	}
+	if c.AdminAccess != nil {
+		policies = append(policies, *c.AdminAccess)
+	}
+
+	for _, ap := range policies {
+		if ap.APIKeyHeader != "" {
+			headers = append(headers, ap.APIKeyHeader)
+		}
+	}
+
+	return headers
+}
+
+// AdminEnabled indicates whether the admin API is enabled.
+func (c Config) AdminEnabled() bool {
+	return c.AdminAccess != nil
+}
+
+// HTTPServerReadHeaderTimeout is the amount of time allowed to read request
+// headers.
+func (c Config) HTTPServerReadHeaderTimeout() time.Duration {
+	return time.Duration(c.ReadHeaderTimeout) * time.Second
+}
+
+// HTTPServerReadTimeout is the amount of time allowed to read the entire
+// request, including the body.
+func (c Config) HTTPServerReadTimeout() time.Duration {
+	return time.Duration(c.ReadTimeout) * time.Second
+}
+
+// HTTPServerWriteTimeout is the amount of time allowed to write the
+// response.
+func (c Config) HTTPServerWriteTimeout() time.Duration {
+	return time.Duration(c.WriteTimeout) * time.Second
+}
+
+// HTTPServerIdleTimeout is the amount of time to wait for the next request
+// on a keep-alive connection.
+func (c Config) HTTPServerIdleTimeout() time.Duration {
+	return time.Duration(c.IdleTimeout) * time.Second
+}
+
+// WebSocketEchoDelay is the amount of time to wait before echoing each
+// WebSocket message.
+func (c Config) WebSocketEchoDelay() time.Duration {
+	return time.Duration(c.WebSocketDelay) * time.Millisecond
+}
+
+// WebSocketPingPeriod is the amount of time between ping frames sent to
+// WebSocket clients.
+func (c Config) WebSocketPingPeriod() time.Duration {
+	return time.Duration(c.WebSocketPingInterval) * time.Second
+}
+
+// SSEEventInterval is the amount of time between Server-Sent Events.
+func (c Config) SSEEventInterval() time.Duration {
+	return time.Duration(c.SSEInterval) * time.Millisecond
+}
+
+// HTTPServerShutdownTimeout is used by the graceful shutdown process to
+// control how long the shutdown process should wait before forcefully
+// terminating.
+func (c Config) HTTPServerShutdownTimeout() time.Duration {
+	return time.Duration(c.ShutdownTimeout) * time.Second
+}
+
+// NotifyMgrServicesShutdownTimeout is used by the NotifyMgr to determine how
+// long it should wait for results from each notifier or notifier "service"
+// before continuing on with the shutdown process.
+func (c Config) NotifyMgrServicesShutdownTimeout() time.Duration {
+	return time.Duration(c.NotifyShutdownTimeout) * time.Second
+}
+
+// NotifyMgrFlushTimeout is used by the NotifyMgr to determine how long
+// pending notifications are given to be sent during shutdown.
+func (c Config) NotifyMgrFlushTimeout() time.Duration {
+	return time.Duration(c.NotifyFlushTimeout) * time.Second
+}
+
 // NotifyEmail indicates whether or not notifications should be generated and
 // sent via email to specified recipients.
 func (c Config) NotifyEmail() bool {
@@ -349,6 +1043,57 @@ func (c Config) NotifyEmail() bool {
 
 }
 
+// EnabledNotifiers returns the names of all enabled notifiers.
+func (c Config) EnabledNotifiers() []string {
+
+	var notifiers []string
+
+	if c.NotifyTeams() {
+		notifiers = append(notifiers, NotifierTeams)
+	}
+
+	if c.NotifyEmail() {
+		notifiers = append(notifiers, NotifierEmail)
+	}
+
+	return notifiers
+}
+
+// ValidEchoFormat indicates whether the given format for client request
+// details is supported.
+func ValidEchoFormat(format string) bool {
+	for _, supported := range EchoFormats {
+		if format == supported {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidSSEDisconnectMode indicates whether the given Server-Sent Events
+// disconnect mode is supported.
+func ValidSSEDisconnectMode(mode string) bool {
+	return mode == SSEDisconnectModeClose || mode == SSEDisconnectModeReset
+}
+
+// RateLimitFor returns the rate limit settings for the specified route. Rate
+// limits specified for the route in the configuration file take precedence,
+// followed by those specified for the default route and then those specified
+// via command-line flags.
+func (c Config) RateLimitFor(routeName string) RateLimit {
+
+	if rl, ok := c.RateLimits[routeName]; ok {
+		return rl
+	}
+
+	if rl, ok := c.RateLimits[DefaultRouteName]; ok {
+		return rl
+	}
+
+	return c.DefaultRateLimit
+}
+
 // GetTimeout accepts the next scheduled notification, the number of
 // message submission retries and the delay between each
 // attempt and returns the timeout value for the entire message submission
@@ -378,66 +1123,181 @@ func GetTimeout(baseTimeout time.Duration, schedule time.Time, retries int, retr
 }
 
 // NewConfig is a factory function that produces a new Config object based
-// on user provided flag values.
-func NewConfig() (*Config, error) {
+// on user provided flag values (excluding the program and subcommand name).
+func NewConfig(args []string) (*Config, error) {
 
-	config := Config{}
-
-	if err := config.handleFlagsConfig(); err != nil {
-		return nil, fmt.Errorf("error encountered configuring flags: %w", err)
+	config, err := load(args)
+	if err != nil {
+		return nil, err
 	}
 
 	// Apply initial logging settings based on any provided CLI flags
-	config.configureLogging()
+	if err := config.ConfigureLogging(); err != nil {
+		return nil, fmt.Errorf("failed to configure logging: %w", err)
+	}
 
 	// If no errors were encountered during parsing, proceed to validation of
 	// configuration settings (both user-specified and defaults)
-	if err := validate(config); err != nil {
+	if err := validate(*config); err != nil {
 		flag.Usage()
 		return nil, err
 	}
 
-	return &config, nil
+	return config, nil
 
 }
 
-// configureLogging is a wrapper function to enable setting requested logging
+// FromArgs creates a new Config from the given command-line flags (excluding
+// the program and subcommand name) and the configuration file they specify,
+// if any. The new Config is validated, but unlike NewConfig, logging settings
+// are not applied. This is used to reload the configuration (e.g., upon
+// receiving SIGHUP), in which case the caller is expected to call
+// ConfigureLogging once the new Config is accepted, and when running bounce
+// from other Go code (e.g., tests).
+func FromArgs(args []string) (*Config, error) {
+
+	config, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validate(*config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// load creates a new Config from the given command-line flags and the
+// optional configuration file.
+func load(args []string) (*Config, error) {
+
+	config := Config{}
+
+	if err := config.handleFlagsConfig(args); err != nil {
+		return nil, fmt.Errorf("error encountered configuring flags: %w", err)
+	}
+
+	if config.ConfigFile != "" {
+		if err := config.loadConfigFile(config.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+// ConfigureLogging is a wrapper function to enable setting requested logging
 // settings.
-func (c Config) configureLogging() {
+func (c Config) ConfigureLogging() error {
+
+	switch c.LogOutput {
+	case LogOutputSyslog:
+		h, err := loghandler.NewSyslog(c.SyslogAddress, c.SyslogFacility, MyAppName)
+		if err != nil {
+			return err
+		}
+		setLogHandler(h)
+
+	case LogOutputJournald:
+		h, err := loghandler.NewJournald(MyAppName)
+		if err != nil {
+			return err
+		}
+		setLogHandler(h)
+
+	default:
+		c.SetLogOutput(c.LogOutputTarget())
+	}
+
+	return SetLogLevel(c.LogLevel)
+}
+
+// SetLogLevel applies the given logging level. This is used to apply the
+// LogLevel setting and to change the logging level at runtime (e.g., via the
+// admin API).
+func SetLogLevel(level string) error {
+
+	switch level {
+	case LogLevelFatal:
+		log.SetLevel(log.FatalLevel)
+	case LogLevelError:
+		log.SetLevel(log.ErrorLevel)
+	case LogLevelWarn:
+		log.SetLevel(log.WarnLevel)
+	case LogLevelInfo:
+		log.SetLevel(log.InfoLevel)
+	case LogLevelDebug:
+		log.SetLevel(log.DebugLevel)
+	default:
+		return fmt.Errorf("invalid option %q provided for log level", level)
+	}
+
+	return nil
+}
+
+// CurrentLogLevel returns the logging level currently applied.
+func CurrentLogLevel() string {
+	if logger, ok := log.Log.(*log.Logger); ok {
+		return logger.Level.String()
+	}
+
+	return ""
+}
+
+// LogOutputTarget returns the standard application output (stdout or stderr)
+// chosen for log messages. Stderr is used for log output targets which are
+// not standard application outputs (e.g., syslog).
+func (c Config) LogOutputTarget() *os.File {
 
 	var logOutput *os.File
 
 	switch c.LogOutput {
-	case LogOutputStderr:
-		logOutput = os.Stderr
 	case LogOutputStdout:
 		logOutput = os.Stdout
+	default:
+		logOutput = os.Stderr
 	}
 
+	return logOutput
+}
+
+// SetLogOutput applies the requested log format using the given output
+// target for log messages. This is used to replace the LogOutput target
+// (e.g., with LogFile once opened).
+func (c Config) SetLogOutput(logOutput io.Writer) {
+
 	switch c.LogFormat {
 	case LogFormatCLI:
-		log.SetHandler(cli.New(logOutput))
+		setLogHandler(cli.New(logOutput))
 	case LogFormatJSON:
-		log.SetHandler(json.New(logOutput))
+		setLogHandler(json.New(logOutput))
 	case LogFormatLogFmt:
-		log.SetHandler(logfmt.New(logOutput))
+		setLogHandler(logfmt.New(logOutput))
 	case LogFormatText:
-		log.SetHandler(text.New(logOutput))
+		setLogHandler(text.New(logOutput))
 	case LogFormatDiscard:
-		log.SetHandler(discard.New())
+		setLogHandler(discard.New())
 	}
+}
 
-	switch c.LogLevel {
-	case LogLevelFatal:
-		log.SetLevel(log.FatalLevel)
-	case LogLevelError:
-		log.SetLevel(log.ErrorLevel)
-	case LogLevelWarn:
-		log.SetLevel(log.WarnLevel)
-	case LogLevelInfo:
-		log.SetLevel(log.InfoLevel)
-	case LogLevelDebug:
-		log.SetLevel(log.DebugLevel)
+// setLogHandler applies the given log handler and closes the handler it
+// replaces, if applicable. Handlers for system logging services (e.g.,
+// syslog) hold a connection which would otherwise be leaked each time the
+// configuration is reloaded.
+func setLogHandler(h log.Handler) {
+
+	var previous log.Handler
+	if logger, ok := log.Log.(*log.Logger); ok {
+		previous = logger.Handler
+	}
+
+	log.SetHandler(h)
+
+	if closer, ok := previous.(io.Closer); ok && previous != h {
+		if err := closer.Close(); err != nil {
+			log.Errorf("setLogHandler: failed to close previous log handler: %v", err)
+		}
 	}
 }
 
@@ -518,6 +1378,14 @@ func validate(c Config) error {
 	switch c.LogOutput {
 	case LogOutputStderr:
 	case LogOutputStdout:
+	case LogOutputSyslog:
+		if _, _, err := loghandler.ParseSyslogAddress(c.SyslogAddress); err != nil {
+			return err
+		}
+		if !loghandler.ValidFacility(c.SyslogFacility) {
+			return fmt.Errorf("invalid syslog facility %q", c.SyslogFacility)
+		}
+	case LogOutputJournald:
 	default:
 		return fmt.Errorf("invalid option %q provided for log output",
 			c.LogOutput)
@@ -536,6 +1404,202 @@ func validate(c Config) error {
 
 	// LogFormat
 
+	if _, err := clientip.ParseCIDRs(c.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxy setting: %w", err)
+	}
+
+	if !clientip.ValidForwardingHeader(c.ForwardedHeader) {
+		return fmt.Errorf(
+			"invalid forwarded header %q; supported headers: %s",
+			c.ForwardedHeader,
+			strings.Join(clientip.ForwardingHeaders, ", "),
+		)
+	}
+
+	if err := c.validateListeners(); err != nil {
+		return err
+	}
+
+	for routeName, policy := range c.AccessControl {
+		if err := validateAccessPolicy(policy); err != nil {
+			return fmt.Errorf(
+				"invalid access control policy for route %q: %w",
+				routeName,
+				err,
+			)
+		}
+	}
+
+	if err := validateRateLimit(c.DefaultRateLimit); err != nil {
+		return fmt.Errorf("invalid rate limit setting: %w", err)
+	}
+
+	for routeName, rl := range c.RateLimits {
+		if err := validateRateLimit(rl); err != nil {
+			return fmt.Errorf(
+				"invalid rate limit setting for route %q: %w",
+				routeName,
+				err,
+			)
+		}
+	}
+
+	for routeName, policy := range c.CORS {
+		if len(policy.AllowedOrigins) == 0 {
+			return fmt.Errorf("CORS policy for route %q does not specify any allowed origins", routeName)
+		}
+		if policy.MaxAge < 0 {
+			return fmt.Errorf("invalid CORS max age for route %q: %d", routeName, policy.MaxAge)
+		}
+	}
+
+	if c.AdminAccess != nil {
+		if err := validateAccessPolicy(*c.AdminAccess); err != nil {
+			return fmt.Errorf("invalid admin access policy: %w", err)
+		}
+
+		ap := c.AdminAccess
+		if len(ap.BasicAuth) == 0 && len(ap.BearerTokens) == 0 && len(ap.APIKeys) == 0 {
+			return fmt.Errorf("admin access policy does not specify any credentials")
+		}
+	}
+
+	if len(c.AdminListen) > 0 && c.AdminAccess == nil {
+		return fmt.Errorf("admin listen addresses require an admin access policy in the configuration file")
+	}
+
+	for routeName, faults := range c.Faults {
+		if err := ValidateFaultInjection(faults); err != nil {
+			return fmt.Errorf(
+				"invalid fault injection settings for route %q: %w",
+				routeName,
+				err,
+			)
+		}
+	}
+
+	routeNames := make(map[string]bool, len(c.Routes))
+	for _, route := range c.Routes {
+		if routeNames[route.Name] {
+			return fmt.Errorf("duplicate route name %q", route.Name)
+		}
+		routeNames[route.Name] = true
+
+		if err := validateRoute(route); err != nil {
+			return fmt.Errorf("invalid route %q: %w", route.Name, err)
+		}
+	}
+
+	if c.NotifyRateLimit < 0 || c.NotifyRateLimitBurst < 0 {
+		return fmt.Errorf(
+			"invalid notification rate limit settings: %d (burst %d)",
+			c.NotifyRateLimit,
+			c.NotifyRateLimitBurst,
+		)
+	}
+
+	if c.HistorySize < 0 {
+		return fmt.Errorf("invalid history size: %d", c.HistorySize)
+	}
+
+	if c.ReadyFailureThreshold < 0 {
+		return fmt.Errorf("invalid readiness failure threshold: %d", c.ReadyFailureThreshold)
+	}
+
+	if !ValidEchoFormat(c.ResponseFormat) {
+		return fmt.Errorf("invalid response format %q; supported formats: %v", c.ResponseFormat, EchoFormats)
+	}
+
+	if !ValidEchoFormat(c.OutputFormat) {
+		return fmt.Errorf("invalid output format %q; supported formats: %v", c.OutputFormat, EchoFormats)
+	}
+
+	if c.ReadHeaderTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		return fmt.Errorf(
+			"invalid server timeout settings: read header timeout %d, read timeout %d, write timeout %d, idle timeout %d; timeouts may not be negative",
+			c.ReadHeaderTimeout,
+			c.ReadTimeout,
+			c.WriteTimeout,
+			c.IdleTimeout,
+		)
+	}
+
+	if c.MaxHeaderBytes < minMaxHeaderBytes {
+		return fmt.Errorf(
+			"invalid max header bytes %d; at least %d bytes are required",
+			c.MaxHeaderBytes,
+			minMaxHeaderBytes,
+		)
+	}
+
+	if c.MaxConnections < 0 {
+		return fmt.Errorf("invalid max connections %d; value may not be negative", c.MaxConnections)
+	}
+
+	if c.WebSocketDelay < 0 || c.WebSocketCloseAfter < 0 || c.WebSocketPingInterval < 0 {
+		return fmt.Errorf(
+			"invalid WebSocket settings: delay %d, close after %d, ping interval %d; values may not be negative",
+			c.WebSocketDelay,
+			c.WebSocketCloseAfter,
+			c.WebSocketPingInterval,
+		)
+	}
+
+	if c.WebSocketMaxMessageSize < 1 {
+		return fmt.Errorf("invalid WebSocket max message size %d; value must be at least 1", c.WebSocketMaxMessageSize)
+	}
+
+	if c.SSERetry < 0 || c.SSEDisconnectAfter < 0 {
+		return fmt.Errorf(
+			"invalid Server-Sent Events settings: retry %d, disconnect after %d; values may not be negative",
+			c.SSERetry,
+			c.SSEDisconnectAfter,
+		)
+	}
+
+	if c.SSEInterval < MinSSEInterval {
+		return fmt.Errorf(
+			"invalid Server-Sent Events interval %d; value must be at least %d",
+			c.SSEInterval,
+			MinSSEInterval,
+		)
+	}
+
+	if !ValidSSEDisconnectMode(c.SSEDisconnectMode) {
+		return fmt.Errorf(
+			"invalid Server-Sent Events disconnect mode %q; supported modes: %s, %s",
+			c.SSEDisconnectMode,
+			SSEDisconnectModeClose,
+			SSEDisconnectModeReset,
+		)
+	}
+
+	if c.SSEDataTemplate != "" && c.SSEEventsFile != "" {
+		return fmt.Errorf("the Server-Sent Events data template and events file settings may not be used together")
+	}
+
+	if c.ShutdownTimeout < 1 || c.NotifyShutdownTimeout < 1 || c.NotifyFlushTimeout < 1 {
+		return fmt.Errorf(
+			"invalid shutdown timeout settings: shutdown timeout %d, notify shutdown timeout %d, notify flush timeout %d; timeouts must be at least 1 second",
+			c.ShutdownTimeout,
+			c.NotifyShutdownTimeout,
+			c.NotifyFlushTimeout,
+		)
+	}
+
+	if c.TemplateReload && c.EchoTemplate == "" && c.IndexTemplate == "" {
+		return fmt.Errorf("template reload requires an echo or index template file")
+	}
+
+	if c.RotateMaxSize < 0 || c.RotateMaxAge < 0 || c.RotateMaxBackups < 0 {
+		return fmt.Errorf(
+			"invalid file rotation settings: max size %d, max age %d, max backups %d",
+			c.RotateMaxSize,
+			c.RotateMaxAge,
+			c.RotateMaxBackups,
+		)
+	}
+
 	// Not having a webhook URL is a valid choice. Perform validation if value
 	// is provided.
 	if c.WebhookURL != "" {
@@ -551,3 +1615,273 @@ func validate(c Config) error {
 	return nil
 
 }
+
+// validateListeners confirms that the listen addresses are valid and that
+// TLS settings are provided if needed.
+func (c Config) validateListeners() error {
+
+	var useTLS bool
+	var useSystemd bool
+	seen := make(map[string]bool, len(c.Listen)+len(c.AdminListen))
+	for _, addr := range c.AdminListen {
+		spec, err := listener.Parse(addr)
+		if err != nil {
+			return err
+		}
+
+		if seen[spec.String()] {
+			return fmt.Errorf("duplicate admin listen address %q", addr)
+		}
+		seen[spec.String()] = true
+
+		if spec.Kind == listener.KindSystemd {
+			return fmt.Errorf("invalid admin listen address %q: systemd sockets are not supported for the admin API", addr)
+		}
+
+		useTLS = useTLS || spec.TLS
+	}
+
+	for _, addr := range c.Listen {
+		spec, err := listener.Parse(addr)
+		if err != nil {
+			return err
+		}
+
+		if seen[spec.String()] {
+			return fmt.Errorf("duplicate listen address %q", addr)
+		}
+		seen[spec.String()] = true
+
+		if spec.Kind == listener.KindSystemd {
+			if useSystemd {
+				return fmt.Errorf("invalid listen address %q: systemd sockets may only be specified once", addr)
+			}
+			useSystemd = true
+		}
+
+		useTLS = useTLS || spec.TLS
+	}
+
+	switch {
+	case useTLS && (c.TLSCertFile == "" || c.TLSKeyFile == ""):
+		return fmt.Errorf("https listeners require a TLS certificate file and key file")
+	case !useTLS && (c.TLSCertFile != "" || c.TLSKeyFile != ""):
+		return fmt.Errorf("TLS certificate and key files require an https listener")
+	}
+
+	return nil
+}
+
+// validateAccessPolicy confirms that an access policy has reasonable values.
+func validateAccessPolicy(ap AccessPolicy) error {
+
+	if _, err := clientip.ParseCIDRs(ap.Allow); err != nil {
+		return fmt.Errorf("invalid allow entry: %w", err)
+	}
+
+	if _, err := clientip.ParseCIDRs(ap.Deny); err != nil {
+		return fmt.Errorf("invalid deny entry: %w", err)
+	}
+
+	for username, password := range ap.BasicAuth {
+		if username == "" || password == "" {
+			return fmt.Errorf("basic auth entries require a username and password")
+		}
+	}
+
+	for _, token := range ap.BearerTokens {
+		if token == "" {
+			return fmt.Errorf("empty bearer token specified")
+		}
+	}
+
+	for _, key := range ap.APIKeys {
+		if key == "" {
+			return fmt.Errorf("empty API key specified")
+		}
+	}
+
+	return nil
+}
+
+// validateRateLimit confirms that rate limit settings have reasonable values.
+func validateRateLimit(rl RateLimit) error {
+
+	switch {
+	case rl.ClientPerMinute < 0:
+		return fmt.Errorf("invalid per-client rate limit: %d", rl.ClientPerMinute)
+	case rl.ClientBurst < 0:
+		return fmt.Errorf("invalid per-client burst size: %d", rl.ClientBurst)
+	case rl.RoutePerMinute < 0:
+		return fmt.Errorf("invalid per-route rate limit: %d", rl.RoutePerMinute)
+	case rl.RouteBurst < 0:
+		return fmt.Errorf("invalid per-route burst size: %d", rl.RouteBurst)
+	}
+
+	return nil
+}
+
+// ValidateFaultInjection confirms that fault injection settings have
+// reasonable values.
+func ValidateFaultInjection(f FaultInjection) error {
+
+	validPercent := func(name string, percent float64) error {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("invalid %s percentage: %v", name, percent)
+		}
+		return nil
+	}
+
+	if lf := f.Latency; lf != nil {
+		if err := validPercent("latency", lf.Percent); err != nil {
+			return err
+		}
+
+		if lf.DelayMs < 0 || lf.MaxDelayMs < 0 || lf.StdDevMs < 0 {
+			return fmt.Errorf(
+				"invalid latency delay settings: %d (max %d, stddev %d)",
+				lf.DelayMs,
+				lf.MaxDelayMs,
+				lf.StdDevMs,
+			)
+		}
+
+		switch lf.Distribution {
+		case "":
+		case LatencyDistributionFixed:
+		case LatencyDistributionNormal:
+		case LatencyDistributionUniform:
+			if lf.MaxDelayMs < lf.DelayMs {
+				return fmt.Errorf(
+					"maximum latency delay %d is less than delay %d",
+					lf.MaxDelayMs,
+					lf.DelayMs,
+				)
+			}
+		default:
+			return fmt.Errorf("invalid latency distribution %q", lf.Distribution)
+		}
+	}
+
+	var total float64
+
+	if ef := f.Error; ef != nil {
+		if err := validPercent("error", ef.Percent); err != nil {
+			return err
+		}
+		if ef.Status != 0 && (ef.Status < 400 || ef.Status > 599) {
+			return fmt.Errorf("invalid error status %d", ef.Status)
+		}
+		total += ef.Percent
+	}
+
+	if rf := f.Reset; rf != nil {
+		if err := validPercent("reset", rf.Percent); err != nil {
+			return err
+		}
+		total += rf.Percent
+	}
+
+	if tf := f.Truncate; tf != nil {
+		if err := validPercent("truncate", tf.Percent); err != nil {
+			return err
+		}
+		if tf.Bytes < 0 {
+			return fmt.Errorf("invalid truncate bytes: %d", tf.Bytes)
+		}
+		total += tf.Percent
+	}
+
+	if sf := f.SlowDrip; sf != nil {
+		if err := validPercent("slow drip", sf.Percent); err != nil {
+			return err
+		}
+		if sf.ChunkBytes < 0 || sf.IntervalMs < 0 {
+			return fmt.Errorf(
+				"invalid slow drip settings: %d bytes every %d ms",
+				sf.ChunkBytes,
+				sf.IntervalMs,
+			)
+		}
+		total += sf.Percent
+	}
+
+	if hf := f.Hang; hf != nil {
+		if err := validPercent("hang", hf.Percent); err != nil {
+			return err
+		}
+		if hf.TimeoutMs < 0 {
+			return fmt.Errorf("invalid hang timeout: %d", hf.TimeoutMs)
+		}
+		total += hf.Percent
+	}
+
+	if total > 100 {
+		return fmt.Errorf("fault percentages total %v, exceeding 100", total)
+	}
+
+	return nil
+}
+
+// ValidateResponseRule confirms that a response rule has reasonable values.
+func ValidateResponseRule(rule ResponseRule) error {
+
+	if rule.Status != 0 && (rule.Status < 200 || rule.Status > 599) {
+		return fmt.Errorf("invalid response status %d", rule.Status)
+	}
+
+	return nil
+}
+
+// validateRoute confirms that a user-defined route has reasonable values.
+func validateRoute(rc RouteConfig) error {
+
+	if rc.Name == "" {
+		return fmt.Errorf("route name not provided")
+	}
+
+	if !strings.HasPrefix(rc.Pattern, "/") {
+		return fmt.Errorf("pattern %q must begin with /", rc.Pattern)
+	}
+
+	for _, method := range rc.Methods {
+		switch method {
+		case http.MethodGet:
+		case http.MethodHead:
+		case http.MethodPost:
+		case http.MethodPut:
+		case http.MethodPatch:
+		case http.MethodDelete:
+		case http.MethodOptions:
+		default:
+			return fmt.Errorf("unsupported method %q", method)
+		}
+	}
+
+	switch rc.Formatter {
+	case "":
+	case FormatterRaw:
+	case FormatterJSON:
+	default:
+		return fmt.Errorf("invalid formatter %q", rc.Formatter)
+	}
+
+	if rc.Response != nil {
+		if err := ValidateResponseRule(*rc.Response); err != nil {
+			return err
+		}
+	}
+
+	if rc.Notifiers != nil {
+		for _, notifier := range *rc.Notifiers {
+			switch notifier {
+			case NotifierTeams:
+			case NotifierEmail:
+			default:
+				return fmt.Errorf("invalid notifier %q", notifier)
+			}
+		}
+	}
+
+	return nil
+}
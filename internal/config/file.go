@@ -0,0 +1,412 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/log"
+)
+
+// DefaultRouteName is the route name used in the configuration file to
+// specify settings (e.g., an access policy or rate limit) applied to all
+// routes without explicit settings of their own.
+const DefaultRouteName string = "*"
+
+// AccessPolicy represents the access control settings applied to a route.
+// Network restrictions are evaluated first. If any credentials are
+// configured, a request must then provide at least one valid credential.
+type AccessPolicy struct {
+
+	// BasicAuth is a collection of username/password pairs accepted via
+	// HTTP Basic authentication.
+	BasicAuth map[string]string `json:"basic_auth"`
+
+	// APIKeyHeader is the request header used to provide API keys. If not
+	// specified, DefaultAPIKeyHeader is used.
+	APIKeyHeader string `json:"api_key_header"`
+
+	// Allow is a list of IP Addresses or CIDR ranges permitted to access the
+	// route. If empty, all addresses not explicitly denied are permitted.
+	Allow []string `json:"allow"`
+
+	// Deny is a list of IP Addresses or CIDR ranges refused access to the
+	// route. Deny entries take precedence over Allow entries.
+	Deny []string `json:"deny"`
+
+	// BearerTokens is a list of static tokens accepted via the
+	// Authorization header using the Bearer scheme.
+	BearerTokens []string `json:"bearer_tokens"`
+
+	// APIKeys is a list of static keys accepted via the APIKeyHeader
+	// request header.
+	APIKeys []string `json:"api_keys"`
+}
+
+// DefaultAPIKeyHeader is the request header used to provide API keys if not
+// overridden by an access policy.
+const DefaultAPIKeyHeader string = "X-API-Key"
+
+// RateLimit represents the rate limit settings applied to a route. Limits
+// are expressed as requests per minute; a value of 0 disables the limit. A
+// burst size of 0 uses the associated limit as the burst size.
+type RateLimit struct {
+
+	// ClientPerMinute is the maximum number of requests per minute accepted
+	// from each client IP Address.
+	ClientPerMinute int `json:"client_per_minute"`
+
+	// ClientBurst is the maximum burst size for ClientPerMinute.
+	ClientBurst int `json:"client_burst"`
+
+	// RoutePerMinute is the maximum number of requests per minute accepted
+	// from all clients combined.
+	RoutePerMinute int `json:"route_per_minute"`
+
+	// RouteBurst is the maximum burst size for RoutePerMinute.
+	RouteBurst int `json:"route_burst"`
+}
+
+// Formatters supported for user-defined routes.
+const (
+
+	// FormatterRaw echoes the request body as-is.
+	FormatterRaw string = "raw"
+
+	// FormatterJSON decodes the request body as JSON and echoes a formatted
+	// (optionally colorized) copy of the payload.
+	FormatterJSON string = "json"
+)
+
+// Notifiers which may be selected for user-defined routes.
+const (
+	NotifierTeams string = "teams"
+	NotifierEmail string = "email"
+)
+
+// ResponseRule controls the response returned to clients for a route.
+type ResponseRule struct {
+
+	// Headers is a collection of headers added to the response.
+	Headers map[string]string `json:"headers"`
+
+	// ContentType is the Content-Type of the response. If not specified,
+	// text/plain is used.
+	ContentType string `json:"content_type"`
+
+	// Body is a static response body. If not specified, the client request
+	// details are echoed back to the client.
+	Body string `json:"body"`
+
+	// Status is the HTTP status code returned for successfully processed
+	// requests. If not specified, 200 is used.
+	Status int `json:"status"`
+}
+
+// RouteConfig represents a user-defined echo route.
+type RouteConfig struct {
+
+	// Response controls the response returned to clients. If not specified,
+	// the client request details are echoed back to the client.
+	Response *ResponseRule `json:"response"`
+
+	// Name is the unique name of the route.
+	Name string `json:"name"`
+
+	// Pattern is the path pattern for the route. Patterns use the same
+	// syntax as http.ServeMux; wildcards such as /hooks/splunk/{team} are
+	// supported and a trailing slash (or {name...} wildcard) matches all
+	// paths with the given prefix.
+	Pattern string `json:"pattern"`
+
+	// Description is a brief summary of the route shown on the index page.
+	Description string `json:"description"`
+
+	// Formatter controls how the request body is processed. If not
+	// specified, FormatterRaw is used.
+	Formatter string `json:"formatter"`
+
+	// Methods is the list of HTTP methods accepted by the route. If not
+	// specified, GET and POST are accepted.
+	Methods []string `json:"methods"`
+
+	// Notifiers is the list of notifiers used for requests received by the
+	// route. If not specified, all enabled notifiers are used. An empty list
+	// disables notifications for the route.
+	Notifiers *[]string `json:"notifiers"`
+}
+
+// CORSPolicy represents the Cross-Origin Resource Sharing settings applied to
+// a route.
+type CORSPolicy struct {
+
+	// AllowedOrigins is the list of origins permitted to make cross-origin
+	// requests. The value "*" permits all origins.
+	AllowedOrigins []string `json:"allowed_origins"`
+
+	// AllowedMethods is the list of methods permitted for cross-origin
+	// requests. If not specified, the methods accepted by the route are
+	// used.
+	AllowedMethods []string `json:"allowed_methods"`
+
+	// AllowedHeaders is the list of request headers permitted for
+	// cross-origin requests. If not specified, the headers requested by the
+	// client are permitted.
+	AllowedHeaders []string `json:"allowed_headers"`
+
+	// ExposedHeaders is the list of response headers made available to
+	// client scripts.
+	ExposedHeaders []string `json:"exposed_headers"`
+
+	// MaxAge is the number of seconds that preflight responses may be cached
+	// by clients.
+	MaxAge int `json:"max_age"`
+
+	// AllowCredentials indicates whether cookies and other credentials are
+	// permitted with cross-origin requests.
+	AllowCredentials bool `json:"allow_credentials"`
+}
+
+// Latency distributions supported for injected latency.
+const (
+
+	// LatencyDistributionFixed delays each affected request by the same
+	// amount.
+	LatencyDistributionFixed string = "fixed"
+
+	// LatencyDistributionUniform delays each affected request by an amount
+	// chosen uniformly between a minimum and maximum delay.
+	LatencyDistributionUniform string = "uniform"
+
+	// LatencyDistributionNormal delays each affected request by an amount
+	// chosen from a normal distribution with the given mean and standard
+	// deviation.
+	LatencyDistributionNormal string = "normal"
+)
+
+// DefaultFaultStatus is the HTTP status code returned for injected errors if
+// not overridden.
+const DefaultFaultStatus int = http.StatusServiceUnavailable
+
+// FaultInjection represents the faults injected into responses for a route
+// in order to simulate a misbehaving server. Each fault applies to the given
+// percentage (0 to 100) of requests. Latency is applied independently of the
+// other faults; at most one of the other faults is applied to each request,
+// so their percentages may not total more than 100.
+type FaultInjection struct {
+
+	// Latency adds a delay before the request is handled.
+	Latency *LatencyFault `json:"latency,omitempty"`
+
+	// Error returns an error response in place of the normal response.
+	Error *ErrorFault `json:"error,omitempty"`
+
+	// Reset closes the connection without sending a response.
+	Reset *ResetFault `json:"reset,omitempty"`
+
+	// Truncate closes the connection after sending part of the response
+	// body.
+	Truncate *TruncateFault `json:"truncate,omitempty"`
+
+	// SlowDrip sends the response body in small chunks with a delay between
+	// each chunk.
+	SlowDrip *SlowDripFault `json:"slow_drip,omitempty"`
+
+	// Hang holds the request open without sending a response until the
+	// client gives up.
+	Hang *HangFault `json:"hang,omitempty"`
+}
+
+// LatencyFault represents injected latency.
+type LatencyFault struct {
+
+	// Distribution is the distribution used to choose the delay. If not
+	// specified, LatencyDistributionFixed is used.
+	Distribution string `json:"distribution,omitempty"`
+
+	// Percent is the percentage of requests delayed.
+	Percent float64 `json:"percent"`
+
+	// DelayMs is the delay in milliseconds. This is the minimum delay for
+	// the uniform distribution and the mean delay for the normal
+	// distribution.
+	DelayMs int `json:"delay_ms"`
+
+	// MaxDelayMs is the maximum delay in milliseconds for the uniform
+	// distribution.
+	MaxDelayMs int `json:"max_delay_ms,omitempty"`
+
+	// StdDevMs is the standard deviation in milliseconds for the normal
+	// distribution.
+	StdDevMs int `json:"stddev_ms,omitempty"`
+}
+
+// ErrorFault represents injected error responses.
+type ErrorFault struct {
+
+	// Body is the response body. If not specified, the standard error
+	// response for the status code is used.
+	Body string `json:"body,omitempty"`
+
+	// Percent is the percentage of requests receiving an error response.
+	Percent float64 `json:"percent"`
+
+	// Status is the HTTP status code of the error response. If not
+	// specified, DefaultFaultStatus is used.
+	Status int `json:"status,omitempty"`
+}
+
+// ResetFault represents injected connection resets.
+type ResetFault struct {
+
+	// Percent is the percentage of requests for which the connection is
+	// closed without a response.
+	Percent float64 `json:"percent"`
+}
+
+// TruncateFault represents injected truncated responses.
+type TruncateFault struct {
+
+	// Percent is the percentage of requests receiving a truncated response.
+	Percent float64 `json:"percent"`
+
+	// Bytes is the number of response body bytes sent before the connection
+	// is closed. If not specified, half of the response body is sent.
+	Bytes int `json:"bytes,omitempty"`
+}
+
+// SlowDripFault represents injected slow responses.
+type SlowDripFault struct {
+
+	// Percent is the percentage of requests receiving a slow response.
+	Percent float64 `json:"percent"`
+
+	// ChunkBytes is the number of response body bytes sent at a time. If
+	// not specified, one byte is sent at a time.
+	ChunkBytes int `json:"chunk_bytes,omitempty"`
+
+	// IntervalMs is the number of milliseconds between chunks. If not
+	// specified, one chunk is sent each second.
+	IntervalMs int `json:"interval_ms,omitempty"`
+}
+
+// HangFault represents injected hanging requests.
+type HangFault struct {
+
+	// Percent is the percentage of requests which hang.
+	Percent float64 `json:"percent"`
+
+	// TimeoutMs is the number of milliseconds after which the connection is
+	// closed without a response. If not specified, requests hang until the
+	// client disconnects or the application is shut down.
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
+
+// fileConfig represents the settings supported by the optional JSON
+// configuration file. These settings are generally too complex to express
+// via command-line flags. A small number of settings also available via
+// command-line flags may be specified in order to change them when the
+// configuration is reloaded; these take precedence over the flags.
+type fileConfig struct {
+
+	// LogLevel overrides the log-lvl flag if specified.
+	LogLevel *string `json:"log_level"`
+
+	// LogFormat overrides the log-fmt flag if specified.
+	LogFormat *string `json:"log_format"`
+
+	// WebhookURL overrides the webhook-url flag if specified.
+	WebhookURL *string `json:"webhook_url"`
+
+	// EchoTemplate overrides the echo-template flag if specified.
+	EchoTemplate *string `json:"echo_template"`
+
+	// IndexTemplate overrides the index-template flag if specified.
+	IndexTemplate *string `json:"index_template"`
+
+	// TrustedProxies overrides the trusted-proxy flag if specified.
+	TrustedProxies *[]string `json:"trusted_proxies"`
+
+	// ForwardedHeader overrides the forwarded-header flag if specified.
+	ForwardedHeader *string `json:"forwarded_header"`
+
+	// AccessControl is a collection of access policies keyed by route name.
+	AccessControl map[string]AccessPolicy `json:"access_control"`
+
+	// RateLimits is a collection of rate limit settings keyed by route name.
+	RateLimits map[string]RateLimit `json:"rate_limits"`
+
+	// CORS is a collection of CORS policies keyed by route name.
+	CORS map[string]CORSPolicy `json:"cors"`
+
+	// AdminAccess is the access policy protecting the admin API.
+	AdminAccess *AccessPolicy `json:"admin_access"`
+
+	// Faults is a collection of fault injection settings keyed by route
+	// name.
+	Faults map[string]FaultInjection `json:"faults"`
+
+	// Routes is a collection of user-defined echo routes.
+	Routes []RouteConfig `json:"routes"`
+}
+
+// loadConfigFile reads the JSON configuration file at the specified path and
+// applies the settings to the Config.
+func (c *Config) loadConfigFile(path string) error {
+
+	fh, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer func() {
+		if err := fh.Close(); err != nil {
+			log.Errorf("failed to close config file %q: %v", path, err)
+		}
+	}()
+
+	var fc fileConfig
+	dec := json.NewDecoder(fh)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return fmt.Errorf("failed to parse config file %q: %w", path, err)
+	}
+
+	c.AccessControl = fc.AccessControl
+	c.RateLimits = fc.RateLimits
+	c.Routes = fc.Routes
+	c.CORS = fc.CORS
+	c.Faults = fc.Faults
+	c.AdminAccess = fc.AdminAccess
+
+	overrides := []struct {
+		value  *string
+		target *string
+	}{
+		{fc.LogLevel, &c.LogLevel},
+		{fc.LogFormat, &c.LogFormat},
+		{fc.WebhookURL, &c.WebhookURL},
+		{fc.EchoTemplate, &c.EchoTemplate},
+		{fc.IndexTemplate, &c.IndexTemplate},
+		{fc.ForwardedHeader, &c.ForwardedHeader},
+	}
+	for _, override := range overrides {
+		if override.value != nil {
+			*override.target = *override.value
+		}
+	}
+
+	if fc.TrustedProxies != nil {
+		c.TrustedProxies = *fc.TrustedProxies
+	}
+
+	return nil
+}
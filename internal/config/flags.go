@@ -10,11 +10,48 @@ package config
 import (
 	"flag"
 	"os"
+	"strings"
 )
 
+// multiValueStringFlag is a custom type that satisfies the flag.Value
+// interface in order to accept multiple string values for some of our flags.
+// Values may be provided by repeating the flag or as a comma-separated list.
+type multiValueStringFlag []string
+
+// String returns a comma separated string consisting of all slice elements.
+func (mvs *multiValueStringFlag) String() string {
+
+	// From the `flag` package docs:
+	// "The flag package may call the String method with a zero-valued
+	// receiver, such as a nil pointer."
+	if mvs == nil {
+		return ""
+	}
+
+	return strings.Join(*mvs, ", ")
+}
+
+// Set is called once by the flag package, in command line order, for each
+// flag present.
+func (mvs *multiValueStringFlag) Set(value string) error {
+
+	items := strings.Split(value, ",")
+
+	// Clean up any extra whitespace around the provided values.
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			*mvs = append(*mvs, item)
+		}
+	}
+
+	return nil
+}
+
 // handleFlagsConfig wraps flag setup code into a bundle for potential ease of
-// use and future testability
-func (c *Config) handleFlagsConfig() error {
+// use and future testability. The given arguments are parsed, excluding the
+// program name.
+func (c *Config) handleFlagsConfig(args []string) error {
 
 	mainFlagSet := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 
@@ -28,6 +65,57 @@ func (c *Config) handleFlagsConfig() error {
 	mainFlagSet.StringVar(&c.WebhookURL, "webhook-url", defaultWebhookURL, webhookURLFlagHelp)
 	mainFlagSet.IntVar(&c.Retries, "retries", defaultRetries, retriesFlagHelp)
 	mainFlagSet.IntVar(&c.RetriesDelay, "retries-delay", defaultRetriesDelay, retriesDelayFlagHelp)
+	mainFlagSet.Var(&c.Listen, "listen", listenFlagHelp)
+	mainFlagSet.Var(&c.AdminListen, "admin-listen", adminListenFlagHelp)
+	mainFlagSet.StringVar(&c.TLSCertFile, "tls-cert-file", defaultTLSCertFile, tlsCertFileFlagHelp)
+	mainFlagSet.StringVar(&c.TLSKeyFile, "tls-key-file", defaultTLSKeyFile, tlsKeyFileFlagHelp)
+	mainFlagSet.BoolVar(&c.H2C, "h2c", defaultH2C, h2cFlagHelp)
+	mainFlagSet.Var(&c.TrustedProxies, "trusted-proxy", trustedProxyFlagHelp)
+	mainFlagSet.StringVar(&c.ForwardedHeader, "forwarded-header", defaultForwardedHeader, forwardedHeaderFlagHelp)
+	mainFlagSet.StringVar(&c.ConfigFile, "config-file", defaultConfigFile, configFileFlagHelp)
+	mainFlagSet.BoolVar(&c.Compress, "compress", defaultCompress, compressFlagHelp)
+	mainFlagSet.IntVar(&c.DefaultRateLimit.ClientPerMinute, "client-rate-limit", defaultClientRateLimit, clientRateLimitFlagHelp)
+	mainFlagSet.IntVar(&c.DefaultRateLimit.ClientBurst, "client-rate-limit-burst", defaultClientRateLimitBurst, clientRateLimitBurstFlagHelp)
+	mainFlagSet.IntVar(&c.DefaultRateLimit.RoutePerMinute, "route-rate-limit", defaultRouteRateLimit, routeRateLimitFlagHelp)
+	mainFlagSet.IntVar(&c.DefaultRateLimit.RouteBurst, "route-rate-limit-burst", defaultRouteRateLimitBurst, routeRateLimitBurstFlagHelp)
+	mainFlagSet.IntVar(&c.NotifyRateLimit, "notify-rate-limit", defaultNotifyRateLimit, notifyRateLimitFlagHelp)
+	mainFlagSet.IntVar(&c.NotifyRateLimitBurst, "notify-rate-limit-burst", defaultNotifyRateLimitBurst, notifyRateLimitBurstFlagHelp)
+	mainFlagSet.IntVar(&c.HistorySize, "history-size", defaultHistorySize, historySizeFlagHelp)
+	mainFlagSet.IntVar(&c.ReadyFailureThreshold, "ready-failure-threshold", defaultReadyFailureThreshold, readyFailureThresholdFlagHelp)
+	mainFlagSet.StringVar(&c.ResponseFormat, "response-format", defaultResponseFormat, responseFormatFlagHelp)
+	mainFlagSet.StringVar(&c.OutputFormat, "output-format", defaultOutputFormat, outputFormatFlagHelp)
+	mainFlagSet.IntVar(&c.ReadHeaderTimeout, "read-header-timeout", defaultReadHeaderTimeout, readHeaderTimeoutFlagHelp)
+	mainFlagSet.IntVar(&c.ReadTimeout, "read-timeout", defaultReadTimeout, readTimeoutFlagHelp)
+	mainFlagSet.IntVar(&c.WriteTimeout, "write-timeout", defaultWriteTimeout, writeTimeoutFlagHelp)
+	mainFlagSet.IntVar(&c.IdleTimeout, "idle-timeout", defaultIdleTimeout, idleTimeoutFlagHelp)
+	mainFlagSet.IntVar(&c.MaxHeaderBytes, "max-header-bytes", defaultMaxHeaderBytes, maxHeaderBytesFlagHelp)
+	mainFlagSet.IntVar(&c.MaxConnections, "max-connections", defaultMaxConnections, maxConnectionsFlagHelp)
+	mainFlagSet.IntVar(&c.WebSocketDelay, "websocket-delay", defaultWebSocketDelay, websocketDelayFlagHelp)
+	mainFlagSet.IntVar(&c.WebSocketCloseAfter, "websocket-close-after", defaultWebSocketCloseAfter, websocketCloseAfterFlagHelp)
+	mainFlagSet.IntVar(&c.WebSocketPingInterval, "websocket-ping-interval", defaultWebSocketPing, websocketPingIntervalFlagHelp)
+	mainFlagSet.IntVar(&c.WebSocketMaxMessageSize, "websocket-max-message-size", defaultWebSocketMaxMessage, websocketMaxMessageFlagHelp)
+	mainFlagSet.IntVar(&c.SSEInterval, "sse-interval", defaultSSEInterval, sseIntervalFlagHelp)
+	mainFlagSet.StringVar(&c.SSEEvent, "sse-event", defaultSSEEvent, sseEventFlagHelp)
+	mainFlagSet.IntVar(&c.SSERetry, "sse-retry", defaultSSERetry, sseRetryFlagHelp)
+	mainFlagSet.IntVar(&c.SSEDisconnectAfter, "sse-disconnect-after", defaultSSEDisconnectAfter, sseDisconnectAfterFlagHelp)
+	mainFlagSet.StringVar(&c.SSEDisconnectMode, "sse-disconnect-mode", defaultSSEDisconnectMode, sseDisconnectModeFlagHelp)
+	mainFlagSet.StringVar(&c.SSEDataTemplate, "sse-data-template", defaultSSEDataTemplate, sseDataTemplateFlagHelp)
+	mainFlagSet.StringVar(&c.SSEEventsFile, "sse-events-file", defaultSSEEventsFile, sseEventsFileFlagHelp)
+	mainFlagSet.IntVar(&c.ShutdownTimeout, "shutdown-timeout", defaultHTTPServerShutdownTimeout, shutdownTimeoutFlagHelp)
+	mainFlagSet.IntVar(&c.NotifyShutdownTimeout, "notify-shutdown-timeout", defaultNotifyMgrServicesShutdownTimeout, notifyShutdownTimeoutFlagHelp)
+	mainFlagSet.BoolVar(&c.NotifyFlush, "notify-flush", defaultNotifyFlush, notifyFlushFlagHelp)
+	mainFlagSet.IntVar(&c.NotifyFlushTimeout, "notify-flush-timeout", defaultNotifyFlushTimeout, notifyFlushTimeoutFlagHelp)
+	mainFlagSet.StringVar(&c.EchoTemplate, "echo-template", defaultEchoTemplate, echoTemplateFlagHelp)
+	mainFlagSet.StringVar(&c.IndexTemplate, "index-template", defaultIndexTemplate, indexTemplateFlagHelp)
+	mainFlagSet.BoolVar(&c.TemplateReload, "template-reload", defaultTemplateReload, templateReloadFlagHelp)
+	mainFlagSet.StringVar(&c.SyslogAddress, "syslog-addr", defaultSyslogAddress, syslogAddressFlagHelp)
+	mainFlagSet.StringVar(&c.SyslogFacility, "syslog-facility", defaultSyslogFacility, syslogFacilityFlagHelp)
+	mainFlagSet.StringVar(&c.LogFile, "log-file", defaultLogFile, logFileFlagHelp)
+	mainFlagSet.StringVar(&c.OutputFile, "output-file", defaultOutputFile, outputFileFlagHelp)
+	mainFlagSet.IntVar(&c.RotateMaxSize, "rotate-max-size", defaultRotateMaxSize, rotateMaxSizeFlagHelp)
+	mainFlagSet.IntVar(&c.RotateMaxAge, "rotate-max-age", defaultRotateMaxAge, rotateMaxAgeFlagHelp)
+	mainFlagSet.IntVar(&c.RotateMaxBackups, "rotate-max-backups", defaultRotateMaxBackups, rotateMaxBackupsFlagHelp)
+	mainFlagSet.BoolVar(&c.RotateCompress, "rotate-compress", defaultRotateCompress, rotateCompressFlagHelp)
 
 	mainFlagSet.Usage = Usage(mainFlagSet)
 
@@ -38,5 +126,5 @@ func (c *Config) handleFlagsConfig() error {
 	// been parsed?
 	// flag.CommandLine = mainFlagSet
 	// flag.Parse()
-	return mainFlagSet.Parse(os.Args[1:])
+	return mainFlagSet.Parse(args)
 }
@@ -0,0 +1,150 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package fault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Buffer is an http.ResponseWriter which retains the response in memory
+// instead of sending it to the client.
+type Buffer struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+// NewBuffer creates a new, empty Buffer.
+func NewBuffer() *Buffer {
+	return &Buffer{
+		header: make(http.Header),
+	}
+}
+
+// Header returns the buffered response headers.
+func (b *Buffer) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the response status code. Only the first call has any
+// effect.
+func (b *Buffer) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+// Write appends to the buffered response body. A 200 status code is recorded
+// if WriteHeader has not been called.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// Flush is provided so that handlers which flush their responses are able to
+// do so; buffered responses are not sent until requested.
+func (b *Buffer) Flush() {}
+
+// Status returns the recorded response status code, or 200 if none was
+// recorded.
+func (b *Buffer) Status() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// Len returns the length of the buffered response body.
+func (b *Buffer) Len() int {
+	return b.body.Len()
+}
+
+// writeHeader sends the buffered headers and status code. The
+// Content-Length header reflects the full buffered response body so that
+// clients are able to detect responses which are cut short.
+func (b *Buffer) writeHeader(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(b.body.Len()))
+	w.WriteHeader(b.Status())
+}
+
+// SendTruncated sends the buffered headers and at most n bytes of the
+// buffered response body. The caller is expected to close the connection
+// afterward (e.g., by panicking with http.ErrAbortHandler).
+func (b *Buffer) SendTruncated(w http.ResponseWriter, n int) error {
+
+	b.writeHeader(w)
+
+	body := b.body.Bytes()
+	if n < len(body) {
+		body = body[:n]
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write truncated response: %w", err)
+	}
+
+	return flush(w)
+}
+
+// SendSlowly sends the buffered response, writing chunkSize bytes of the
+// body at a time with the given interval between chunks. Sending stops early
+// if the context is canceled.
+func (b *Buffer) SendSlowly(ctx context.Context, w http.ResponseWriter, chunkSize int, interval time.Duration) error {
+
+	if chunkSize < 1 {
+		return fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	b.writeHeader(w)
+	if err := flush(w); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	body := b.body.Bytes()
+	for len(body) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			timer.Reset(interval)
+		}
+
+		n := min(chunkSize, len(body))
+		if _, err := w.Write(body[:n]); err != nil {
+			return fmt.Errorf("failed to write response chunk: %w", err)
+		}
+		if err := flush(w); err != nil {
+			return err
+		}
+		body = body[n:]
+	}
+
+	return nil
+}
+
+// flush sends any data buffered by the http.ResponseWriter to the client.
+func flush(w http.ResponseWriter) error {
+	err := http.NewResponseController(w).Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("failed to flush response: %w", err)
+	}
+
+	return nil
+}
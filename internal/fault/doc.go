@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package fault provides the mechanics used to inject faults into HTTP
+responses in order to simulate a misbehaving server (e.g., to exercise the
+retry logic of webhook senders).
+
+A handler writes its response to a Buffer instead of the client connection.
+The buffered response may then be discarded, sent in part before the
+connection is closed or sent slowly in small chunks.
+*/
+package fault
@@ -0,0 +1,20 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package jsonmatch provides subset matching of decoded JSON values.
+
+A value matches an expected value if every member of each expected object is
+present in the corresponding actual object with a matching value, if every
+element of each expected array matches at least one element of the
+corresponding actual array and if all other values are equal. Members and
+elements not mentioned by the expected value are ignored.
+
+Values are compared as decoded by encoding/json into interface{} values.
+Differences are reported using a JSONPath-like notation (e.g., $.a.b[0]).
+*/
+package jsonmatch
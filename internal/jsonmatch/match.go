@@ -0,0 +1,138 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package jsonmatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// RootPath is the path of the top-level value.
+const RootPath string = "$"
+
+// Difference describes a single way in which an actual value does not match
+// the expected value.
+type Difference struct {
+
+	// Path is the location of the value within the document.
+	Path string `json:"path"`
+
+	// Expected is the expected value, encoded as JSON.
+	Expected string `json:"expected"`
+
+	// Actual is the actual value encoded as JSON, or empty if the value is
+	// missing.
+	Actual string `json:"actual,omitempty"`
+}
+
+// String describes the difference in a single line.
+func (d Difference) String() string {
+	if d.Actual == "" {
+		return fmt.Sprintf("%s: expected %s, missing", d.Path, d.Expected)
+	}
+
+	return fmt.Sprintf("%s: expected %s, got %s", d.Path, d.Expected, d.Actual)
+}
+
+// Subset compares the actual value against the expected value, returning
+// each difference found. An empty result indicates that the actual value
+// matches.
+func Subset(expected interface{}, actual interface{}) []Difference {
+	return subset(RootPath, expected, actual)
+}
+
+// subset compares the values found at the given path.
+func subset(path string, expected interface{}, actual interface{}) []Difference {
+
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return []Difference{newDifference(path, expected, actual)}
+		}
+
+		keys := make([]string, 0, len(exp))
+		for key := range exp {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var diffs []Difference
+		for _, key := range keys {
+			memberPath := path + "." + key
+			value, ok := act[key]
+			if !ok {
+				diffs = append(diffs, Difference{Path: memberPath, Expected: encode(exp[key])})
+				continue
+			}
+			diffs = append(diffs, subset(memberPath, exp[key], value)...)
+		}
+
+		return diffs
+
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			return []Difference{newDifference(path, expected, actual)}
+		}
+
+		var diffs []Difference
+		for i, element := range exp {
+			if !containsMatch(element, act) {
+				diffs = append(diffs, Difference{
+					Path:     path + "[" + strconv.Itoa(i) + "]",
+					Expected: encode(element),
+				})
+			}
+		}
+
+		return diffs
+
+	default:
+		if encode(expected) != encode(actual) {
+			return []Difference{newDifference(path, expected, actual)}
+		}
+
+		return nil
+	}
+}
+
+// containsMatch indicates whether any of the given values matches the
+// expected value.
+func containsMatch(expected interface{}, values []interface{}) bool {
+	for _, value := range values {
+		if len(subset(RootPath, expected, value)) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newDifference returns a Difference for mismatched values at the given
+// path.
+func newDifference(path string, expected interface{}, actual interface{}) Difference {
+	return Difference{
+		Path:     path,
+		Expected: encode(expected),
+		Actual:   encode(actual),
+	}
+}
+
+// encode returns the JSON encoding of the given value. Values decoded from
+// JSON can always be encoded, so an error is not expected.
+func encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(b)
+}
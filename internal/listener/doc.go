@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package listener provides the network listeners used by the HTTP server.
+Listeners are described using address strings which specify TCP addresses
+(IPv4 or IPv6, with or without TLS), unix domain sockets or sockets passed to
+the application by systemd (socket activation).
+
+Helpers are also provided for limiting the number of concurrent connections
+accepted across all listeners.
+*/
+package listener
@@ -0,0 +1,104 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package listener
+
+import (
+	"net"
+	"sync"
+)
+
+// ConnectionLimit limits the number of concurrent connections accepted by
+// one or many listeners.
+type ConnectionLimit struct {
+	sem chan struct{}
+}
+
+// NewConnectionLimit creates a ConnectionLimit which permits at most n
+// concurrent connections.
+func NewConnectionLimit(n int) *ConnectionLimit {
+	return &ConnectionLimit{sem: make(chan struct{}, n)}
+}
+
+// Wrap returns a Listener which accepts connections from the given Listener
+// subject to the limit. Once the limit is reached, further connections are
+// not accepted until an existing connection is closed; they wait in the
+// operating system's connection backlog instead.
+func (cl *ConnectionLimit) Wrap(l net.Listener) net.Listener {
+	return &limitListener{
+		Listener: l,
+		sem:      cl.sem,
+		done:     make(chan struct{}),
+	}
+}
+
+// limitListener is a net.Listener which accepts connections subject to a
+// ConnectionLimit.
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// acquire waits for a connection slot to become available. false is returned
+// if the Listener is closed first.
+func (l *limitListener) acquire() bool {
+	select {
+	case <-l.done:
+		return false
+	case l.sem <- struct{}{}:
+		return true
+	}
+}
+
+// release frees a connection slot.
+func (l *limitListener) release() {
+	<-l.sem
+}
+
+// Accept waits for a connection slot and then for the next connection.
+func (l *limitListener) Accept() (net.Conn, error) {
+
+	if !l.acquire() {
+		// The underlying Listener is closed, so Accept returns the
+		// appropriate error.
+		return l.Listener.Accept()
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+
+	return &limitConn{Conn: conn, release: l.release}, nil
+}
+
+// Close closes the underlying Listener and stops waiting for connection
+// slots.
+func (l *limitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+
+	return err
+}
+
+// limitConn frees its connection slot once closed.
+type limitConn struct {
+	net.Conn
+	release     func()
+	releaseOnce sync.Once
+}
+
+// Close closes the connection and frees its connection slot.
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+
+	return err
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Supported listener kinds.
+const (
+	KindTCP     string = "tcp"
+	KindUnix    string = "unix"
+	KindSystemd string = "systemd"
+)
+
+// Address prefixes used to specify the listener kind and whether TLS is
+// used.
+const (
+	prefixHTTP    string = "http://"
+	prefixHTTPS   string = "https://"
+	prefixUnix    string = "unix://"
+	prefixSystemd string = "systemd"
+)
+
+// Spec describes a listener.
+type Spec struct {
+
+	// Kind is one of KindTCP, KindUnix or KindSystemd.
+	Kind string
+
+	// Address is the host:port for KindTCP listeners, the socket path for
+	// KindUnix listeners or the optional socket name (as specified by the
+	// FileDescriptorName setting of the systemd socket unit) for
+	// KindSystemd listeners.
+	Address string
+
+	// TLS indicates whether connections are served using TLS.
+	TLS bool
+}
+
+// Parse parses a listener address. Supported forms are:
+//
+//	host:port, http://host:port   plain HTTP over TCP
+//	https://host:port             HTTPS over TCP
+//	unix:///path/to/socket        plain HTTP over a unix domain socket
+//	systemd, systemd:name         plain HTTP over all (or the named)
+//	                              sockets passed by systemd
+//
+// IPv6 addresses are given in brackets (e.g., [::1]:8000). An empty host
+// listens on all addresses.
+func Parse(addr string) (Spec, error) {
+
+	addr = strings.TrimSpace(addr)
+
+	var spec Spec
+	switch {
+	case addr == prefixSystemd || strings.HasPrefix(addr, prefixSystemd+":"):
+		spec = Spec{
+			Kind:    KindSystemd,
+			Address: strings.TrimPrefix(strings.TrimPrefix(addr, prefixSystemd), ":"),
+		}
+		return spec, nil
+
+	case strings.HasPrefix(addr, prefixUnix):
+		spec = Spec{Kind: KindUnix, Address: strings.TrimPrefix(addr, prefixUnix)}
+		if spec.Address == "" {
+			return Spec{}, fmt.Errorf("invalid listen address %q: missing socket path", addr)
+		}
+		return spec, nil
+
+	case strings.HasPrefix(addr, prefixHTTPS):
+		spec = Spec{Kind: KindTCP, Address: strings.TrimPrefix(addr, prefixHTTPS), TLS: true}
+
+	default:
+		spec = Spec{Kind: KindTCP, Address: strings.TrimPrefix(addr, prefixHTTP)}
+	}
+
+	if strings.Contains(spec.Address, "://") {
+		return Spec{}, fmt.Errorf("invalid listen address %q: unsupported scheme", addr)
+	}
+
+	host, port, err := net.SplitHostPort(spec.Address)
+	if err != nil {
+		return Spec{}, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+
+	if host != "" && net.ParseIP(host) == nil && !validHostname(host) {
+		return Spec{}, fmt.Errorf("invalid listen address %q: invalid host %q", addr, host)
+	}
+
+	if _, err := net.LookupPort("tcp", port); err != nil || port == "" {
+		return Spec{}, fmt.Errorf("invalid listen address %q: invalid port %q", addr, port)
+	}
+
+	return spec, nil
+}
+
+// validHostname performs basic validation of a hostname (e.g., localhost).
+func validHostname(host string) bool {
+	for _, r := range host {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the address of the listener in the form accepted by Parse.
+func (s Spec) String() string {
+	switch {
+	case s.Kind == KindSystemd && s.Address != "":
+		return prefixSystemd + ":" + s.Address
+	case s.Kind == KindSystemd:
+		return prefixSystemd
+	case s.Kind == KindUnix:
+		return prefixUnix + s.Address
+	case s.TLS:
+		return prefixHTTPS + s.Address
+	default:
+		return prefixHTTP + s.Address
+	}
+}
+
+// Listener is an open listener along with the Spec used to open it.
+type Listener struct {
+	net.Listener
+	Spec Spec
+}
+
+// Open opens the listener described by the given Spec. Multiple listeners
+// may be returned for KindSystemd.
+func Open(spec Spec) ([]Listener, error) {
+
+	switch spec.Kind {
+	case KindSystemd:
+		return systemdListeners(spec)
+
+	case KindUnix:
+		if err := removeStaleSocket(spec.Address); err != nil {
+			return nil, err
+		}
+
+		l, err := net.Listen("unix", spec.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", spec, err)
+		}
+
+		return []Listener{{Listener: l, Spec: spec}}, nil
+
+	default:
+		l, err := net.Listen("tcp", spec.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", spec, err)
+		}
+
+		return []Listener{{Listener: l, Spec: spec}}, nil
+	}
+}
+
+// removeStaleSocket removes a unix domain socket left behind by a previous
+// instance of the application. Files which are not sockets are left as-is.
+func removeStaleSocket(path string) error {
+
+	fi, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to check for existing socket %q: %w", path, err)
+	case fi.Mode()&os.ModeSocket == 0:
+		return fmt.Errorf("failed to listen on unix socket %q: file exists and is not a socket", path)
+	}
+
+	// A socket which still accepts connections belongs to a running process.
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to listen on unix socket %q: socket is in use", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %q: %w", path, err)
+	}
+
+	return nil
+}
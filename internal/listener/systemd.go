@@ -0,0 +1,105 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemdListenFDsStart is the first file descriptor passed by systemd; file
+// descriptors 0 - 2 are used for stdin, stdout and stderr.
+const systemdListenFDsStart int = 3
+
+// Environment variables set by systemd for socket activated services.
+const (
+	envListenPID     string = "LISTEN_PID"
+	envListenFDs     string = "LISTEN_FDS"
+	envListenFDNames string = "LISTEN_FDNAMES"
+)
+
+// systemdListeners returns listeners for the sockets passed by systemd,
+// limited to those with the name given by the Spec (if any). Sockets which
+// are not used are closed. The environment variables describing the sockets
+// are cleared so that they are not inherited by child processes, so this
+// function may only be called once.
+func systemdListeners(spec Spec) ([]Listener, error) {
+
+	files, err := collectSystemdFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []Listener
+	for _, f := range files {
+		if spec.Address != "" && f.Name() != spec.Address {
+			_ = f.Close()
+			continue
+		}
+
+		// FileListener duplicates the file descriptor, so the file is
+		// closed either way.
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("failed to use socket %q passed by systemd: %w", f.Name(), err)
+		}
+
+		listeners = append(listeners, Listener{
+			Listener: l,
+			Spec:     Spec{Kind: KindSystemd, Address: f.Name()},
+		})
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no sockets matching %s were passed by systemd", spec)
+	}
+
+	return listeners, nil
+}
+
+// collectSystemdFiles collects the sockets passed by systemd using the
+// socket activation protocol described by sd_listen_fds(3).
+func collectSystemdFiles() ([]*os.File, error) {
+
+	defer func() {
+		_ = os.Unsetenv(envListenPID)
+		_ = os.Unsetenv(envListenFDs)
+		_ = os.Unsetenv(envListenFDNames)
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv(envListenPID))
+	if err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no sockets were passed by systemd (socket activation is not in use)")
+	}
+
+	count, err := strconv.Atoi(os.Getenv(envListenFDs))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("no sockets were passed by systemd (invalid %s value)", envListenFDs)
+	}
+
+	names := strings.Split(os.Getenv(envListenFDNames), ":")
+
+	files := make([]*os.File, 0, count)
+	for i := 0; i < count; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(systemdListenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		files = append(files, os.NewFile(uintptr(systemdListenFDsStart+i), name))
+	}
+
+	return files, nil
+}
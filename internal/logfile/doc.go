@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package logfile provides an io.Writer for output files which are rotated once
+they reach a maximum size or age. Rotated files are renamed using a
+timestamp suffix, optionally compressed using gzip and pruned once the
+maximum number of rotated files is exceeded.
+
+Files may also be reopened on request (e.g., upon receiving a SIGHUP signal)
+in order to support rotation by external tools such as logrotate.
+*/
+package logfile
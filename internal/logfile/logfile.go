@@ -0,0 +1,402 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package logfile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// backupTimeFormat is the timestamp format used as the suffix for rotated
+// files. The format sorts lexically in chronological order.
+const backupTimeFormat string = "20060102T150405.000"
+
+// compressedSuffix is the suffix added to rotated files once compressed.
+const compressedSuffix string = ".gz"
+
+// filePerms is the permissions used when creating new files.
+const filePerms os.FileMode = 0o640
+
+// Options controls how a File is rotated.
+type Options struct {
+
+	// Path is the path to the active file.
+	Path string
+
+	// MaxSize is the maximum size in bytes of the active file before it is
+	// rotated. A value of 0 disables size-based rotation.
+	MaxSize int64
+
+	// MaxAge is the maximum amount of time the active file is written to
+	// before it is rotated. A value of 0 disables age-based rotation.
+	MaxAge time.Duration
+
+	// MaxBackups is the maximum number of rotated files to retain. A value
+	// of 0 retains all rotated files.
+	MaxBackups int
+
+	// Compress indicates whether rotated files are compressed using gzip.
+	Compress bool
+}
+
+// File is an io.Writer which writes to the file specified by Options.Path,
+// rotating the file as needed. File is safe for concurrent use.
+type File struct {
+	file   *os.File
+	opened time.Time
+	opts   Options
+	size   int64
+	mu     sync.Mutex
+
+	// writeErr is the error returned by the most recent write, if any.
+	writeErr error
+
+	// rotateErr is the error returned by the most recent rotation, if any.
+	// Writes continue to the active file if rotation fails.
+	rotateErr error
+
+	// maintenance serializes compression and pruning of rotated files,
+	// which are performed in the background.
+	maintenance sync.Mutex
+	wg          sync.WaitGroup
+}
+
+// Open opens (or creates) the file specified by the given options for
+// appending.
+func Open(opts Options) (*File, error) {
+
+	if opts.Path == "" {
+		return nil, fmt.Errorf("file path not provided")
+	}
+
+	if opts.MaxSize < 0 || opts.MaxAge < 0 || opts.MaxBackups < 0 {
+		return nil, fmt.Errorf("invalid rotation settings for %s: %+v", opts.Path, opts)
+	}
+
+	f := File{opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// open opens the active file. The caller is responsible for holding the lock.
+func (f *File) open() error {
+
+	file, err := os.OpenFile(f.opts.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, filePerms)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.opts.Path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.opts.Path, err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+
+	return nil
+}
+
+// Write writes the given bytes to the active file, rotating the file first
+// if writing the bytes would exceed the maximum size or if the file has
+// reached the maximum age.
+func (f *File) Write(p []byte) (int, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	// Rotation is attempted again on the next write if it fails. The data
+	// is still written as long as the active file remains open.
+	if f.rotationDue(int64(len(p))) {
+		if err := f.rotate(); err != nil && f.file == nil {
+			f.writeErr = err
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	f.writeErr = err
+
+	return n, err
+}
+
+// Writable indicates whether the file is expected to accept further writes.
+// An error is returned if the file is closed, if the most recent write or
+// rotation failed or if a new file cannot be created in the directory
+// containing the file (as is required for rotation). No data is written to
+// the file.
+func (f *File) Writable() error {
+
+	f.mu.Lock()
+	closed, writeErr, rotateErr := f.file == nil, f.writeErr, f.rotateErr
+	f.mu.Unlock()
+
+	switch {
+	case closed:
+		return fmt.Errorf("%s: %w", f.opts.Path, os.ErrClosed)
+	case writeErr != nil:
+		return fmt.Errorf("most recent write to %s failed: %w", f.opts.Path, writeErr)
+	case rotateErr != nil:
+		return fmt.Errorf("most recent rotation of %s failed: %w", f.opts.Path, rotateErr)
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(f.opts.Path), "."+filepath.Base(f.opts.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create file alongside %s: %w", f.opts.Path, err)
+	}
+
+	closeErr := probe.Close()
+	if err := os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", probe.Name(), err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close %s: %w", probe.Name(), closeErr)
+	}
+
+	return nil
+}
+
+// rotationDue indicates whether the active file should be rotated before
+// writing the given number of bytes. Empty files are never rotated.
+func (f *File) rotationDue(n int64) bool {
+
+	if f.size == 0 {
+		return false
+	}
+
+	if f.opts.MaxSize > 0 && f.size+n > f.opts.MaxSize {
+		return true
+	}
+
+	return f.opts.MaxAge > 0 && time.Since(f.opened) >= f.opts.MaxAge
+}
+
+// Rotate closes the active file, renames it using a timestamp suffix and
+// opens a new active file.
+func (f *File) Rotate() error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+
+	return f.rotate()
+}
+
+// rotate performs rotation of the active file. The caller is responsible for
+// holding the lock. If rotation fails (e.g., the active file cannot be
+// renamed due to permissions or because it has been removed), the original
+// path is reopened so that writes may continue.
+func (f *File) rotate() error {
+
+	fail := func(err error) error {
+		f.rotateErr = err
+		if f.file == nil {
+			if openErr := f.open(); openErr != nil {
+				return fmt.Errorf("%w; %w", err, openErr)
+			}
+		}
+		return err
+	}
+
+	// The file is closed before being renamed as open files cannot be
+	// renamed on Windows.
+	closeErr := f.file.Close()
+	f.file = nil
+	if closeErr != nil {
+		return fail(fmt.Errorf("failed to close %s: %w", f.opts.Path, closeErr))
+	}
+
+	backupPath := f.opts.Path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(f.opts.Path, backupPath); err != nil {
+		return fail(fmt.Errorf("failed to rename %s: %w", f.opts.Path, err))
+	}
+
+	if err := f.open(); err != nil {
+		f.rotateErr = err
+		return err
+	}
+	f.rotateErr = nil
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.maintain(backupPath)
+	}()
+
+	return nil
+}
+
+// Reopen closes and reopens the active file. This is intended for use after
+// the file has been moved by an external tool (e.g., logrotate).
+func (f *File) Reopen() error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("failed to close %s: %w", f.opts.Path, err)
+		}
+		f.file = nil
+	}
+
+	return f.open()
+}
+
+// Close closes the active file and waits for any background compression or
+// pruning of rotated files to complete.
+func (f *File) Close() error {
+
+	f.mu.Lock()
+
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+
+	f.mu.Unlock()
+
+	// Background maintenance may log errors, potentially to this file, so
+	// the lock is not held while waiting.
+	f.wg.Wait()
+
+	return err
+}
+
+// Path returns the path to the active file.
+func (f *File) Path() string {
+	return f.opts.Path
+}
+
+// maintain compresses the given rotated file (if enabled) and prunes rotated
+// files exceeding the maximum number of rotated files to retain.
+func (f *File) maintain(backupPath string) {
+
+	f.maintenance.Lock()
+	defer f.maintenance.Unlock()
+
+	if f.opts.Compress {
+		if err := compressFile(backupPath); err != nil {
+			log.Errorf("logfile: failed to compress %s: %v", backupPath, err)
+		}
+	}
+
+	if f.opts.MaxBackups == 0 {
+		return
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		log.Errorf("logfile: failed to list rotated files for %s: %v", f.opts.Path, err)
+		return
+	}
+
+	if len(backups) <= f.opts.MaxBackups {
+		return
+	}
+
+	for _, backup := range backups[:len(backups)-f.opts.MaxBackups] {
+		if err := os.Remove(backup); err != nil {
+			log.Errorf("logfile: failed to remove %s: %v", backup, err)
+		}
+	}
+}
+
+// backups returns the paths to all rotated files for the active file, oldest
+// first.
+func (f *File) backups() ([]string, error) {
+
+	dir := filepath.Dir(f.opts.Path)
+	prefix := filepath.Base(f.opts.Path) + "."
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), compressedSuffix)
+		if _, err := time.Parse(backupTimeFormat, timestamp); err != nil {
+			continue
+		}
+
+		backups = append(backups, filepath.Join(dir, name))
+	}
+
+	// Sort by timestamp, ignoring the compression suffix.
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], compressedSuffix) <
+			strings.TrimSuffix(backups[j], compressedSuffix)
+	})
+
+	return backups, nil
+}
+
+// compressFile compresses the given file using gzip and removes the original
+// once complete.
+func compressFile(path string) error {
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path+compressedSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePerms)
+	if err != nil {
+		_ = src.Close()
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, copyErr := io.Copy(gz, src)
+	gzErr := gz.Close()
+	dstErr := dst.Close()
+	srcErr := src.Close()
+
+	for _, err := range []error{copyErr, gzErr, dstErr} {
+		if err != nil {
+			_ = os.Remove(path + compressedSuffix)
+			return err
+		}
+	}
+
+	if srcErr != nil {
+		return srcErr
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package loghandler provides apex/log handlers for system logging services
+which are not provided by the apex/log package itself.
+
+The Syslog handler sends RFC 5424 formatted messages to a local or remote
+syslog daemon over a unix socket, UDP or TCP. The Journald handler sends
+structured entries to the systemd journal using its native protocol; log
+entry fields are recorded as journal fields.
+*/
+package loghandler
@@ -0,0 +1,133 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package loghandler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+)
+
+// JournaldSocket is the path to the systemd journal native protocol socket.
+const JournaldSocket string = "/run/systemd/journal/socket"
+
+// Journald is an apex/log handler which sends entries to the systemd journal
+// using the native journal protocol. Log entry fields are recorded as journal
+// fields using upper case names (e.g., request_id is recorded as
+// REQUEST_ID).
+type Journald struct {
+	conn       net.Conn
+	identifier string
+	mu         sync.Mutex
+}
+
+// NewJournald creates a new Journald handler which records entries using
+// the given syslog identifier.
+func NewJournald(identifier string) (*Journald, error) {
+
+	conn, err := net.Dial("unixgram", JournaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to systemd journal: %w", err)
+	}
+
+	return &Journald{
+		conn:       conn,
+		identifier: identifier,
+	}, nil
+}
+
+// HandleLog implements log.Handler.
+func (h *Journald) HandleLog(e *log.Entry) error {
+
+	var buf bytes.Buffer
+
+	writeJournalField(&buf, "MESSAGE", e.Message)
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(severity(e.Level)))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", h.identifier)
+
+	for _, name := range e.Fields.Names() {
+		writeJournalField(&buf, journalFieldName(name), fmt.Sprint(e.Fields.Get(name)))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conn == nil {
+		return fmt.Errorf("journald handler is closed")
+	}
+
+	_, err := h.conn.Write(buf.Bytes())
+
+	return err
+}
+
+// Close closes the connection to the systemd journal. Log entries handled
+// after Close are not sent.
+func (h *Journald) Close() error {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conn == nil {
+		return nil
+	}
+
+	err := h.conn.Close()
+	h.conn = nil
+
+	return err
+}
+
+// writeJournalField writes a field using the native journal protocol. Values
+// containing newlines use the binary length-prefixed form.
+func writeJournalField(buf *bytes.Buffer, name string, value string) {
+
+	buf.WriteString(name)
+
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts a log entry field name to a valid journal field
+// name. Journal field names may only contain upper case letters, digits and
+// underscores, and must not start with an underscore or digit (fields
+// starting with an underscore are reserved for trusted fields).
+func journalFieldName(name string) string {
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "FIELD_" + name
+	}
+
+	return name
+}
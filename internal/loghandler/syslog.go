@@ -0,0 +1,325 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package loghandler
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/go-logfmt/logfmt"
+)
+
+// Syslog transports supported by the Syslog handler.
+const (
+	NetworkUnix string = "unix"
+	NetworkUDP  string = "udp"
+	NetworkTCP  string = "tcp"
+)
+
+// DefaultSyslogAddress is the default address used by the Syslog handler,
+// the local syslog socket.
+const DefaultSyslogAddress string = "unix:///dev/log"
+
+// syslogDialTimeout is the timeout applied when connecting to the syslog
+// daemon.
+const syslogDialTimeout time.Duration = 5 * time.Second
+
+// syslogTimestampFormat is the RFC 5424 timestamp format (RFC 3339 with
+// microsecond precision).
+const syslogTimestampFormat string = "2006-01-02T15:04:05.000000Z07:00"
+
+// nilValue is used by RFC 5424 to indicate that a header field is not
+// provided.
+const nilValue string = "-"
+
+// facilities maps syslog facility names to their RFC 5424 codes.
+var facilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// ValidFacility indicates whether the given syslog facility name is
+// supported.
+func ValidFacility(name string) bool {
+	_, ok := facilities[name]
+
+	return ok
+}
+
+// ParseSyslogAddress parses a syslog address in the form of
+// unix:///path/to/socket, udp://host:port or tcp://host:port and returns the
+// network and address for use with net.Dial.
+func ParseSyslogAddress(address string) (string, string, error) {
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid syslog address %q: %w", address, err)
+	}
+
+	switch u.Scheme {
+	case NetworkUnix:
+		if u.Path == "" {
+			return "", "", fmt.Errorf("invalid syslog address %q: socket path not provided", address)
+		}
+		return NetworkUnix, u.Path, nil
+
+	case NetworkUDP, NetworkTCP:
+		if u.Host == "" || u.Port() == "" {
+			return "", "", fmt.Errorf("invalid syslog address %q: host and port required", address)
+		}
+		return u.Scheme, u.Host, nil
+
+	default:
+		return "", "", fmt.Errorf(
+			"invalid syslog address %q: unsupported network %q; use %s, %s or %s",
+			address,
+			u.Scheme,
+			NetworkUnix,
+			NetworkUDP,
+			NetworkTCP,
+		)
+	}
+}
+
+// Syslog is an apex/log handler which sends RFC 5424 formatted messages to a
+// syslog daemon. Log entry fields are appended to the message in logfmt
+// format.
+type Syslog struct {
+	conn     net.Conn
+	network  string
+	address  string
+	hostname string
+	appName  string
+	facility int
+	pid      int
+	closed   bool
+	mu       sync.Mutex
+}
+
+// NewSyslog creates a new Syslog handler which sends messages to the given
+// syslog address (see ParseSyslogAddress) using the given facility and
+// application name.
+func NewSyslog(address string, facility string, appName string) (*Syslog, error) {
+
+	network, addr, err := ParseSyslogAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	code, ok := facilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("unsupported syslog facility %q", facility)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = nilValue
+	}
+
+	h := Syslog{
+		network:  network,
+		address:  addr,
+		hostname: hostname,
+		appName:  appName,
+		facility: code,
+		pid:      os.Getpid(),
+	}
+
+	if err := h.connect(); err != nil {
+		return nil, err
+	}
+
+	return &h, nil
+}
+
+// connect establishes a connection to the syslog daemon. Local unix sockets
+// are usually datagram sockets, but stream sockets are also supported. The
+// caller is responsible for holding the lock.
+func (h *Syslog) connect() error {
+
+	if h.conn != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+	}
+
+	networks := []string{h.network}
+	if h.network == NetworkUnix {
+		networks = []string{"unixgram", "unix"}
+	}
+
+	var lastErr error
+	for _, network := range networks {
+		conn, err := net.DialTimeout(network, h.address, syslogDialTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		h.network = network
+		h.conn = conn
+
+		return nil
+	}
+
+	return fmt.Errorf("failed to connect to syslog daemon at %s: %w", h.address, lastErr)
+}
+
+// HandleLog implements log.Handler.
+func (h *Syslog) HandleLog(e *log.Entry) error {
+
+	msg := h.format(e)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return fmt.Errorf("syslog handler for %s is closed", h.address)
+	}
+
+	// Attempt to reconnect once if the syslog daemon has been restarted.
+	if err := h.write(msg); err != nil {
+		if err := h.connect(); err != nil {
+			return err
+		}
+		return h.write(msg)
+	}
+
+	return nil
+}
+
+// Close closes the connection to the syslog daemon. Log entries handled
+// after Close are not sent.
+func (h *Syslog) Close() error {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	if h.conn == nil {
+		return nil
+	}
+
+	err := h.conn.Close()
+	h.conn = nil
+
+	return err
+}
+
+// write writes the given message using the framing appropriate for the
+// transport. The caller is responsible for holding the lock.
+func (h *Syslog) write(msg []byte) error {
+
+	if h.conn == nil {
+		return fmt.Errorf("not connected to syslog daemon at %s", h.address)
+	}
+
+	var err error
+	switch h.network {
+	case NetworkTCP:
+		// RFC 6587 octet counting
+		_, err = fmt.Fprintf(h.conn, "%d %s", len(msg), msg)
+	case "unix":
+		_, err = h.conn.Write(append(msg, '\n'))
+	default:
+		_, err = h.conn.Write(msg)
+	}
+
+	return err
+}
+
+// format formats the given log entry as an RFC 5424 message.
+func (h *Syslog) format(e *log.Entry) []byte {
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(
+		&buf,
+		"<%d>1 %s %s %s %s %s %s %s",
+		h.facility*8+severity(e.Level),
+		e.Timestamp.Format(syslogTimestampFormat),
+		h.hostname,
+		headerValue(h.appName),
+		strconv.Itoa(h.pid),
+		nilValue,
+		nilValue,
+		e.Message,
+	)
+
+	if names := e.Fields.Names(); len(names) > 0 {
+		buf.WriteByte(' ')
+		enc := logfmt.NewEncoder(&buf)
+		for _, name := range names {
+			if err := enc.EncodeKeyval(name, e.Fields.Get(name)); err != nil {
+				_ = enc.EncodeKeyval(name, fmt.Sprint(e.Fields.Get(name)))
+			}
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// severity maps apex/log levels to RFC 5424 severity codes.
+func severity(level log.Level) int {
+	switch level {
+	case log.DebugLevel:
+		return 7
+	case log.InfoLevel:
+		return 6
+	case log.WarnLevel:
+		return 4
+	case log.ErrorLevel:
+		return 3
+	case log.FatalLevel:
+		return 2
+	default:
+		return 5
+	}
+}
+
+// headerValue returns the given value in a form suitable for use as an RFC
+// 5424 header field; only printable US-ASCII characters are permitted.
+func headerValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+
+	if value == "" {
+		return nilValue
+	}
+
+	return value
+}
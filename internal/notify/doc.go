@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package notify provides the notifications manager used by bounce to send
+notifications (e.g., Microsoft Teams messages) for captured client requests.
+
+StartManager receives captured client requests via a work queue, applies the
+notification rate limit and hands each request off to the enabled notifiers,
+recording the outcome of each notification in a capture.Store. The state of
+the notifications manager is available via Status.
+*/
+package notify
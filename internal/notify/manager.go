@@ -0,0 +1,1220 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/ratelimit"
+)
+
+// Result wraps the results of notification operations to make it easier
+// to inspect the status of various tasks so that we can take action on either
+// error or success conditions
+type Result struct {
+
+	// Err is the error condition message to return from a notification
+	// operation
+	Err error
+
+	// Val is the non-error condition message to return from a notification
+	// operation
+	Val string
+
+	// RequestID is the ID of the client request the notification was
+	// generated for, if any.
+	RequestID string
+
+	// CaptureID is the capture ID of the client request the notification
+	// was generated for, if any.
+	CaptureID string
+
+	// Notifier is the name of the notifier which generated the result.
+	Notifier string
+
+	// Success indicates whether the notification attempt succeeded or if it
+	// failed for one reason or another (remote API, timeout, cancellation,
+	// etc)
+	Success bool
+}
+
+// Queue represents a channel used to queue input data and responses
+// between the main application, the notifications manager and "notifiers".
+type Queue struct {
+
+	// Channel is a channel used to transport input data and responses.
+	Channel interface{} `json:"-"`
+
+	// The name of a queue. This is intended for display in log messages or
+	// other output to identify queues with pending items.
+	Name string `json:"name"`
+
+	// Count is the number of items currently in the queue
+	Count int `json:"count"`
+
+	// Capacity is the maximum number of items allowed in the queue
+	Capacity int `json:"capacity"`
+}
+
+// measure returns a copy of the Queue with the current Count and
+// Capacity of the queue channel.
+func (nq Queue) measure() Queue {
+
+	switch queue := nq.Channel.(type) {
+
+	// FIXME: Is there a generic way to match any channel type
+	// here in order to calculate the length?
+	case chan capture.Request:
+		nq.Count = len(queue)
+		nq.Capacity = cap(queue)
+
+	case <-chan capture.Request:
+		nq.Count = len(queue)
+		nq.Capacity = cap(queue)
+
+	case chan Result:
+		nq.Count = len(queue)
+		nq.Capacity = cap(queue)
+
+	case chan Stats:
+		nq.Count = len(queue)
+		nq.Capacity = cap(queue)
+
+	default:
+		log.Warn("Default case triggered (this should not happen")
+		log.Warnf(
+			"Unhandled channel: [Name: %s, Type: %T]",
+			nq.Name, nq,
+		)
+
+	}
+
+	return nq
+}
+
+// Stats is a collection of stats for Teams and Email notifications
+type Stats struct {
+
+	// These fields are collected directly
+	IncomingMsgReceived int `json:"incoming_msg_received"`
+	RateLimitedMsg      int `json:"rate_limited_msg"`
+	PausedMsg           int `json:"paused_msg"`
+	TeamsMsgSent        int `json:"teams_msg_sent"`
+	TeamsMsgSuccess     int `json:"teams_msg_success"`
+	TeamsMsgFailure     int `json:"teams_msg_failure"`
+	EmailMsgSent        int `json:"email_msg_sent"`
+	EmailMsgSuccess     int `json:"email_msg_success"`
+	EmailMsgFailure     int `json:"email_msg_failure"`
+
+	// These fields are calculated from collected field values
+	TeamsMsgPending int `json:"teams_msg_pending"`
+	EmailMsgPending int `json:"email_msg_pending"`
+
+	TotalPendingMsg int `json:"total_pending_msg"`
+	TotalSuccessMsg int `json:"total_success_msg"`
+	TotalFailureMsg int `json:"total_failure_msg"`
+}
+
+// summaryDue indicates whether the given summary should be sent. A summary is
+// due once suppression has stopped for a quiet period, or once the summary
+// has been held back for the maximum delay while suppression is ongoing.
+func summaryDue(summary *capture.RateLimitSummary, now time.Time) bool {
+	return now.Sub(summary.Last) >= config.NotifyRateLimitSummaryQuietPeriod ||
+		now.Sub(summary.First) >= config.NotifyRateLimitSummaryMaxDelay
+}
+
+// newNotifyScheduler takes a time.Duration value as a delay and returns a
+// function that can be used to generate a new notification schedule. Each
+// call to this function will produce a new schedule incremented by the
+// time.Duration delay value. The intent is to provide an easy to use
+// mechanism for delaying notifications to remote systems (e.g., in order to
+// respect remote API limits).
+func newNotifyScheduler(delay time.Duration) func() time.Time {
+
+	log.Debugf("newNotifyScheduler: Initializing lastNotificationSchedule at %s",
+		time.Now().Format("15:04:05"),
+	)
+	lastNotificationSchedule := time.Now()
+
+	return func() time.Time {
+
+		// if we haven't sent a message in a while we should make ensure
+		// that we do not return a "next schedule" that has already passed
+		if !lastNotificationSchedule.After(time.Now()) {
+
+			expiredSchedule := lastNotificationSchedule.Add(delay)
+
+			log.Debugf(
+				"Expired next schedule: [Now: %v, Last: %v, Next: %v]",
+				time.Now().Format("15:04:05.000"),
+				lastNotificationSchedule.Format("15.04:05.000"),
+				expiredSchedule.Format("15:04:05.000"),
+			)
+
+			replacementSchedule := time.Now().Add(delay)
+
+			log.Debugf(
+				"Replace expired schedule (%v) by resetting the schedule to now (%v) + delay (%v): %v",
+				expiredSchedule.Format("15:04:05.000"),
+				time.Now().Format("15:04:05.000"),
+				delay,
+				replacementSchedule.Format("15:04:05"),
+			)
+
+			lastNotificationSchedule = replacementSchedule
+
+			return replacementSchedule
+		}
+
+		nextSchedule := lastNotificationSchedule.Add(delay)
+
+		log.Debugf(
+			"Next schedule not expired: [Last: %v, Now: %v, Next: %v]",
+			lastNotificationSchedule.Format("15:04:05"),
+			time.Now().Format("15:04:05"),
+			nextSchedule.Format("15:04:05"),
+		)
+
+		lastNotificationSchedule = nextSchedule
+
+		return nextSchedule
+	}
+}
+
+// notifyStatsMonitor accepts a context, a delay and a channel for Stats
+// values in order to collect and emit summary information for notifications.
+// The collected stats are also recorded in the given Status.
+// This function is intended to be run as a goroutine.
+func notifyStatsMonitor(ctx context.Context, delay time.Duration, statsQueue <-chan Stats, status *Status) {
+
+	log.Debug("notifyStatsMonitor: Running")
+
+	// this will be populated using values received on statsQueue
+	stats := Stats{}
+
+	for {
+		t := time.NewTimer(delay)
+
+		// log.Debug("notifyQueueMonitor: Starting loop")
+
+		// block until:
+		//	- context cancellation
+		//	- timer fires
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			log.Debugf(
+				"notifyStatsMonitor: Received Done signal: %v, shutting down ...",
+				ctx.Err().Error(),
+			)
+
+			return
+
+		// emit stats summary here
+		case <-t.C:
+
+			ctxLog := log.WithFields(log.Fields{
+				"timestamp":  time.Now().Format("15:04:05"),
+				"emit_stats": delay,
+			})
+
+			// ctxLog.Infof(
+			// 	"notifyStatsMonitor: "+
+			// 		"Total: [%d received, %d pending, %d success, %d failure], "+
+			// 		"Teams: [%d total, %d pending, %d success, %d failure], "+
+			// 		"Email: [%d total, %d pending, %d success, %d failure]",
+			// 	stats.IncomingMsgReceived,
+			// 	stats.TotalPendingMsg,
+			// 	stats.TotalSuccessMsg,
+			// 	stats.TotalFailureMsg,
+
+			// 	stats.TeamsMsgSent,
+			// 	stats.TeamsMsgPending,
+			// 	stats.TeamsMsgSuccess,
+			// 	stats.TeamsMsgFailure,
+
+			// 	stats.EmailMsgSent,
+			// 	stats.EmailMsgPending,
+			// 	stats.EmailMsgSuccess,
+			// 	stats.EmailMsgFailure,
+			// )
+
+			ctxLog.Infof(
+				"notifyStatsMonitor: Total: "+
+					"[%d received, %d rate limited, %d paused, %d pending, %d success, %d failure]",
+				stats.IncomingMsgReceived,
+				stats.RateLimitedMsg,
+				stats.PausedMsg,
+				stats.TotalPendingMsg,
+				stats.TotalSuccessMsg,
+				stats.TotalFailureMsg,
+			)
+
+			ctxLog.Infof(
+				"notifyStatsMonitor: Teams: "+
+					"[%d total, %d pending, %d success, %d failure]",
+				stats.TeamsMsgSent,
+				stats.TeamsMsgPending,
+				stats.TeamsMsgSuccess,
+				stats.TeamsMsgFailure,
+			)
+
+			ctxLog.Infof(
+				"notifyStatsMonitor: Email: "+
+					"[%d total, %d pending, %d success, %d failure]",
+				stats.EmailMsgSent,
+				stats.EmailMsgPending,
+				stats.EmailMsgSuccess,
+				stats.EmailMsgFailure,
+			)
+
+		// received stats update; update our totals
+		case statsUpdate := <-statsQueue:
+
+			stats.IncomingMsgReceived += statsUpdate.IncomingMsgReceived
+			stats.RateLimitedMsg += statsUpdate.RateLimitedMsg
+			stats.PausedMsg += statsUpdate.PausedMsg
+
+			stats.TeamsMsgSent += statsUpdate.TeamsMsgSent
+			stats.TeamsMsgSuccess += statsUpdate.TeamsMsgSuccess
+			stats.TeamsMsgFailure += statsUpdate.TeamsMsgFailure
+
+			stats.EmailMsgSent += statsUpdate.EmailMsgSent
+			stats.EmailMsgSuccess += statsUpdate.EmailMsgSuccess
+			stats.EmailMsgFailure += statsUpdate.EmailMsgFailure
+
+			// calculate non-collected stats here
+			stats.TeamsMsgPending = stats.TeamsMsgSent -
+				(stats.TeamsMsgSuccess + stats.TeamsMsgFailure)
+
+			stats.EmailMsgPending = stats.EmailMsgSent -
+				(stats.EmailMsgSuccess + stats.EmailMsgFailure)
+
+			stats.TotalPendingMsg = stats.EmailMsgPending + stats.TeamsMsgPending
+			stats.TotalFailureMsg = stats.EmailMsgFailure + stats.TeamsMsgFailure
+			stats.TotalSuccessMsg = stats.EmailMsgSuccess + stats.TeamsMsgSuccess
+
+			status.setStats(stats)
+
+		}
+	}
+}
+
+// notifyQueueMonitor accepts a context, a delay and one or many Queue
+// values to monitor for items yet to be processed. This function is intended
+// to be run as a goroutine.
+func notifyQueueMonitor(ctx context.Context, delay time.Duration, notifyQueues ...Queue) {
+
+	if len(notifyQueues) == 0 {
+		log.Error("received empty list of notifyQueues to monitor, exiting")
+		return
+	}
+
+	log.Debug("notifyQueueMonitor: Running")
+
+	for {
+
+		t := time.NewTimer(delay)
+
+		// log.Debug("notifyQueueMonitor: Starting loop")
+
+		// block until:
+		//	- context cancellation
+		//	- timer fires
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			log.Debugf(
+				"notifyQueueMonitor: Received Done signal: %v, shutting down ...",
+				ctx.Err().Error(),
+			)
+			return
+
+		case <-t.C:
+
+			// log.Debug("notifyQueueMonitor: Timer fired")
+
+			// NOTE: Not needed since the channel is already drained as a
+			// result of the case statement triggering and draining the
+			// channel
+			// t.Stop()
+
+			// Attempt to receive message count updates, proceed without them
+			// if they're not available
+
+			var itemsFound bool
+			// log.Debugf("Length of queues: %d", len(queues))
+			for _, notifyQueue := range notifyQueues {
+
+				notifyQueue = notifyQueue.measure()
+
+				// Show stats only for queues with content
+				if notifyQueue.Count > 0 {
+					itemsFound = true
+
+					log.WithField("timestamp", time.Now().Format("15:04:05")).Debugf(
+						"notifyQueueMonitor: %d/%d items in %s, %d goroutines running",
+						notifyQueue.Count,
+						notifyQueue.Capacity,
+						notifyQueue.Name,
+						runtime.NumGoroutine(),
+					)
+					continue
+				}
+
+			}
+
+			if !itemsFound {
+				log.WithField("timestamp", time.Now().Format("15:04:05")).Debugf(
+					"notifyQueueMonitor: 0 items queued, %d goroutines running",
+					runtime.NumGoroutine())
+			}
+		}
+	}
+}
+
+// teamsNotifierSettings are the settings used by teamsNotifier which may be
+// changed when the configuration is reloaded.
+type teamsNotifierSettings struct {
+	webhookURL   string
+	retries      int
+	retriesDelay int
+}
+
+// newTeamsNotifierSettings creates teamsNotifier settings from the given
+// configuration.
+func newTeamsNotifierSettings(cfg *config.Config) teamsNotifierSettings {
+	return teamsNotifierSettings{
+		webhookURL:   cfg.WebhookURL,
+		retries:      cfg.Retries,
+		retriesDelay: cfg.RetriesDelay,
+	}
+}
+
+// teamsNotifier is a persistent goroutine used to receive incoming
+// notification requests and spin off goroutines to create and send Microsoft
+// Teams messages. Updated settings received on settingsUpdates apply to
+// notification requests received afterwards.
+// TODO: Refactor per GH-37
+func teamsNotifier(
+	ctx context.Context,
+	settings teamsNotifierSettings,
+	settingsUpdates <-chan teamsNotifierSettings,
+	sendTimeout time.Duration,
+	sendDelay time.Duration,
+	incoming <-chan capture.Request,
+	notifyMgrResultQueue chan<- Result,
+	done chan<- struct{},
+) {
+
+	log.Debug("teamsNotifier: Running")
+
+	// used by goroutines called by this function to return results
+	ourResultQueue := make(chan Result)
+
+	// Setup new scheduler that we can use to add an intentional delay between
+	// Microsoft Teams notification attempts
+	// https://docs.microsoft.com/en-us/microsoftteams/platform/webhooks-and-connectors/how-to/connectors-using
+	notifyScheduler := newNotifyScheduler(sendDelay)
+
+	for {
+
+		select {
+
+		case <-ctx.Done():
+
+			ctxErr := ctx.Err()
+			result := Result{
+				Val: fmt.Sprintf("teamsNotifier: Received Done signal: %v, shutting down", ctxErr.Error()),
+			}
+			log.Debug(result.Val)
+
+			log.Debug("teamsNotifier: Sending back results")
+			notifyMgrResultQueue <- result
+
+			log.Debug("teamsNotifier: Closing notifyMgrResultQueue channel to signal shutdown")
+			close(notifyMgrResultQueue)
+
+			log.Debug("teamsNotifier: Closing done channel to signal shutdown")
+			close(done)
+			log.Debug("teamsNotifier: done channel closed, returning")
+			return
+
+		case settings = <-settingsUpdates:
+			log.Debug("teamsNotifier: Settings updated")
+
+		case clientRequest := <-incoming:
+
+			log.Debugf("teamsNotifier: Request received at %v: %#v",
+				time.Now(), clientRequest)
+
+			log.Debug("Calculating next scheduled notification")
+
+			nextScheduledNotification := notifyScheduler()
+
+			log.Debugf("Now: %v, Next scheduled notification: %v",
+				time.Now().Format("15:04:05"),
+				nextScheduledNotification.Format("15:04:05"),
+			)
+
+			timeoutValue := config.GetTimeout(
+				sendTimeout,
+				nextScheduledNotification,
+				settings.retries,
+				settings.retriesDelay,
+			)
+
+			ctx, cancel := context.WithTimeout(ctx, timeoutValue)
+			defer cancel()
+
+			log.Debugf("teamsNotifier: child context created with timeout duration %v", timeoutValue)
+
+			// if there is a message waiting *and* ctx.Done() case statements
+			// are both valid, either path could be taken. If this one is
+			// taken, then the message send timeout will be the only thing
+			// forcing the attempt to loop back around and trigger the
+			// ctx.Done() path, but only if this one isn't taken again by the
+			// random case selection logic
+			log.Debug("teamsNotifier: Checking context to determine whether we should proceed")
+
+			if ctx.Err() != nil {
+				result := Result{
+					Success:   false,
+					Val:       "teamsNotifier: context has been cancelled, aborting notification attempt",
+					RequestID: clientRequest.RequestID,
+					CaptureID: clientRequest.CaptureID,
+					Notifier:  config.NotifierTeams,
+				}
+				log.Debug(result.Val)
+				notifyMgrResultQueue <- result
+
+				continue
+			}
+
+			log.Debug("teamsNotifier: context not cancelled, proceeding with notification attempt")
+
+			// launch task in separate goroutine, each with its own schedule
+			log.Debug("teamsNotifier: Launching message creation/submission in separate goroutine")
+
+			go func(
+				ctx context.Context,
+				webhookURL string,
+				clientRequest capture.Request,
+				schedule time.Time,
+				numRetries int,
+				retryDelay int,
+				resultQueue chan<- Result) {
+
+				ourMessage := createMessage(clientRequest)
+				result := sendMessage(ctx, webhookURL, ourMessage, schedule, numRetries, retryDelay)
+				result.RequestID = clientRequest.RequestID
+				result.CaptureID = clientRequest.CaptureID
+				result.Notifier = config.NotifierTeams
+				resultQueue <- result
+
+			}(ctx, settings.webhookURL, clientRequest, nextScheduledNotification, settings.retries, settings.retriesDelay, ourResultQueue)
+
+		case result := <-ourResultQueue:
+			if result.Err != nil {
+				log.Errorf("teamsNotifier: Error received from ourResultQueue: %v", result.Err)
+			} else {
+				log.Debugf("teamsNotifier: OK: non-error status received on ourResultQueue: %v", result.Val)
+			}
+
+			notifyMgrResultQueue <- result
+
+		}
+
+	}
+
+}
+
+// emailNotifier is a persistent goroutine used to receive incoming
+// notification requests and spin off goroutines to create and send email
+// messages.
+// TODO: Refactor per GH-37
+func emailNotifier(
+	ctx context.Context,
+	sendTimeout time.Duration,
+	sendDelay time.Duration,
+	retries int,
+	retriesDelay int,
+	incoming <-chan capture.Request,
+	notifyMgrResultQueue chan<- Result,
+	done chan<- struct{},
+) {
+
+	log.Debug("emailNotifier: Running")
+
+	// used by goroutines called by this function to return results
+	ourResultQueue := make(chan Result)
+
+	// Setup new scheduler that we can use to add an intentional delay between
+	// email notification attempts
+	notifyScheduler := newNotifyScheduler(sendDelay)
+
+	for {
+
+		select {
+
+		case <-ctx.Done():
+
+			ctxErr := ctx.Err()
+			result := Result{
+				Val: fmt.Sprintf("emailNotifier: Received Done signal: %v, shutting down", ctxErr.Error()),
+			}
+			log.Debug(result.Val)
+
+			log.Debug("emailNotifier: Sending back results")
+			notifyMgrResultQueue <- result
+
+			log.Debug("emailNotifier: Closing notifyMgrResultQueue channel to signal shutdown")
+			close(notifyMgrResultQueue)
+
+			log.Debug("emailNotifier: Closing done channel to signal shutdown")
+			close(done)
+			log.Debug("emailNotifier: done channel closed, returning")
+			return
+
+		case clientRequest := <-incoming:
+
+			log.Debugf("emailNotifier: Request received at %v: %#v",
+				time.Now(), clientRequest)
+
+			log.Debug("Calculating next scheduled notification")
+
+			nextScheduledNotification := notifyScheduler()
+
+			log.Debugf("Now: %v, Next scheduled notification: %v",
+				time.Now().Format("15:04:05"),
+				nextScheduledNotification.Format("15:04:05"),
+			)
+
+			timeoutValue := config.GetTimeout(
+				sendTimeout,
+				nextScheduledNotification,
+				retries,
+				retriesDelay,
+			)
+
+			ctx, cancel := context.WithTimeout(ctx, timeoutValue)
+			defer cancel()
+
+			log.Debugf("emailNotifier: child context created with timeout duration %v", timeoutValue)
+
+			// if there is a message waiting *and* ctx.Done() case statements
+			// are both valid, either path could be taken. If this one is
+			// taken, then the message send timeout will be the only thing
+			// forcing the attempt to loop back around and trigger the
+			// ctx.Done() path, but only if this one isn't taken again by the
+			// random case selection logic
+			log.Debug("emailNotifier: Checking context to determine whether we should proceed")
+
+			if ctx.Err() != nil {
+				result := Result{
+					Success:   false,
+					Val:       "emailNotifier: context has been cancelled, aborting notification attempt",
+					RequestID: clientRequest.RequestID,
+					CaptureID: clientRequest.CaptureID,
+					Notifier:  config.NotifierEmail,
+				}
+				log.Debug(result.Val)
+				notifyMgrResultQueue <- result
+
+				continue
+			}
+
+			log.Debug("emailNotifier: context not cancelled, proceeding with notification attempt")
+
+			// launch task in separate goroutine, each with its own schedule
+			log.Debug("emailNotifier: Launching message creation/submission in separate goroutine")
+
+			// launch task in a separate goroutine
+			// FIXME: Implement most of the same parameters here as with the
+			// goroutine in teamsNotifier, pass ctx for email function to use.
+			go func(clientRequest capture.Request, resultQueue chan<- Result) {
+				result := Result{
+					Err:       fmt.Errorf("emailNotifier: Sending email is not currently supported"),
+					RequestID: clientRequest.RequestID,
+					CaptureID: clientRequest.CaptureID,
+					Notifier:  config.NotifierEmail,
+				}
+				log.Error(result.Err.Error())
+				resultQueue <- result
+			}(clientRequest, ourResultQueue)
+
+		case result := <-ourResultQueue:
+
+			if result.Err != nil {
+				log.Errorf("emailNotifier: Error received from ourResultQueue: %v", result.Err)
+			} else {
+				log.Debugf("emailNotifier: OK: non-error status received on ourResultQueue: %v", result.Val)
+			}
+
+			notifyMgrResultQueue <- result
+
+		}
+	}
+
+}
+
+// FlushRequest asks the notification manager to send pending
+// notifications before shutdown. The done channel is closed once all pending
+// notifications have been processed or the timeout has been reached.
+type FlushRequest struct {
+	Done    chan<- struct{}
+	Timeout time.Duration
+}
+
+// pendingNotification identifies a notification handed off to a notifier for
+// which no result has been received yet.
+type pendingNotification struct {
+	captureID string
+	notifier  string
+}
+
+// notifyRateLimitKey is the key used with the notification rate limiter. A
+// single key is used as the limit applies to all notifications.
+const notifyRateLimitKey string = "notifications"
+
+// StartManager receives capture.Request values and sends notifications
+// to any enabled service (e.g., Microsoft Teams). The outcome of each
+// notification is recorded in the request history. Notification stats and
+// queues are recorded in the given Status, which is also used to pause
+// notifications. Reloaded configurations
+// received on cfgUpdates replace the notifier settings and notification rate
+// limit; notifications already queued are sent using the previous settings.
+// Pending notifications are sent before shutdown if requested via
+// flushRequests; otherwise they are discarded once the context is cancelled.
+func StartManager(
+	ctx context.Context,
+	cfg *config.Config,
+	cfgUpdates <-chan *config.Config,
+	flushRequests <-chan FlushRequest,
+	reqHistory *capture.Store,
+	status *Status,
+	notifyWorkQueue <-chan capture.Request,
+	done chan<- struct{},
+) {
+
+	log.Debug("StartManager: Running")
+
+	status.setRunning(true)
+	defer status.setRunning(false)
+
+	// TODO: Refactor as part of GH-37
+	//
+	// Create separate, buffered channels to hand-off capture.Request
+	// values for processing for each service, e.g., one channel for Microsoft
+	// Teams outgoing notifications, another for email and so on. Buffered
+	// channels are used both to enable async tasks and to provide a means of
+	// monitoring the number of items queued for each channel; unbuffered
+	// channels have a queue depth (and thus length) of 0.
+	teamsNotifyWorkQueue := make(chan capture.Request, config.NotifyMgrQueueDepth)
+	teamsNotifyResultQueue := make(chan Result, config.NotifyMgrQueueDepth)
+	teamsNotifyDone := make(chan struct{})
+	teamsSettingsUpdates := make(chan teamsNotifierSettings, 1)
+
+	emailNotifyWorkQueue := make(chan capture.Request, config.NotifyMgrQueueDepth)
+	emailNotifyResultQueue := make(chan Result, config.NotifyMgrQueueDepth)
+	emailNotifyDone := make(chan struct{})
+
+	notifyStatsQueue := make(chan Stats, 1)
+
+	if !cfg.NotifyTeams() && !cfg.NotifyEmail() {
+		log.Debug("StartManager: Teams and email notifications not requested, not starting notifier goroutines")
+		// NOTE: Do not return/exit here.
+		//
+		// We cannot return/exit the function here because StartManager HAS
+		// to run in order to keep the notifyWorkQueue from filling up and
+		// blocking other parts of this application that send messages to this
+		// channel.
+	}
+
+	// The teamsNotifier goroutine is started once Teams notifications are
+	// enabled, either initially or by a reloaded configuration, and runs
+	// until shutdown.
+	var teamsNotifierRunning bool
+	startTeamsNotifier := func() {
+		log.Debug("StartManager: Teams notifications enabled")
+		log.Debug("StartManager: Starting up teamsNotifier")
+		go teamsNotifier(
+			ctx,
+			newTeamsNotifierSettings(cfg),
+			teamsSettingsUpdates,
+			config.NotifyMgrTeamsTimeout,
+			config.NotifyMgrTeamsNotificationDelay,
+			teamsNotifyWorkQueue,
+			teamsNotifyResultQueue,
+			teamsNotifyDone,
+		)
+		teamsNotifierRunning = true
+	}
+
+	// If enabled, start persistent goroutine to process request details and
+	// submit messages to Microsoft Teams.
+	if cfg.NotifyTeams() {
+		startTeamsNotifier()
+	}
+
+	// If enabled, start persistent goroutine to process request details and
+	// submit messages by email.
+	if cfg.NotifyEmail() {
+		log.Debug("StartManager: Email notifications enabled")
+		log.Debug("StartManager: Starting up emailNotifier")
+		go emailNotifier(
+			ctx,
+			config.NotifyMgrEmailTimeout,
+			config.NotifyMgrEmailNotificationDelay,
+			cfg.Retries,
+			cfg.RetriesDelay,
+			emailNotifyWorkQueue,
+			emailNotifyResultQueue,
+			emailNotifyDone,
+		)
+	}
+
+	// Monitor queues and report stats for each, even if the user has not
+	// opted to use notifications. This is done since we are tracking at least
+	// one queue (notifyStatsQueue) which is active even with notifiers
+	// disabled.
+	queuesToMonitor := []Queue{
+		{
+			Name:    "notifyWorkQueue",
+			Channel: notifyWorkQueue,
+		},
+		{
+			Name:    "emailNotifyWorkQueue",
+			Channel: emailNotifyWorkQueue,
+		},
+		{
+			Name:    "emailNotifyResultQueue",
+			Channel: emailNotifyResultQueue,
+		},
+		{
+			Name:    "teamsNotifyWorkQueue",
+			Channel: teamsNotifyWorkQueue,
+		},
+		{
+			Name:    "teamsNotifyResultQueue",
+			Channel: teamsNotifyResultQueue,
+		},
+		{
+			Name:    "notifyStatsQueue",
+			Channel: notifyStatsQueue,
+		},
+	}
+
+	status.setQueues(queuesToMonitor)
+
+	// periodically print current queue items
+	go notifyQueueMonitor(
+		ctx,
+		config.NotifyQueueMonitorDelay,
+		queuesToMonitor...,
+	)
+
+	// collect and periodically emit summary of notification details
+	go notifyStatsMonitor(
+		ctx,
+		config.NotifyStatsMonitorDelay,
+		notifyStatsQueue,
+		status,
+	)
+
+	// Notifications exceeding this limit are suppressed and summarized.
+	notifyLimiter := ratelimit.NewLimiter(cfg.NotifyRateLimit, cfg.NotifyRateLimitBurst)
+	var suppressed *capture.RateLimitSummary
+
+	summaryTicker := time.NewTicker(config.NotifyRateLimitSummaryCheckInterval)
+	defer summaryTicker.Stop()
+
+	// Notifications handed off to notifiers are tracked, along with the
+	// request ID, until a result is received so that any not sent during
+	// shutdown can be reported.
+	pending := make(map[pendingNotification]string)
+
+	// Set while pending notifications are flushed during shutdown.
+	var flush *FlushRequest
+	var flushTimeout <-chan time.Time
+
+	// dispatch hands off the given capture.Request value to each enabled
+	// notifier.
+	dispatch := func(clientRequest capture.Request) {
+		if cfg.NotifyTeams() && clientRequest.NotifierSelected(config.NotifierTeams) {
+			log.Debug("StartManager: Creating new goroutine to place clientRequest into teamsNotifyWorkQueue")
+
+			// TODO: Perhaps record this *after* sending the clientRequest
+			// down the teamsNotifyWorkQueue channel? See other cases
+			// where we're using the same "record stat, then do it"
+			// approach.
+
+			pending[pendingNotification{clientRequest.CaptureID, config.NotifierTeams}] = clientRequest.RequestID
+
+			go func() {
+				notifyStatsQueue <- Stats{
+					TeamsMsgSent: 1,
+				}
+			}()
+
+			go func() {
+				log.Debugf("StartManager: Existing items in teamsNotifyWorkQueue: %d", len(teamsNotifyWorkQueue))
+				log.Debug("StartManager: Pending; placing clientRequest into teamsNotifyWorkQueue")
+				teamsNotifyWorkQueue <- clientRequest
+				log.Debug("StartManager: Done; placed clientRequest into teamsNotifyWorkQueue")
+				log.Debugf("StartManager: Items now in teamsNotifyWorkQueue: %d", len(teamsNotifyWorkQueue))
+			}()
+		}
+
+		if cfg.NotifyEmail() && clientRequest.NotifierSelected(config.NotifierEmail) {
+			log.Debug("StartManager: Creating new goroutine to place clientRequest in emailNotifyWorkQueue")
+
+			pending[pendingNotification{clientRequest.CaptureID, config.NotifierEmail}] = clientRequest.RequestID
+
+			go func() {
+				notifyStatsQueue <- Stats{
+					EmailMsgSent: 1,
+				}
+			}()
+
+			go func() {
+				log.Debugf("StartManager: Existing items in emailNotifyWorkQueue: %d", len(emailNotifyWorkQueue))
+				log.Debug("StartManager: Pending; placing clientRequest into emailNotifyWorkQueue")
+				emailNotifyWorkQueue <- clientRequest
+				log.Debug("StartManager: Done; placed clientRequest into emailNotifyWorkQueue")
+				log.Debugf("StartManager: Items now in emailNotifyWorkQueue: %d", len(emailNotifyWorkQueue))
+			}()
+		}
+	}
+
+	for {
+
+		status.beat()
+
+		if flush != nil && len(pending) == 0 && len(notifyWorkQueue) == 0 {
+			log.Info("StartManager: All pending notifications processed")
+			close(flush.Done)
+			flush, flushTimeout = nil, nil
+		}
+
+		select {
+
+		// NOTE: This should ONLY ever be done when shutting down the entire
+		// application, as otherwise goroutines associated with client
+		// requests will likely hang, likely until client/server timeout
+		// settings are reached
+		case <-ctx.Done():
+			ctxErr := ctx.Err()
+			log.Debugf("StartManager: Received Done signal: %v, shutting down ...", ctxErr.Error())
+
+			if suppressed != nil {
+				log.Warnf(
+					"StartManager: Discarding summary for %d notifications suppressed by rate limit",
+					suppressed.Count,
+				)
+			}
+
+			if len(pending) > 0 || len(notifyWorkQueue) > 0 {
+				log.Warnf(
+					"StartManager: Discarding %d pending and %d queued notifications",
+					len(pending),
+					len(notifyWorkQueue),
+				)
+			}
+
+			evalResults := func(queueName string, result Result) {
+				if result.Err != nil {
+					log.Errorf("StartManager: Error received from %s: %v", queueName, result.Err)
+					return
+				}
+				log.Debugf("StartManager: OK: non-error status received on %s: %v", queueName, result.Val)
+			}
+
+			// Process any waiting results before blocking and waiting
+			// on final completion response from notifier goroutines
+			if teamsNotifierRunning {
+				log.Debug("StartManager: Teams notifications are enabled, shutting down teamsNotifier")
+
+				log.Debug("StartManager: Ranging over teamsNotifyResultQueue")
+				for result := range teamsNotifyResultQueue {
+					evalResults("teamsNotifyResultQueue", result)
+				}
+
+				log.Debug("StartManager: Waiting on teamsNotifyDone")
+				select {
+				case <-teamsNotifyDone:
+					log.Debug("StartManager: Received from teamsNotifyDone")
+				case <-time.After(cfg.NotifyMgrServicesShutdownTimeout()):
+					log.Debug("StartManager: Timeout occurred while waiting for teamsNotifyDone")
+					log.Debug("StartManager: Proceeding with shutdown")
+				}
+
+			}
+
+			if cfg.NotifyEmail() {
+				log.Debug("StartManager: Email notifications are enabled, shutting down emailNotifier")
+
+				log.Debug("StartManager: Ranging over emailNotifyResultQueue")
+				for result := range emailNotifyResultQueue {
+					evalResults("emailNotifyResultQueue", result)
+				}
+
+				log.Debug("StartManager: Waiting on emailNotifyDone")
+				select {
+				case <-emailNotifyDone:
+					log.Debug("StartManager: Received from emailNotifyDone")
+				case <-time.After(cfg.NotifyMgrServicesShutdownTimeout()):
+					log.Debug("StartManager: Timeout occurred while waiting for emailNotifyDone")
+					log.Debug("StartManager: Proceeding with shutdown")
+				}
+
+			}
+
+			log.Debug("StartManager: Closing done channel")
+			close(done)
+
+			log.Debug("StartManager: About to return")
+			return
+
+		case req := <-flushRequests:
+
+			log.Infof(
+				"StartManager: Sending %d pending and %d queued notifications before shutdown (timeout %v)",
+				len(pending),
+				len(notifyWorkQueue),
+				req.Timeout,
+			)
+
+			flush = &req
+			flushTimeout = time.After(req.Timeout)
+
+			// Send any summary for suppressed notifications now rather than
+			// waiting for it to become due.
+			if suppressed != nil {
+				dispatch(capture.Request{
+					Datestamp:        time.Now().Format("2006-01-02 15:04:05"),
+					RateLimitSummary: suppressed,
+				})
+				suppressed = nil
+			}
+
+		case <-flushTimeout:
+
+			log.Warnf("StartManager: Timeout reached while sending pending notifications")
+
+			for notification, requestID := range pending {
+				log.WithFields(log.Fields{
+					"request_id": requestID,
+					"notifier":   notification.notifier,
+				}).Warn("StartManager: Dropped notification not sent before shutdown")
+			}
+			clear(pending)
+
+			for len(notifyWorkQueue) > 0 {
+				clientRequest := <-notifyWorkQueue
+				log.WithField("request_id", clientRequest.RequestID).
+					Warn("StartManager: Dropped queued notification not processed before shutdown")
+			}
+
+			close(flush.Done)
+			flush, flushTimeout = nil, nil
+
+		case newCfg := <-cfgUpdates:
+
+			// Rate limiter state is only reset if the limit has changed.
+			if newCfg.NotifyRateLimit != cfg.NotifyRateLimit ||
+				newCfg.NotifyRateLimitBurst != cfg.NotifyRateLimitBurst {
+				notifyLimiter = ratelimit.NewLimiter(newCfg.NotifyRateLimit, newCfg.NotifyRateLimitBurst)
+			}
+
+			cfg = newCfg
+
+			switch {
+			case cfg.NotifyTeams() && !teamsNotifierRunning:
+				startTeamsNotifier()
+
+			case teamsNotifierRunning:
+				// Replace any settings not yet applied by teamsNotifier;
+				// this is the only sender, so the send does not block.
+				select {
+				case <-teamsSettingsUpdates:
+				default:
+				}
+				teamsSettingsUpdates <- newTeamsNotifierSettings(cfg)
+			}
+
+			log.WithField("notifiers", strings.Join(cfg.EnabledNotifiers(), ", ")).
+				Info("StartManager: Applied reloaded configuration")
+
+		case clientRequest := <-notifyWorkQueue:
+
+			log.Debug("StartManager: Input received from notifyWorkQueue")
+
+			go func() {
+				notifyStatsQueue <- Stats{
+					IncomingMsgReceived: 1,
+				}
+			}()
+
+			// If we don't have *any* notifications enabled we will just
+			// discard the item we have pulled from the channel
+			if !cfg.NotifyEmail() && !cfg.NotifyTeams() {
+				log.Debug("StartManager: Notifications are not currently enabled; ignoring notification request")
+				continue
+			}
+
+			// Routes may limit (or disable) the notifiers used for requests
+			// they receive.
+			if len(clientRequest.NotifyTargets(cfg.EnabledNotifiers())) == 0 {
+				log.Debug("StartManager: No enabled notifiers selected for this request; ignoring notification request")
+				continue
+			}
+
+			// Notifications may be paused via the admin API; requests
+			// received while paused do not generate notifications.
+			if status.Paused() {
+				for _, notifier := range clientRequest.NotifyTargets(cfg.EnabledNotifiers()) {
+					reqHistory.SetNotifyOutcome(clientRequest.CaptureID, notifier, capture.NotifyOutcomePaused)
+				}
+				log.WithField("request_id", clientRequest.RequestID).
+					Debug("StartManager: Notifications paused; ignoring notification request")
+
+				go func() {
+					notifyStatsQueue <- Stats{
+						PausedMsg: 1,
+					}
+				}()
+
+				continue
+			}
+
+			// Suppress notifications exceeding the notification rate limit;
+			// these are reported later using a single summary notification.
+			if result := notifyLimiter.Allow(notifyRateLimitKey); !result.Allowed {
+				if suppressed == nil {
+					log.Warnf(
+						"StartManager: Notification rate limit exceeded; suppressing notifications (retry after %v)",
+						result.RetryAfter,
+					)
+					suppressed = capture.NewRateLimitSummary()
+				}
+				suppressed.Record(clientRequest)
+
+				for _, notifier := range clientRequest.NotifyTargets(cfg.EnabledNotifiers()) {
+					reqHistory.SetNotifyOutcome(clientRequest.CaptureID, notifier, capture.NotifyOutcomeRateLimited)
+				}
+				log.WithField("request_id", clientRequest.RequestID).
+					Debug("StartManager: Notification suppressed by rate limit")
+
+				go func() {
+					notifyStatsQueue <- Stats{
+						RateLimitedMsg: 1,
+					}
+				}()
+
+				continue
+			}
+
+			dispatch(clientRequest)
+
+		case <-summaryTicker.C:
+
+			if suppressed == nil || !summaryDue(suppressed, time.Now()) {
+				continue
+			}
+
+			log.Warnf(
+				"StartManager: Sending summary for %d notifications suppressed by rate limit",
+				suppressed.Count,
+			)
+
+			// Summary notifications are not subject to the rate limit.
+			dispatch(capture.Request{
+				Datestamp:        time.Now().Format("2006-01-02 15:04:05"),
+				RateLimitSummary: suppressed,
+			})
+			suppressed = nil
+
+		case result := <-teamsNotifyResultQueue:
+
+			delete(pending, pendingNotification{result.CaptureID, config.NotifierTeams})
+
+			statsUpdate := Stats{}
+
+			// NOTE: Only consider explicit success, not a non-error condition
+			// because cancellations and timeouts are (currently) treated as
+			// non-error, but they're not successful notifications.
+
+			ctxLog := log.WithFields(log.Fields{
+				"request_id": result.RequestID,
+				"notifier":   result.Notifier,
+			})
+
+			if !result.Success {
+				if result.Err != nil {
+					ctxLog.Errorf("StartManager: Error received from teamsNotifyResultQueue: %v", result.Err)
+				}
+				statsUpdate.TeamsMsgFailure = 1
+				status.recordAttempt(false)
+				reqHistory.SetNotifyOutcome(result.CaptureID, config.NotifierTeams, capture.NotifyOutcomeFailure)
+			}
+
+			if result.Success {
+				ctxLog.Debugf("StartManager: OK: non-error status received on teamsNotifyResultQueue: %v", result.Val)
+				ctxLog.Infof("StartManager: %v", result.Val)
+				statsUpdate.TeamsMsgSuccess = 1
+				status.recordAttempt(true)
+				reqHistory.SetNotifyOutcome(result.CaptureID, config.NotifierTeams, capture.NotifyOutcomeSuccess)
+			}
+
+			// log.Debugf("statsUpdate: %#v", statsUpdate)
+
+			go func() {
+				notifyStatsQueue <- statsUpdate
+			}()
+
+		case result := <-emailNotifyResultQueue:
+
+			delete(pending, pendingNotification{result.CaptureID, config.NotifierEmail})
+
+			statsUpdate := Stats{}
+
+			// NOTE: Only consider explicit success, not a non-error condition
+			// because cancellations and timeouts are (currently) treated as
+			// non-error, but they're not successful notifications.
+
+			ctxLog := log.WithFields(log.Fields{
+				"request_id": result.RequestID,
+				"notifier":   result.Notifier,
+			})
+
+			if !result.Success {
+				if result.Err != nil {
+					ctxLog.Errorf("StartManager: Error received from emailNotifyResultQueue: %v", result.Err)
+				}
+				statsUpdate.EmailMsgFailure = 1
+				status.recordAttempt(false)
+				reqHistory.SetNotifyOutcome(result.CaptureID, config.NotifierEmail, capture.NotifyOutcomeFailure)
+			}
+
+			if result.Success {
+				ctxLog.Debugf("StartManager: non-error status received on emailNotifyResultQueue: %v", result.Val)
+				ctxLog.Infof("StartManager: %v", result.Val)
+				statsUpdate.EmailMsgSuccess = 1
+				status.recordAttempt(true)
+				reqHistory.SetNotifyOutcome(result.CaptureID, config.NotifierEmail, capture.NotifyOutcomeSuccess)
+			}
+
+			go func() {
+				notifyStatsQueue <- statsUpdate
+			}()
+
+		}
+
+	}
+}
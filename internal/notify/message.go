@@ -5,15 +5,19 @@
 // Licensed under the MIT License. See LICENSE file in the project root for
 // full license information.
 
-package main
+package notify
 
 import (
 	"context"
 	"fmt"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
 	"github.com/atc0005/bounce/internal/config"
 
 	// use our fork for now until recent work can be submitted for inclusion
@@ -22,9 +26,13 @@ import (
 	"github.com/atc0005/go-teams-notify/v2/messagecard"
 )
 
-func createMessage(clientRequest clientRequestDetails) *messagecard.MessageCard {
+func createMessage(clientRequest capture.Request) *messagecard.MessageCard {
 
-	log.Debugf("createMessage: clientRequestDetails received: %#v", clientRequest)
+	log.Debugf("createMessage: capture.Request received: %#v", clientRequest)
+
+	if clientRequest.RateLimitSummary != nil {
+		return createRateLimitSummaryMessage(clientRequest.RateLimitSummary)
+	}
 
 	const ClientRequestErrorsRecorded = "Errors recorded for client request"
 	const ClientRequestErrorsNotFound = "No errors recorded for client request"
@@ -68,9 +76,28 @@ func createMessage(clientRequest clientRequestDetails) *messagecard.MessageCard
 	clientRequestSummarySection.StartGroup = true
 
 	addFactPair(msgCard, clientRequestSummarySection, "Received at", clientRequest.Datestamp)
+	addFactPair(msgCard, clientRequestSummarySection, "Request ID", clientRequest.RequestID)
 	addFactPair(msgCard, clientRequestSummarySection, "Endpoint path", clientRequest.EndpointPath)
+	for name, value := range clientRequest.PathParams {
+		addFactPair(msgCard, clientRequestSummarySection, "Path parameter "+name, value)
+	}
 	addFactPair(msgCard, clientRequestSummarySection, "HTTP Method", clientRequest.HTTPMethod)
+	addFactPair(msgCard, clientRequestSummarySection, "Protocol", clientRequest.Protocol)
+	addFactPair(msgCard, clientRequestSummarySection, "Connection reused", strconv.FormatBool(clientRequest.ConnectionReused))
+	if tlsState := clientRequest.TLS; tlsState != nil {
+		addFactPair(msgCard, clientRequestSummarySection, "TLS version", tlsState.Version)
+		addFactPair(msgCard, clientRequestSummarySection, "TLS cipher suite", tlsState.CipherSuite)
+		addFactPair(msgCard, clientRequestSummarySection, "TLS ALPN", tlsState.ALPN)
+	}
+	if injected := clientRequest.Fault; injected != nil {
+		addFactPair(msgCard, clientRequestSummarySection, "Injected fault", injected.Type)
+		if injected.LatencyMs > 0 {
+			addFactPair(msgCard, clientRequestSummarySection, "Injected latency", fmt.Sprintf("%dms", injected.LatencyMs))
+		}
+	}
 	addFactPair(msgCard, clientRequestSummarySection, "Client IP Address", clientRequest.ClientIPAddress)
+	addFactPair(msgCard, clientRequestSummarySection, "Client IP Source", clientRequest.ClientIPSource)
+	addFactPair(msgCard, clientRequestSummarySection, "Proxy chain", strings.Join(clientRequest.ProxyChain, " -> "))
 
 	if err := msgCard.AddSection(clientRequestSummarySection); err != nil {
 		errMsg := fmt.Sprintf("Error returned from attempt to add clientRequestSummarySection: %v", err)
@@ -160,12 +187,13 @@ func createMessage(clientRequest clientRequestDetails) *messagecard.MessageCard
 	// process client request headers
 
 	for header, values := range clientRequest.Headers {
+		// apply code snippet formatting to a copy of the values; the
+		// client request headers may be in use elsewhere
+		formatted := make([]string, len(values))
 		for index, value := range values {
-			// update value with code snippet formatting, assign back using
-			// the available index value
-			values[index] = messagecard.TryToFormatAsCodeSnippet(value)
+			formatted[index] = messagecard.TryToFormatAsCodeSnippet(value)
 		}
-		addFactPair(msgCard, clientRequestHeadersSection, header, values...)
+		addFactPair(msgCard, clientRequestHeadersSection, header, formatted...)
 	}
 
 	if err := msgCard.AddSection(clientRequestHeadersSection); err != nil {
@@ -190,6 +218,61 @@ func createMessage(clientRequest clientRequestDetails) *messagecard.MessageCard
 	return msgCard
 }
 
+// createRateLimitSummaryMessage generates a single message summarizing the
+// notifications suppressed by the notification rate limit.
+func createRateLimitSummaryMessage(summary *capture.RateLimitSummary) *messagecard.MessageCard {
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Title = "Notification from " + config.MyAppName
+	msgCard.Text = fmt.Sprintf(
+		"Rate limited: %d notifications suppressed between %s and %s",
+		summary.Count,
+		summary.First.Format("2006-01-02 15:04:05"),
+		summary.Last.Format("2006-01-02 15:04:05"),
+	)
+
+	addCountsSection := func(title string, counts map[string]int) {
+		section := messagecard.NewSection()
+		section.Title = title
+		section.StartGroup = true
+
+		keys := make([]string, 0, len(counts))
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := section.AddFactFromKeyValue(
+				messagecard.TryToFormatAsCodeSnippet(key),
+				strconv.Itoa(counts[key]),
+			); err != nil {
+				log.Errorf("createRateLimitSummaryMessage: error adding fact for %q: %v", key, err)
+			}
+		}
+
+		if err := msgCard.AddSection(section); err != nil {
+			errMsg := fmt.Sprintf("Error returned from attempt to add section %q: %v", title, err)
+			log.Error("createRateLimitSummaryMessage: " + errMsg)
+			msgCard.Text = msgCard.Text + "\n\n" + messagecard.TryToFormatAsCodeSnippet(errMsg)
+		}
+	}
+
+	addCountsSection("## Suppressed notifications by endpoint", summary.Endpoints)
+	addCountsSection("## Suppressed notifications by client IP Address", summary.Clients)
+
+	trailerSection := messagecard.NewSection()
+	trailerSection.StartGroup = true
+	trailerSection.Text = messagecard.ConvertEOLToBreak(config.MessageTrailer())
+	if err := msgCard.AddSection(trailerSection); err != nil {
+		errMsg := fmt.Sprintf("Error returned from attempt to add trailerSection: %v", err)
+		log.Error("createRateLimitSummaryMessage: " + errMsg)
+		msgCard.Text = msgCard.Text + "\n\n" + messagecard.TryToFormatAsCodeSnippet(errMsg)
+	}
+
+	return msgCard
+}
+
 // define function/wrapper for sending details to Microsoft Teams
 func sendMessage(
 	ctx context.Context,
@@ -198,13 +281,13 @@ func sendMessage(
 	schedule time.Time,
 	retries int,
 	retriesDelay int,
-) NotifyResult {
+) Result {
 
 	// Note: We already do validation elsewhere, and the library call does
 	// even more validation, but we can handle this obvious empty argument
 	// problem directly
 	if webhookURL == "" {
-		return NotifyResult{
+		return Result{
 			Err:     fmt.Errorf("sendMessage: webhookURL not defined, skipping message submission to Microsoft Teams channel"),
 			Success: false,
 		}
@@ -230,7 +313,7 @@ func sendMessage(
 	select {
 	case <-ctx.Done():
 		ctxErr := ctx.Err()
-		msg := NotifyResult{
+		msg := Result{
 			Val: fmt.Sprintf("sendMessage: Received Done signal at %v: %v, shutting down",
 				time.Now().Format("15:04:05"),
 				ctxErr.Error(),
@@ -256,7 +339,7 @@ func sendMessage(
 
 		// check to see if context has expired during our delay
 		if ctx.Err() != nil {
-			msg := NotifyResult{
+			msg := Result{
 				Val: fmt.Sprintf(
 					"sendMessage: context expired or cancelled at %v: %v, attempting to abort message submission",
 					time.Now().Format("15:04:05"),
@@ -276,7 +359,7 @@ func sendMessage(
 		// Submit message card using Microsoft Teams client, retry submission
 		// if needed up to specified number of retry attempts.
 		if err := mstClient.SendWithRetry(ctx, webhookURL, msgCard, retries, retriesDelay); err != nil {
-			errMsg := NotifyResult{
+			errMsg := Result{
 				Err: fmt.Errorf(
 					"sendMessage: ERROR: Failed to submit message to Microsoft Teams at %v: %w",
 					time.Now().Format("15:04:05"),
@@ -288,7 +371,7 @@ func sendMessage(
 			return errMsg
 		}
 
-		successMsg := NotifyResult{
+		successMsg := Result{
 			Val: fmt.Sprintf(
 				"sendMessage: Message successfully sent to Microsoft Teams at %v",
 				time.Now().Format("15:04:05"),
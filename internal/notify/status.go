@@ -0,0 +1,129 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package notify
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the state of the notifications manager made available to
+// other parts of this application (e.g., the admin API and readiness checks).
+// The notifications manager records its stats, queues, liveness and the
+// outcome of notification attempts here and checks whether notifications
+// have been paused.
+type Status struct {
+	queues   []Queue
+	stats    Stats
+	mu       sync.RWMutex
+	isPaused atomic.Bool
+
+	// isRunning is set while the notifications manager is running.
+	isRunning atomic.Bool
+
+	// heartbeat is the time (in Unix nanoseconds) that the notifications
+	// manager last completed an iteration of its main loop.
+	heartbeat atomic.Int64
+
+	// failures is the number of consecutive failed notification attempts.
+	failures atomic.Int64
+}
+
+// NewStatus creates a new Status with notifications enabled.
+func NewStatus() *Status {
+	return &Status{}
+}
+
+// setStats records the current notification stats.
+func (ns *Status) setStats(stats Stats) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.stats = stats
+}
+
+// setQueues records the queues used by the notifications manager.
+func (ns *Status) setQueues(queues []Queue) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.queues = append([]Queue(nil), queues...)
+}
+
+// Stats returns the current notification stats.
+func (ns *Status) Stats() Stats {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	return ns.stats
+}
+
+// QueueDepths returns the current number of items in each queue used by the
+// notifications manager.
+func (ns *Status) QueueDepths() []Queue {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	queues := make([]Queue, 0, len(ns.queues))
+	for _, queue := range ns.queues {
+		queues = append(queues, queue.measure())
+	}
+
+	return queues
+}
+
+// Paused indicates whether notifications are paused.
+func (ns *Status) Paused() bool {
+	return ns.isPaused.Load()
+}
+
+// SetPaused pauses or resumes notifications, indicating whether the state
+// was changed.
+func (ns *Status) SetPaused(paused bool) bool {
+	return ns.isPaused.Swap(paused) != paused
+}
+
+// setRunning records whether the notifications manager is running.
+func (ns *Status) setRunning(running bool) {
+	ns.isRunning.Store(running)
+	if running {
+		ns.beat()
+	}
+}
+
+// Running indicates whether the notifications manager is running.
+func (ns *Status) Running() bool {
+	return ns.isRunning.Load()
+}
+
+// beat records that the notifications manager is responsive.
+func (ns *Status) beat() {
+	ns.heartbeat.Store(time.Now().UnixNano())
+}
+
+// LastHeartbeat returns the time that the notifications manager was last
+// known to be responsive.
+func (ns *Status) LastHeartbeat() time.Time {
+	return time.Unix(0, ns.heartbeat.Load())
+}
+
+// recordAttempt records the outcome of a notification attempt.
+func (ns *Status) recordAttempt(success bool) {
+	if success {
+		ns.failures.Store(0)
+		return
+	}
+	ns.failures.Add(1)
+}
+
+// ConsecutiveFailures returns the number of consecutive failed notification
+// attempts, counting back from the most recent attempt.
+func (ns *Status) ConsecutiveFailures() int {
+	return int(ns.failures.Load())
+}
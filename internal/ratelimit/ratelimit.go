@@ -0,0 +1,181 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package ratelimit provides a token bucket rate limiter with independent
+// buckets for each key (e.g., client IP Address or route name).
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// cleanupInterval is how often idle buckets are removed from a Limiter.
+// Buckets which have refilled completely are indistinguishable from new
+// buckets and are safe to discard.
+const cleanupInterval time.Duration = time.Minute
+
+// Result is the outcome of a request for a token.
+type Result struct {
+
+	// RetryAfter is how long the caller should wait before a token is
+	// expected to be available. This is zero for allowed requests.
+	RetryAfter time.Duration
+
+	// Denied is the number of consecutive requests denied for the key,
+	// including this one. This is zero for allowed requests and is useful
+	// for logging only the first denial for a key instead of every denial.
+	Denied uint64
+
+	// Allowed indicates whether a token was available.
+	Allowed bool
+}
+
+// bucket is a single token bucket.
+type bucket struct {
+	last   time.Time
+	tokens float64
+	denied uint64
+}
+
+// Limiter is a collection of token buckets sharing the same rate and burst
+// settings, one for each key.
+type Limiter struct {
+	lastCleanup time.Time
+	buckets     map[string]*bucket
+	ratePerSec  float64
+	burst       float64
+	mu          sync.Mutex
+}
+
+// NewLimiter creates a new Limiter which permits the given number of
+// requests per minute for each key, with bursts of up to the given size. If
+// burst is less than one, the burst size is set to the per-minute limit. A
+// per-minute limit less than one results in a disabled Limiter which allows
+// all requests.
+func NewLimiter(perMinute int, burst int) *Limiter {
+
+	if burst < 1 {
+		burst = perMinute
+	}
+
+	return &Limiter{
+		ratePerSec:  float64(perMinute) / 60,
+		burst:       float64(burst),
+		buckets:     make(map[string]*bucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+// Enabled indicates whether the Limiter enforces a limit.
+func (l *Limiter) Enabled() bool {
+	return l != nil && l.ratePerSec > 0
+}
+
+// Allow consumes a token for the given key if one is available.
+func (l *Limiter) Allow(key string) Result {
+
+	if !l.Enabled() {
+		return Result{Allowed: true}
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.ratePerSec)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.denied = 0
+		return Result{Allowed: true}
+	}
+
+	b.denied++
+	wait := (1 - b.tokens) / l.ratePerSec
+
+	return Result{
+		RetryAfter: time.Duration(wait * float64(time.Second)),
+		Denied:     b.denied,
+	}
+}
+
+// Refund returns a token consumed by Allow for the given key, e.g., if the
+// request was rejected for another reason. The number of tokens is capped at
+// the burst size.
+func (l *Limiter) Refund(key string) {
+
+	if !l.Enabled() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(l.burst, b.tokens+1)
+	}
+}
+
+// Available indicates whether a token is available for the given key
+// without consuming it.
+func (l *Limiter) Available(key string) bool {
+
+	if !l.Enabled() {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return true
+	}
+
+	return b.tokens+time.Since(b.last).Seconds()*l.ratePerSec >= 1
+}
+
+// cleanup removes buckets which have had enough time to refill completely.
+// The caller is expected to hold the lock.
+func (l *Limiter) cleanup(now time.Time) {
+
+	if now.Sub(l.lastCleanup) < cleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+
+	refill := time.Duration(l.burst / l.ratePerSec * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RetryAfterSeconds converts a wait duration into a whole number of seconds
+// suitable for use with the Retry-After response header. The value is
+// rounded up and is always at least one.
+func RetryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return seconds
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package routes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// accessLogKey is the context key used to store the access log entry fields
+// for a request.
+type accessLogKey struct{}
+
+// accessLogFields collects additional fields provided by later handlers for
+// inclusion in the access log entry for a request.
+type accessLogFields struct {
+	fields log.Fields
+	mu     sync.Mutex
+}
+
+// AddAccessLogField adds a field to the access log entry for the given
+// request. This is a no-op if the AccessLog middleware is not in use.
+func AddAccessLogField(r *http.Request, key string, value interface{}) {
+	alf, ok := r.Context().Value(accessLogKey{}).(*accessLogFields)
+	if !ok {
+		return
+	}
+
+	alf.mu.Lock()
+	defer alf.mu.Unlock()
+
+	alf.fields[key] = value
+}
+
+// responseRecorder records the status code and number of bytes written for a
+// response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code before passing it on.
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if rr.status == 0 {
+		rr.status = statusCode
+	}
+
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records the number of bytes written.
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += int64(n)
+
+	return n, err
+}
+
+// Flush flushes buffered data to the client, if supported.
+func (rr *responseRecorder) Flush() {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows the wrapped connection to be taken over by the handler.
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
+	}
+
+	if rr.status == 0 {
+		rr.status = http.StatusSwitchingProtocols
+	}
+
+	return hj.Hijack()
+}
+
+// Unwrap provides access to the wrapped ResponseWriter for use with
+// http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// AccessLog emits a structured log entry for each request once the response
+// has been written. The entry includes the request ID (if the RequestID
+// middleware is used first), method, path, status, bytes written, duration
+// and client IP Address along with any fields added by later handlers using
+// AddAccessLogField. The given function is used to determine the client IP
+// Address.
+func AccessLog(clientIP func(*http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			start := time.Now()
+
+			alf := &accessLogFields{fields: make(log.Fields)}
+			rr := &responseRecorder{ResponseWriter: w}
+
+			ctx := context.WithValue(r.Context(), accessLogKey{}, alf)
+
+			defer func() {
+				if rr.status == 0 {
+					rr.status = http.StatusOK
+				}
+
+				alf.mu.Lock()
+				defer alf.mu.Unlock()
+
+				fields := log.Fields{
+					"request_id":  RequestIDFromContext(ctx),
+					"http_method": r.Method,
+					"url_path":    r.URL.Path,
+					"status":      rr.status,
+					"bytes":       rr.bytes,
+					"duration":    time.Since(start).String(),
+					"client_ip":   clientIP(r),
+				}
+				for k, v := range alf.fields {
+					fields[k] = v
+				}
+
+				log.WithFields(fields).Info("AccessLog: request handled")
+			}()
+
+			next.ServeHTTP(rr, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package routes
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// connectionKey is the context key used to store the request counter for a
+// connection.
+type connectionKey struct{}
+
+// connectionRequestKey is the context key used to store the position of a
+// request on its connection.
+type connectionRequestKey struct{}
+
+// ConnContext is intended for use as the ConnContext function of an
+// http.Server. A request counter is attached to the context of each new
+// connection so that the ConnectionRequests middleware can determine whether
+// a connection is reused.
+func ConnContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connectionKey{}, new(atomic.Int64))
+}
+
+// ConnectionRequests records the position of each request on its connection
+// (1 for the first request). Requests after the first are received on a
+// reused connection, either via HTTP/1.x keep-alive or as additional HTTP/2
+// streams. The position is available to later handlers via
+// ConnectionRequestFromContext. Nothing is recorded unless the server uses
+// ConnContext.
+func ConnectionRequests() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			counter, ok := r.Context().Value(connectionKey{}).(*atomic.Int64)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), connectionRequestKey{}, counter.Add(1))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ConnectionRequestFromContext returns the position of the request on its
+// connection as recorded by the ConnectionRequests middleware. 0 is returned
+// if a position was not recorded.
+func ConnectionRequestFromContext(ctx context.Context) int64 {
+	n, _ := ctx.Value(connectionRequestKey{}).(int64)
+
+	return n
+}
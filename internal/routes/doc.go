@@ -10,5 +10,11 @@ Package routes provides types and functions used to collect routes and
 associated metadata. The route metadata is used to generate an index listing
 available routes supported by the application and the collected routes are
 registered with the servemux for request handling.
+
+Middleware may be applied globally (by wrapping the servemux) or per-route
+using a Chain. Any middleware using the common func(http.Handler)
+http.Handler signature may be used, including third-party middleware.
+Middleware for panic recovery, response compression, CORS and tracking
+connection reuse is provided by this package.
 */
 package routes
@@ -0,0 +1,94 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/golang/gddo/httputil/header"
+)
+
+// ErrorResponse is the JSON representation of an error returned to clients
+// which indicate a preference for JSON responses.
+type ErrorResponse struct {
+
+	// Error is the standard text for the HTTP status code.
+	Error string `json:"error"`
+
+	// Message provides additional details for the error.
+	Message string `json:"message"`
+
+	// Status is the HTTP status code.
+	Status int `json:"status"`
+}
+
+// WriteError writes an error response using a consistent format for all
+// routes. The response body is JSON if the client indicates (via the Accept
+// header) a preference for JSON over plain text, otherwise plain text.
+func WriteError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+
+	if !PrefersJSON(r) {
+		http.Error(w, fmt.Sprintf("%d %s: %s", statusCode, http.StatusText(statusCode), message), statusCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+
+	errResponse := ErrorResponse{
+		Error:   http.StatusText(statusCode),
+		Message: message,
+		Status:  statusCode,
+	}
+
+	if err := json.NewEncoder(w).Encode(errResponse); err != nil {
+		log.Errorf("WriteError: failed to write error response: %v", err)
+	}
+}
+
+// PrefersJSON indicates whether the client prefers a JSON response over a
+// plain text response based on the Accept header. JSON is only chosen if it
+// is explicitly listed and has a higher quality value than plain text (or a
+// wildcard), or the same quality value but listed first. Plain text is used
+// if the Accept header is not provided.
+func PrefersJSON(r *http.Request) bool {
+
+	jsonQ, textQ := -1.0, -1.0
+	jsonPos, textPos := -1, -1
+
+	for pos, spec := range header.ParseAccept(r.Header, "Accept") {
+		switch strings.ToLower(spec.Value) {
+		case "application/json", "application/*":
+			if spec.Q > jsonQ {
+				jsonQ, jsonPos = spec.Q, pos
+			}
+		case "text/plain", "text/*", "*/*":
+			if spec.Q > textQ {
+				textQ, textPos = spec.Q, pos
+			}
+		}
+	}
+
+	switch {
+	case jsonQ <= 0:
+		return false
+	case jsonQ != textQ:
+		return jsonQ > textQ
+	default:
+		return jsonPos < textPos
+	}
+}
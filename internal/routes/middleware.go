@@ -0,0 +1,336 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package routes
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/golang/gddo/httputil/header"
+)
+
+// Middleware wraps an http.Handler in order to perform work before and/or
+// after the wrapped handler is called. Any third-party middleware using the
+// common func(http.Handler) http.Handler signature may be used as-is.
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered collection of Middleware. The first Middleware in the
+// Chain is the outermost; it sees each request first and each response last.
+type Chain []Middleware
+
+// NewChain creates a new Chain from the given Middleware.
+func NewChain(mw ...Middleware) Chain {
+	return append(Chain(nil), mw...)
+}
+
+// Append returns a new Chain with the given Middleware added to the end
+// (innermost position) of the existing Chain. The existing Chain is not
+// modified.
+func (c Chain) Append(mw ...Middleware) Chain {
+	newChain := make(Chain, 0, len(c)+len(mw))
+	newChain = append(newChain, c...)
+
+	return append(newChain, mw...)
+}
+
+// Then wraps the given handler with each Middleware in the Chain and returns
+// the result.
+func (c Chain) Then(h http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+
+	return h
+}
+
+// ThenFunc is a convenience wrapper around Then for use with an
+// http.HandlerFunc.
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
+
+// Recoverer recovers from panics in later handlers, logs the panic and
+// returns a 500 response to the client. The http.ErrAbortHandler sentinel
+// panic is passed through so that the http package is able to abort the
+// response as intended.
+func Recoverer() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				log.WithFields(log.Fields{
+					"url_path":    r.URL.Path,
+					"http_method": r.Method,
+					"panic":       fmt.Sprint(rec),
+				}).Error("Recoverer: recovered from panic while handling request")
+				log.Debugf("Recoverer: stack trace: %s", debug.Stack())
+
+				WriteError(w, r, http.StatusInternalServerError, "")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// gzipResponseWriter compresses the response body using gzip. The decision
+// whether to compress is deferred until the status code and headers are
+// known.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	pool        *sync.Pool
+	wroteHeader bool
+}
+
+// WriteHeader enables compression unless the response has no body or has
+// already been encoded by the handler.
+func (gw *gzipResponseWriter) WriteHeader(statusCode int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+
+	h := gw.Header()
+	bodyAllowed := statusCode != http.StatusNoContent &&
+		statusCode != http.StatusNotModified &&
+		statusCode >= http.StatusOK
+
+	if bodyAllowed && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", "gzip")
+		h.Add("Vary", "Accept-Encoding")
+		h.Del("Content-Length")
+
+		gz, _ := gw.pool.Get().(*gzip.Writer)
+		gz.Reset(gw.ResponseWriter)
+		gw.gz = gz
+	}
+
+	gw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write compresses the given bytes if compression is enabled.
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(b)
+	}
+
+	return gw.gz.Write(b)
+}
+
+// Flush flushes any pending compressed data to the client.
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+
+	if gw.gz != nil {
+		if err := gw.gz.Flush(); err != nil {
+			log.Errorf("gzipResponseWriter: failed to flush compressed data: %v", err)
+		}
+	}
+
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows the wrapped connection to be taken over by the handler.
+// Compression is not applied to hijacked connections.
+func (gw *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := gw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
+	}
+
+	return hj.Hijack()
+}
+
+// Unwrap provides access to the wrapped ResponseWriter for use with
+// http.ResponseController.
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// close completes the compressed stream and returns the gzip.Writer to the
+// pool.
+func (gw *gzipResponseWriter) close() {
+	if gw.gz == nil {
+		return
+	}
+
+	if err := gw.gz.Close(); err != nil {
+		log.Errorf("gzipResponseWriter: failed to complete compressed response: %v", err)
+	}
+	gw.pool.Put(gw.gz)
+	gw.gz = nil
+}
+
+// Compress compresses response bodies using gzip for clients which accept
+// gzip encoding. Requests for connection upgrades (e.g., WebSockets) and HEAD
+// requests are passed through unmodified.
+func Compress(level int) Middleware {
+
+	pool := &sync.Pool{
+		New: func() interface{} {
+			gz, err := gzip.NewWriterLevel(nil, level)
+			if err != nil {
+				gz = gzip.NewWriter(nil)
+			}
+
+			return gz
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, pool: pool}
+			defer gw.close()
+
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip indicates whether the client accepts gzip encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, spec := range header.ParseAccept(r.Header, "Accept-Encoding") {
+		if strings.EqualFold(spec.Value, "gzip") && spec.Q > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CORSOptions controls the Cross-Origin Resource Sharing headers applied to
+// responses by the CORS middleware.
+type CORSOptions struct {
+
+	// AllowedOrigins is the list of origins permitted to make cross-origin
+	// requests. The value "*" permits all origins.
+	AllowedOrigins []string
+
+	// AllowedMethods is the list of methods permitted for cross-origin
+	// requests.
+	AllowedMethods []string
+
+	// AllowedHeaders is the list of request headers permitted for
+	// cross-origin requests. If empty, the headers requested by the client
+	// in a preflight request are permitted.
+	AllowedHeaders []string
+
+	// ExposedHeaders is the list of response headers made available to
+	// client scripts.
+	ExposedHeaders []string
+
+	// MaxAge is the number of seconds that preflight responses may be cached
+	// by clients. A value of 0 omits the header.
+	MaxAge int
+
+	// AllowCredentials indicates whether cookies and other credentials are
+	// permitted with cross-origin requests.
+	AllowCredentials bool
+}
+
+// originAllowed indicates whether the given origin is permitted.
+func (co CORSOptions) originAllowed(origin string) bool {
+	for _, allowed := range co.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CORS applies Cross-Origin Resource Sharing headers to responses for
+// permitted origins and answers preflight requests directly.
+func CORS(opts CORSOptions) Middleware {
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+
+			if origin == "" || !opts.originAllowed(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Credentials cannot be combined with a wildcard origin, so the
+			// requesting origin is reflected back instead.
+			allowOrigin := origin
+			if !opts.AllowCredentials && opts.originAllowed("*") {
+				allowOrigin = "*"
+			}
+
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			preflight := r.Method == http.MethodOptions &&
+				r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !preflight {
+				if len(opts.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", strings.Join(opts.AllowedMethods, ", "))
+
+			switch {
+			case len(opts.AllowedHeaders) > 0:
+				h.Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+			case r.Header.Get("Access-Control-Request-Headers") != "":
+				h.Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+			}
+
+			if opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
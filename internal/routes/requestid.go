@@ -0,0 +1,90 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/apex/log"
+)
+
+// RequestIDHeader is the header used to receive a request ID from clients and
+// to return the request ID used for a request.
+const RequestIDHeader string = "X-Request-Id"
+
+// maxRequestIDLength is the maximum length of a client-provided request ID.
+// Longer values are replaced with a generated ID.
+const maxRequestIDLength int = 128
+
+// requestIDKey is the context key used to store the request ID.
+type requestIDKey struct{}
+
+// RequestID assigns an ID to each request and returns it to the client using
+// the X-Request-Id response header. A valid request ID provided by the client
+// is used as-is, otherwise a new ID is generated. The request ID is available
+// to later handlers via RequestIDFromContext. Since clients may repeat request
+// IDs, the request ID does not necessarily identify a single request.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID recorded by the RequestID
+// middleware. An empty string is returned if a request ID was not recorded.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// newRequestID generates a random 128-bit request ID encoded as a hex string.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("newRequestID: failed to generate request ID: %v", err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// validRequestID indicates whether a client-provided request ID is safe to
+// use as-is. Only a limited set of characters is accepted in order to prevent
+// abuse of log output and response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		clientID  string
+		wantHonor bool
+	}{
+		{name: "no client request ID"},
+		{name: "valid client request ID", clientID: "build-42:step/3+retry=1", wantHonor: true},
+		{name: "maximum length client request ID", clientID: strings.Repeat("a", maxRequestIDLength), wantHonor: true},
+		{name: "client request ID too long", clientID: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "client request ID with spaces", clientID: "build 42"},
+		{name: "client request ID with control characters", clientID: "id\x1b[31m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var gotCtxID string
+			handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotCtxID = RequestIDFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.clientID != "" {
+				r.Header.Set(RequestIDHeader, tt.clientID)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			gotHeaderID := w.Header().Get(RequestIDHeader)
+			if gotHeaderID != gotCtxID {
+				t.Errorf("response header ID %q does not match context ID %q", gotHeaderID, gotCtxID)
+			}
+
+			switch {
+			case tt.wantHonor && gotCtxID != tt.clientID:
+				t.Errorf("request ID = %q, want client request ID %q", gotCtxID, tt.clientID)
+			case !tt.wantHonor && (gotCtxID == tt.clientID || len(gotCtxID) != 32):
+				t.Errorf("request ID = %q, want a generated request ID", gotCtxID)
+			}
+		})
+	}
+}
@@ -8,7 +8,9 @@
 package routes
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/apex/log"
 )
@@ -21,6 +23,27 @@ type Route struct {
 	Description    string
 	HandlerFunc    http.HandlerFunc
 	AllowedMethods []string
+
+	// AccessControls is a list of the access controls (e.g., IP allowlist,
+	// Basic auth) enforced for the route. An empty list indicates that the
+	// route is publicly accessible.
+	AccessControls []string
+
+	// Middleware is the per-route Chain applied to requests for the route.
+	// The per-route Chain runs after any global Middleware and before the
+	// allowed methods for the route are enforced.
+	Middleware Chain
+}
+
+// Use appends one or many Middleware to the per-route Chain.
+func (r *Route) Use(mw ...Middleware) {
+	r.Middleware = r.Middleware.Append(mw...)
+}
+
+// Protected indicates whether any access controls are enforced for the
+// route.
+func (r Route) Protected() bool {
+	return len(r.AccessControls) > 0
 }
 
 // Routes is a collection of defined routes, intended for bulk registration
@@ -37,16 +60,127 @@ func (rs *Routes) Add(r ...Route) {
 }
 
 // RegisterWithServeMux registers each recorded Route with the specified
-// HTTP ServeMux.
-func (rs *Routes) RegisterWithServeMux(mux *http.ServeMux) {
-
-	// TODO: How would we check for errors registering our route?
+// HTTP ServeMux. An error is returned if a route pattern is invalid or
+// conflicts with a previously registered pattern.
+func (rs *Routes) RegisterWithServeMux(mux *http.ServeMux) error {
 
 	for _, route := range *rs {
 		log.Debugf("Register %s with ServeMux ...", route.Name)
-		mux.HandleFunc(route.Pattern, route.HandlerFunc)
+		if err := register(mux, route); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// register registers a single Route with the specified HTTP ServeMux. The
+// ServeMux panics if a pattern is invalid or conflicts with another; we
+// recover from the panic in order to report the problem as an error.
+func register(mux *http.ServeMux, route Route) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to register route %q: %v", route.Name, r)
+		}
+	}()
+
+	mux.Handle(route.Pattern, route.Middleware.Then(route.enforceMethods()))
+
+	return nil
+}
+
+// methodAllowed indicates whether the route explicitly accepts the given
+// HTTP method.
+func (r Route) methodAllowed(method string) bool {
+	for _, allowed := range r.AllowedMethods {
+		if method == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowHeader provides the value for the Allow response header listing all
+// methods accepted by the route. HEAD is implied by GET and OPTIONS is
+// always accepted.
+func (r Route) AllowHeader() string {
+
+	methods := make([]string, 0, len(r.AllowedMethods)+2)
+	methods = append(methods, r.AllowedMethods...)
+
+	if r.methodAllowed(http.MethodGet) && !r.methodAllowed(http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+
+	if !r.methodAllowed(http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+
+	return strings.Join(methods, ", ")
+}
+
+// enforceMethods wraps the route handler in order to enforce the allowed
+// methods for the route. HEAD requests are passed to the handler for routes
+// accepting GET requests (the http package discards the response body) and
+// OPTIONS requests are answered directly unless the route explicitly
+// accepts them. All other methods not explicitly accepted are rejected with
+// a 405 response.
+func (r Route) enforceMethods() http.HandlerFunc {
+
+	allow := r.AllowHeader()
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		switch {
+		case r.methodAllowed(req.Method):
+			r.HandlerFunc(w, req)
+
+		case req.Method == http.MethodHead && r.methodAllowed(http.MethodGet):
+			r.HandlerFunc(w, req)
+
+		case req.Method == http.MethodOptions:
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			log.WithFields(log.Fields{
+				"route":       r.Name,
+				"url_path":    req.URL.Path,
+				"http_method": req.Method,
+			}).Debug("enforceMethods: rejecting request using unsupported method")
+
+			w.Header().Set("Allow", allow)
+			WriteError(w, req, http.StatusMethodNotAllowed, fmt.Sprintf(
+				"Unsupported method %q received; this endpoint accepts: %s",
+				req.Method,
+				allow,
+			))
+		}
+	}
+}
+
+// PatternParams provides the list of wildcard names used in the given route
+// pattern (e.g., "team" for /hooks/splunk/{team}). The special {$} wildcard
+// is not included.
+func PatternParams(pattern string) []string {
+
+	var params []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+		if name == "" || name == "$" {
+			continue
+		}
+
+		params = append(params, name)
 	}
 
+	return params
 }
 
 // ListNames provides a list of all recorded route names in Routes
@@ -0,0 +1,107 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/access"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// applyAccessControl adds middleware to each route to enforce the access
+// policy configured for the route. Routes without an explicit policy use the
+// default policy, if one is configured. The route metadata is updated to
+// reflect the access controls enforced so that the index page is able to
+// indicate which routes are protected.
+func applyAccessControl(
+	rs *routes.Routes,
+	policies map[string]config.AccessPolicy,
+	ipResolver *clientip.Resolver,
+) error {
+
+	knownRoutes := make(map[string]bool, len(*rs))
+	for _, route := range *rs {
+		knownRoutes[route.Name] = true
+	}
+
+	for routeName := range policies {
+		if routeName != config.DefaultRouteName && !knownRoutes[routeName] {
+			log.Warnf("applyAccessControl: access policy specified for unknown route %q", routeName)
+		}
+	}
+
+	for i := range *rs {
+		route := &(*rs)[i]
+
+		policySettings, ok := policies[route.Name]
+		if !ok {
+			policySettings, ok = policies[config.DefaultRouteName]
+		}
+		if !ok {
+			log.Debugf("applyAccessControl: no access policy for route %s", route.Name)
+			continue
+		}
+
+		policy, err := access.NewPolicy(policySettings)
+		if err != nil {
+			return fmt.Errorf("failed to apply access policy for route %q: %w", route.Name, err)
+		}
+
+		route.AccessControls = policy.Controls()
+		route.Use(accessControlMiddleware(route.Name, policy, ipResolver))
+
+		log.Debugf("applyAccessControl: route %s protected by %v", route.Name, route.AccessControls)
+	}
+
+	return nil
+}
+
+// accessControlMiddleware evaluates each request against the given access
+// policy and only passes permitted requests on to the next handler. Rejected
+// requests are logged, counted and do not generate notifications.
+func accessControlMiddleware(
+	routeName string,
+	policy *access.Policy,
+	ipResolver *clientip.Resolver,
+) routes.Middleware {
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			clientIP := ipResolver.GetIP(r)
+
+			decision := policy.Check(r, clientIP)
+			if decision.Allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log.WithFields(log.Fields{
+				"request_id":     routes.RequestIDFromContext(r.Context()),
+				"route":          routeName,
+				"url_path":       r.URL.Path,
+				"http_method":    r.Method,
+				"client_ip":      clientIP,
+				"reason":         decision.Reason,
+				"status":         decision.Status,
+				"rejected_total": policy.RejectedTotal(),
+			}).Warn("accessControlMiddleware: request rejected")
+
+			if decision.Status == http.StatusUnauthorized {
+				policy.Challenge(w)
+			}
+
+			routes.WriteError(w, r, decision.Status, "")
+		})
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/notify"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// Admin API endpoint patterns. The fault injection endpoints are defined
+// alongside the fault injection middleware.
+const (
+	adminAPIV1ConfigEndpointPattern             string = "/admin/api/v1/config"
+	adminAPIV1LogLevelEndpointPattern           string = "/admin/api/v1/log-level"
+	adminAPIV1NotificationsEndpointPattern      string = "/admin/api/v1/notifications"
+	adminAPIV1NotificationsPauseEndpointPattern string = "/admin/api/v1/notifications/pause"
+	adminAPIV1NotificationsResumePattern        string = "/admin/api/v1/notifications/resume"
+	adminAPIV1HistoryEndpointPattern            string = "/admin/api/v1/history"
+	adminAPIV1ResponsesEndpointPattern          string = "/admin/api/v1/responses"
+	adminAPIV1ResponseEndpointPattern           string = "/admin/api/v1/responses/{route}"
+	adminAPIV1ExpectationsEndpointPattern       string = "/admin/api/v1/expectations"
+	adminAPIV1ExpectationEndpointPattern        string = "/admin/api/v1/expectations/{id}"
+)
+
+// newAdminRoutes creates the admin API routes. Every admin route is
+// protected by the admin access policy, which is required in order to
+// enable the admin API. Other access policies, rate limits and CORS policies
+// do not apply to these routes.
+func newAdminRoutes(cfg *config.Config, deps Deps, faults faultSettings, echoRoutes []string) (routes.Routes, error) {
+
+	var adminRoutes routes.Routes
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-config",
+		Description:    "Returns the effective configuration as JSON, with secrets redacted",
+		Pattern:        adminAPIV1ConfigEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    handleAdminConfig(cfg),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-log-level",
+		Description:    "Returns or changes the logging level",
+		Pattern:        adminAPIV1LogLevelEndpointPattern,
+		AllowedMethods: []string{http.MethodGet, http.MethodPut},
+		HandlerFunc:    handleAdminLogLevel(),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-notifications",
+		Description:    "Returns notification stats and queue depths as JSON",
+		Pattern:        adminAPIV1NotificationsEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    handleAdminNotifications(cfg, deps.NotifyStatus),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-notifications-pause",
+		Description:    "Pauses notifications",
+		Pattern:        adminAPIV1NotificationsPauseEndpointPattern,
+		AllowedMethods: []string{http.MethodPost},
+		HandlerFunc:    handleAdminNotificationsPause(cfg, deps.NotifyStatus, true),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-notifications-resume",
+		Description:    "Resumes notifications",
+		Pattern:        adminAPIV1NotificationsResumePattern,
+		AllowedMethods: []string{http.MethodPost},
+		HandlerFunc:    handleAdminNotificationsPause(cfg, deps.NotifyStatus, false),
+	})
+
+	if deps.History.Enabled() {
+		adminRoutes.Add(routes.Route{
+			Name:           "admin-history",
+			Description:    "Discards all captured client requests retained in memory",
+			Pattern:        adminAPIV1HistoryEndpointPattern,
+			AllowedMethods: []string{http.MethodDelete},
+			HandlerFunc:    handleAdminClearHistory(deps.History),
+		})
+	}
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-expectations",
+		Description:    "Registers a new expectation",
+		Pattern:        adminAPIV1ExpectationsEndpointPattern,
+		AllowedMethods: []string{http.MethodPost},
+		HandlerFunc:    handleAdminAddExpectation(deps.Expectations),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-expectation",
+		Description:    "Removes an expectation",
+		Pattern:        adminAPIV1ExpectationEndpointPattern,
+		AllowedMethods: []string{http.MethodDelete},
+		HandlerFunc:    handleAdminRemoveExpectation(deps.Expectations),
+	})
+
+	configuredResponses := make(map[string]config.ResponseRule, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		if route.Response != nil {
+			configuredResponses[route.Name] = *route.Response
+		}
+	}
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-responses",
+		Description:    "Lists response rules as JSON",
+		Pattern:        adminAPIV1ResponsesEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    handleAdminResponses(configuredResponses, deps.ResponseOverrides, echoRoutes),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-response",
+		Description:    "Returns, replaces or removes the runtime response rule for a route",
+		Pattern:        adminAPIV1ResponseEndpointPattern,
+		AllowedMethods: []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+		HandlerFunc:    handleAdminResponse(configuredResponses, deps.ResponseOverrides, echoRoutes),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-faults",
+		Description:    "Lists fault injection settings as JSON",
+		Pattern:        adminAPIV1FaultsEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    handleFaults(faults, echoRoutes),
+	})
+
+	adminRoutes.Add(routes.Route{
+		Name:           "admin-fault",
+		Description:    "Returns, replaces or removes the runtime fault injection settings for a route",
+		Pattern:        adminAPIV1FaultEndpointPattern,
+		AllowedMethods: []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+		HandlerFunc:    handleFault(faults, echoRoutes),
+	})
+
+	policies := map[string]config.AccessPolicy{
+		config.DefaultRouteName: *cfg.AdminAccess,
+	}
+	if err := applyAccessControl(&adminRoutes, policies, deps.IPResolver); err != nil {
+		return nil, fmt.Errorf("failed to apply admin access policy: %w", err)
+	}
+
+	return adminRoutes, nil
+}
+
+// adminLog returns a logger with the fields common to admin API requests.
+func adminLog(r *http.Request) *log.Entry {
+	return log.WithFields(log.Fields{
+		"request_id": routes.RequestIDFromContext(r.Context()),
+		"url_path":   r.URL.Path,
+	})
+}
+
+// handleAdminConfig returns the effective configuration with secrets (e.g.,
+// the webhook URL and credentials) redacted.
+func handleAdminConfig(cfg *config.Config) http.HandlerFunc {
+
+	redacted := cfg.Redacted()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminConfig endpoint hit")
+
+		writeJSON(w, redacted)
+	}
+}
+
+// adminLogLevel is the logging level returned and accepted by the admin API.
+type adminLogLevel struct {
+	Level string `json:"level"`
+}
+
+// handleAdminLogLevel returns or (via PUT) changes the logging level. The
+// change lasts until the configuration is reloaded.
+func handleAdminLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminLogLevel endpoint hit")
+
+		if r.Method == http.MethodPut {
+			var req adminLogLevel
+			if !decodeJSONRequest(w, r, &req) {
+				return
+			}
+
+			previous := config.CurrentLogLevel()
+			if err := config.SetLogLevel(req.Level); err != nil {
+				routes.WriteError(w, r, http.StatusUnprocessableEntity, err.Error())
+				return
+			}
+
+			adminLog(r).WithFields(log.Fields{
+				"previous": previous,
+				"level":    req.Level,
+			}).Info("handleAdminLogLevel: logging level changed")
+		}
+
+		writeJSON(w, adminLogLevel{Level: config.CurrentLogLevel()})
+	}
+}
+
+// adminNotifications is the notification status returned by the admin API.
+type adminNotifications struct {
+
+	// EnabledNotifiers is the list of notifiers enabled for the application.
+	EnabledNotifiers []string `json:"enabled_notifiers"`
+
+	// Queues is the number of items in each queue used by the
+	// notifications manager.
+	Queues []notify.Queue `json:"queues"`
+
+	// Stats is the collection of notification stats.
+	Stats notify.Stats `json:"stats"`
+
+	// Paused indicates whether notifications are paused.
+	Paused bool `json:"paused"`
+}
+
+// newAdminNotifications returns the current notification status.
+func newAdminNotifications(cfg *config.Config, status *notify.Status) adminNotifications {
+	enabled := cfg.EnabledNotifiers()
+	if enabled == nil {
+		enabled = []string{}
+	}
+
+	return adminNotifications{
+		EnabledNotifiers: enabled,
+		Queues:           status.QueueDepths(),
+		Stats:            status.Stats(),
+		Paused:           status.Paused(),
+	}
+}
+
+// handleAdminNotifications returns the notification stats and the number of
+// items in each notification queue.
+func handleAdminNotifications(cfg *config.Config, status *notify.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminNotifications endpoint hit")
+
+		writeJSON(w, newAdminNotifications(cfg, status))
+	}
+}
+
+// handleAdminNotificationsPause pauses or resumes notifications. Client
+// requests received while notifications are paused are recorded with a
+// paused notification outcome and do not generate notifications later.
+func handleAdminNotificationsPause(cfg *config.Config, status *notify.Status, pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminNotificationsPause endpoint hit")
+
+		if status.SetPaused(pause) {
+			state := "resumed"
+			if pause {
+				state = "paused"
+			}
+			adminLog(r).Infof("handleAdminNotificationsPause: notifications %s", state)
+		}
+
+		writeJSON(w, newAdminNotifications(cfg, status))
+	}
+}
+
+// adminClearedHistory is the result of clearing the request history.
+type adminClearedHistory struct {
+	Removed int `json:"removed"`
+}
+
+// handleAdminClearHistory discards all captured client requests retained in
+// memory.
+func handleAdminClearHistory(rh *capture.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminClearHistory endpoint hit")
+
+		removed := rh.Clear()
+		adminLog(r).WithField("removed", removed).Info("handleAdminClearHistory: request history cleared")
+
+		writeJSON(w, adminClearedHistory{Removed: removed})
+	}
+}
+
+// responseRuleSettings is the response rule in effect for a route, as
+// returned by the admin API.
+type responseRuleSettings struct {
+
+	// Response is the response rule in effect for the route, if any. The
+	// client request details are echoed back if no rule is in effect.
+	Response *config.ResponseRule `json:"response"`
+
+	// Route is the route name.
+	Route string `json:"route"`
+
+	// Source indicates where the rule was specified; this is empty if no
+	// rule is in effect for the route.
+	Source string `json:"source"`
+}
+
+// lookupResponseRule returns the response rule in effect for the given route
+// along with its source. Runtime overrides take precedence over the
+// configuration file.
+func lookupResponseRule(
+	configured map[string]config.ResponseRule,
+	overrides *Overrides[config.ResponseRule],
+	routeName string,
+) responseRuleSettings {
+
+	settings := responseRuleSettings{Route: routeName}
+
+	if rule, ok := overrides.get(routeName); ok {
+		settings.Response = &rule
+		settings.Source = faultSourceOverride
+		return settings
+	}
+
+	if rule, ok := configured[routeName]; ok {
+		settings.Response = &rule
+		settings.Source = faultSourceConfig
+	}
+
+	return settings
+}
+
+// handleAdminResponses lists the response rules in effect for each route
+// served by echoHandler.
+func handleAdminResponses(
+	configured map[string]config.ResponseRule,
+	overrides *Overrides[config.ResponseRule],
+	routeNames []string,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminResponses endpoint hit")
+
+		sorted := append([]string(nil), routeNames...)
+		sort.Strings(sorted)
+
+		list := make([]responseRuleSettings, 0, len(sorted))
+		for _, name := range sorted {
+			list = append(list, lookupResponseRule(configured, overrides, name))
+		}
+
+		writeJSON(w, list)
+	}
+}
+
+// handleAdminResponse returns, replaces (PUT) or removes (DELETE) the
+// runtime response rule for the route specified in the request path.
+// Removing the runtime rule for a route restores the rule from the
+// configuration file, if any.
+func handleAdminResponse(
+	configured map[string]config.ResponseRule,
+	overrides *Overrides[config.ResponseRule],
+	routeNames []string,
+) http.HandlerFunc {
+
+	eligible := make(map[string]bool, len(routeNames))
+	for _, name := range routeNames {
+		eligible[name] = true
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminResponse endpoint hit")
+
+		routeName := r.PathValue("route")
+		if !eligible[routeName] {
+			routes.WriteError(w, r, http.StatusNotFound, "No echo route with the specified name")
+			return
+		}
+
+		ctxLog := adminLog(r).WithField("route", routeName)
+
+		switch r.Method {
+		case http.MethodPut:
+			var rule config.ResponseRule
+			if !decodeJSONRequest(w, r, &rule) {
+				return
+			}
+
+			if err := config.ValidateResponseRule(rule); err != nil {
+				routes.WriteError(w, r, http.StatusUnprocessableEntity, err.Error())
+				return
+			}
+
+			overrides.Set(routeName, rule)
+			ctxLog.Info("handleAdminResponse: response rule replaced")
+
+		case http.MethodDelete:
+			if !overrides.Remove(routeName) {
+				routes.WriteError(w, r, http.StatusNotFound, "No runtime response rule for the specified route")
+				return
+			}
+			ctxLog.Info("handleAdminResponse: response rule removed")
+		}
+
+		writeJSON(w, lookupResponseRule(configured, overrides, routeName))
+	}
+}
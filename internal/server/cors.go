@@ -0,0 +1,63 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// applyCORS adds middleware to each route to apply the Cross-Origin Resource
+// Sharing policy configured for the route. Routes without an explicit policy
+// use the default policy, if one is configured. If a policy does not specify
+// the allowed methods, the methods supported by the route are used.
+func applyCORS(rs *routes.Routes, policies map[string]config.CORSPolicy) {
+
+	knownRoutes := make(map[string]bool, len(*rs))
+	for _, route := range *rs {
+		knownRoutes[route.Name] = true
+	}
+
+	for routeName := range policies {
+		if routeName != config.DefaultRouteName && !knownRoutes[routeName] {
+			log.Warnf("applyCORS: CORS policy specified for unknown route %q", routeName)
+		}
+	}
+
+	for i := range *rs {
+		route := &(*rs)[i]
+
+		policy, ok := policies[route.Name]
+		if !ok {
+			policy, ok = policies[config.DefaultRouteName]
+		}
+		if !ok {
+			log.Debugf("applyCORS: no CORS policy for route %s", route.Name)
+			continue
+		}
+
+		allowedMethods := policy.AllowedMethods
+		if len(allowedMethods) == 0 {
+			allowedMethods = strings.Split(route.AllowHeader(), ", ")
+		}
+
+		route.Use(routes.CORS(routes.CORSOptions{
+			AllowedOrigins:   policy.AllowedOrigins,
+			AllowedMethods:   allowedMethods,
+			AllowedHeaders:   policy.AllowedHeaders,
+			ExposedHeaders:   policy.ExposedHeaders,
+			MaxAge:           policy.MaxAge,
+			AllowCredentials: policy.AllowCredentials,
+		}))
+
+		log.Debugf("applyCORS: route %s uses CORS policy %+v", route.Name, policy)
+	}
+}
@@ -8,7 +8,7 @@
 //
 // All code snippets in this post are free to use under the MIT Licence.
 
-package main
+package server
 
 import (
 	"encoding/json"
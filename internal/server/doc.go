@@ -0,0 +1,19 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package server provides the HTTP handlers used by bounce along with
+NewRouter, which registers the built-in routes, the routes defined via the
+configuration file and the admin API routes using the middleware specified
+by the configuration.
+
+The values shared by each router (e.g., the request history and the
+notifications work queue) are provided via Deps. Router allows the routes to
+be replaced when the configuration is reloaded without interrupting requests
+already in progress.
+*/
+package server
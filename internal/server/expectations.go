@@ -0,0 +1,538 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/jsonmatch"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// API endpoint patterns used to manage expected client requests.
+const (
+	apiV1ExpectationsEndpointPattern string = "/api/v1/expectations"
+	apiV1ExpectationEndpointPattern  string = "/api/v1/expectations/{id}"
+)
+
+// Expectation status values.
+const (
+	expectationStatusPending string = "pending"
+	expectationStatusMet     string = "met"
+	expectationStatusExpired string = "expired"
+)
+
+const (
+	// defaultExpectationTimeout is how long an expectation waits for
+	// matching client requests if a timeout is not specified.
+	defaultExpectationTimeout time.Duration = 60 * time.Second
+
+	// maxExpectationTimeout is the longest an expectation may wait for
+	// matching client requests.
+	maxExpectationTimeout time.Duration = time.Hour
+
+	// maxExpectations is the maximum number of expectations retained. Once
+	// reached, the oldest expectation no longer pending is discarded when a
+	// new expectation is registered.
+	maxExpectations int = 100
+
+	// maxClosestMismatches is the number of non-matching client requests
+	// retained for each expectation.
+	maxClosestMismatches int = 3
+)
+
+// expectationSpec describes the client requests expected by an expectation.
+type expectationSpec struct {
+
+	// Path is the expected request path.
+	Path string `json:"path"`
+
+	// Method is the expected request method. Any method matches if empty.
+	Method string `json:"method,omitempty"`
+
+	// Headers are expected request header values, keyed by header name. A
+	// header matches if any of its values is equal to the expected value.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// JSON is the expected JSON request body, matched as a subset of the
+	// actual request body. The request body is not checked if nil.
+	JSON interface{} `json:"json,omitempty"`
+
+	// Count is the number of matching client requests expected.
+	Count int `json:"count"`
+
+	// TimeoutMs is the number of milliseconds to wait for matching client
+	// requests.
+	TimeoutMs int `json:"timeout_ms"`
+}
+
+// expectationMismatch is a client request which did not match an
+// expectation, along with the differences found.
+type expectationMismatch struct {
+	RequestID    string    `json:"request_id"`
+	ReceivedAt   time.Time `json:"received_at"`
+	HTTPMethod   string    `json:"http_method"`
+	EndpointPath string    `json:"endpoint_path"`
+	Differences  []string  `json:"differences"`
+}
+
+// expectation is a registered expectation along with the client requests
+// observed for it so far.
+type expectation struct {
+	ID                string                `json:"id"`
+	Status            string                `json:"status"`
+	Expected          expectationSpec       `json:"expected"`
+	RegisteredAt      time.Time             `json:"registered_at"`
+	ExpiresAt         time.Time             `json:"expires_at"`
+	Matched           int                   `json:"matched"`
+	MatchedRequestIDs []string              `json:"matched_request_ids"`
+	ClosestMismatches []expectationMismatch `json:"closest_mismatches"`
+
+	// spec is the expected client requests as registered. Expected is the
+	// same with the values of credential headers redacted.
+	spec expectationSpec
+
+	// settled is closed once the expectation is no longer pending.
+	settled chan struct{}
+	timer   *time.Timer
+}
+
+// ExpectationStore retains registered expectations and checks each captured
+// client request against those still pending.
+type ExpectationStore struct {
+	byID    map[string]*expectation
+	redact  func(http.Header) http.Header
+	entries []*expectation
+	mu      sync.Mutex
+}
+
+// NewExpectationStore creates an empty ExpectationStore. The given function
+// (e.g., capture.Store.RedactHeaders) redacts the values of credential
+// headers from expected and observed header values before they are reported.
+// Header values are reported as-is if nil.
+func NewExpectationStore(redact func(http.Header) http.Header) *ExpectationStore {
+	return &ExpectationStore{
+		byID:   make(map[string]*expectation),
+		redact: redact,
+	}
+}
+
+// redactHeaders returns a copy of the given headers with the values of
+// credential headers redacted.
+func (es *ExpectationStore) redactHeaders(headers http.Header) http.Header {
+	if es.redact == nil {
+		return headers.Clone()
+	}
+
+	return es.redact(headers)
+}
+
+// validate checks the expectation settings, applying defaults as needed.
+func (spec *expectationSpec) validate() error {
+
+	if !strings.HasPrefix(spec.Path, "/") {
+		return fmt.Errorf("invalid path %q; an absolute request path is required", spec.Path)
+	}
+
+	if spec.Count < 0 || spec.TimeoutMs < 0 {
+		return fmt.Errorf("invalid count (%d) or timeout (%d ms)", spec.Count, spec.TimeoutMs)
+	}
+
+	if maxTimeoutMs := int(maxExpectationTimeout / time.Millisecond); spec.TimeoutMs > maxTimeoutMs {
+		return fmt.Errorf("invalid timeout (%d ms); value must not exceed %d ms", spec.TimeoutMs, maxTimeoutMs)
+	}
+
+	if spec.Count == 0 {
+		spec.Count = 1
+	}
+
+	if spec.TimeoutMs == 0 {
+		spec.TimeoutMs = int(defaultExpectationTimeout / time.Millisecond)
+	}
+
+	spec.Method = strings.ToUpper(spec.Method)
+
+	return nil
+}
+
+// compare returns the differences between the expected and the captured
+// client request. An empty result indicates that the client request matches.
+// Header values are reported using the redacted expected values and the
+// given (redacted) headers of the client request.
+func (e *expectation) compare(clientRequest capture.Request, reported http.Header) []string {
+
+	spec := e.spec
+
+	var diffs []string
+
+	if clientRequest.EndpointPath != spec.Path {
+		diffs = append(diffs, fmt.Sprintf("path: expected %q, got %q", spec.Path, clientRequest.EndpointPath))
+	}
+
+	if spec.Method != "" && !strings.EqualFold(clientRequest.HTTPMethod, spec.Method) {
+		diffs = append(diffs, fmt.Sprintf("method: expected %q, got %q", spec.Method, clientRequest.HTTPMethod))
+	}
+
+	names := make([]string, 0, len(spec.Headers))
+	for name := range spec.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := clientRequest.Headers.Values(name)
+		if !containsString(values, spec.Headers[name]) {
+			diffs = append(diffs, fmt.Sprintf(
+				"header %s: expected %q, got %q",
+				http.CanonicalHeaderKey(name),
+				e.Expected.Headers[name],
+				reported.Values(name),
+			))
+		}
+	}
+
+	if spec.JSON != nil {
+		var body interface{}
+		if err := json.Unmarshal([]byte(clientRequest.Body), &body); err != nil {
+			diffs = append(diffs, fmt.Sprintf("body: expected JSON, got invalid JSON: %v", err))
+			return diffs
+		}
+
+		for _, diff := range jsonmatch.Subset(spec.JSON, body) {
+			diffs = append(diffs, "json "+diff.String())
+		}
+	}
+
+	return diffs
+}
+
+// containsString indicates whether the given value is in the list.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newExpectationID generates a random expectation ID encoded as a hex
+// string.
+func newExpectationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("newExpectationID: failed to generate expectation ID: %v", err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// add registers a new expectation using the given (validated) settings. The
+// oldest expectation no longer pending is discarded if the maximum number of
+// expectations are retained. An error is returned if all retained
+// expectations are pending.
+func (es *ExpectationStore) add(spec expectationSpec) (expectation, error) {
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if len(es.entries) >= maxExpectations {
+		discarded := false
+		for i, e := range es.entries {
+			if e.Status != expectationStatusPending {
+				delete(es.byID, e.ID)
+				es.entries = append(es.entries[:i], es.entries[i+1:]...)
+				discarded = true
+				break
+			}
+		}
+
+		if !discarded {
+			return expectation{}, fmt.Errorf("limit of %d pending expectations reached", maxExpectations)
+		}
+	}
+
+	now := time.Now()
+	timeout := time.Duration(spec.TimeoutMs) * time.Millisecond
+
+	expected := spec
+	if len(spec.Headers) > 0 {
+		headers := make(http.Header, len(spec.Headers))
+		for name, value := range spec.Headers {
+			headers.Set(name, value)
+		}
+		headers = es.redactHeaders(headers)
+
+		expected.Headers = make(map[string]string, len(spec.Headers))
+		for name := range spec.Headers {
+			expected.Headers[name] = headers.Get(name)
+		}
+	}
+
+	e := &expectation{
+		ID:                newExpectationID(),
+		Status:            expectationStatusPending,
+		Expected:          expected,
+		spec:              spec,
+		RegisteredAt:      now,
+		ExpiresAt:         now.Add(timeout),
+		MatchedRequestIDs: []string{},
+		ClosestMismatches: []expectationMismatch{},
+		settled:           make(chan struct{}),
+	}
+
+	e.timer = time.AfterFunc(timeout, func() {
+		es.mu.Lock()
+		defer es.mu.Unlock()
+
+		if e.Status == expectationStatusPending {
+			e.Status = expectationStatusExpired
+			close(e.settled)
+		}
+	})
+
+	es.entries = append(es.entries, e)
+	es.byID[e.ID] = e
+
+	return e.snapshot(), nil
+}
+
+// snapshot returns a copy of the expectation which is safe to use once the
+// lock is released. The caller is responsible for holding the lock.
+func (e *expectation) snapshot() expectation {
+	s := *e
+	s.MatchedRequestIDs = append([]string{}, e.MatchedRequestIDs...)
+	s.ClosestMismatches = append([]expectationMismatch{}, e.ClosestMismatches...)
+	s.timer = nil
+
+	return s
+}
+
+// observe checks the given client request against each pending
+// expectation, recording a match or, if the client request is among the
+// closest mismatches so far, the differences found.
+func (es *ExpectationStore) observe(clientRequest capture.Request) {
+
+	if es == nil {
+		return
+	}
+
+	reported := es.redactHeaders(clientRequest.Headers)
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	for _, e := range es.entries {
+		if e.Status != expectationStatusPending {
+			continue
+		}
+
+		diffs := e.compare(clientRequest, reported)
+		if len(diffs) == 0 {
+			e.Matched++
+			e.MatchedRequestIDs = append(e.MatchedRequestIDs, clientRequest.RequestID)
+
+			if e.Matched >= e.spec.Count {
+				e.Status = expectationStatusMet
+				e.timer.Stop()
+				close(e.settled)
+			}
+
+			continue
+		}
+
+		e.ClosestMismatches = append(e.ClosestMismatches, expectationMismatch{
+			RequestID:    clientRequest.RequestID,
+			ReceivedAt:   clientRequest.ReceivedAt,
+			HTTPMethod:   clientRequest.HTTPMethod,
+			EndpointPath: clientRequest.EndpointPath,
+			Differences:  diffs,
+		})
+
+		// Fewer differences first; the most recent first for the same number
+		// of differences.
+		sort.SliceStable(e.ClosestMismatches, func(i, j int) bool {
+			a, b := e.ClosestMismatches[i], e.ClosestMismatches[j]
+			if len(a.Differences) != len(b.Differences) {
+				return len(a.Differences) < len(b.Differences)
+			}
+			return a.ReceivedAt.After(b.ReceivedAt)
+		})
+
+		if len(e.ClosestMismatches) > maxClosestMismatches {
+			e.ClosestMismatches = e.ClosestMismatches[:maxClosestMismatches]
+		}
+	}
+}
+
+// list returns all retained expectations, oldest first.
+func (es *ExpectationStore) list() []expectation {
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	list := make([]expectation, 0, len(es.entries))
+	for _, e := range es.entries {
+		list = append(list, e.snapshot())
+	}
+
+	return list
+}
+
+// get returns the expectation with the given ID along with a channel which
+// is closed once the expectation is no longer pending.
+func (es *ExpectationStore) get(id string) (expectation, <-chan struct{}, bool) {
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	e, ok := es.byID[id]
+	if !ok {
+		return expectation{}, nil, false
+	}
+
+	return e.snapshot(), e.settled, true
+}
+
+// remove discards the expectation with the given ID, indicating whether it
+// was found.
+func (es *ExpectationStore) remove(id string) bool {
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	e, ok := es.byID[id]
+	if !ok {
+		return false
+	}
+
+	e.timer.Stop()
+	if e.Status == expectationStatusPending {
+		close(e.settled)
+	}
+
+	delete(es.byID, id)
+	for i := range es.entries {
+		if es.entries[i] == e {
+			es.entries = append(es.entries[:i], es.entries[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// handleExpectations lists the registered expectations.
+func handleExpectations(es *ExpectationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleExpectations endpoint hit")
+
+		writeJSON(w, es.list())
+	}
+}
+
+// handleAdminAddExpectation registers a new expectation.
+func handleAdminAddExpectation(es *ExpectationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminAddExpectation endpoint hit")
+
+		var spec expectationSpec
+		if !decodeJSONRequest(w, r, &spec) {
+			return
+		}
+
+		if err := spec.validate(); err != nil {
+			routes.WriteError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		e, err := es.add(spec)
+		if err != nil {
+			routes.WriteError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+
+		adminLog(r).WithFields(log.Fields{
+			"expectation_id": e.ID,
+			"path":           spec.Path,
+		}).Info("handleAdminAddExpectation: expectation registered")
+
+		w.Header().Set("Location", apiV1ExpectationsEndpointPattern+"/"+e.ID)
+		writeJSONStatus(w, http.StatusCreated, e)
+	}
+}
+
+// handleExpectation returns the expectation with the ID specified in the
+// request path. If the wait query parameter is true, the response is delayed
+// until the expectation is no longer pending.
+func handleExpectation(es *ExpectationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleExpectation endpoint hit")
+
+		id := r.PathValue("id")
+
+		e, settled, ok := es.get(id)
+		if !ok {
+			routes.WriteError(w, r, http.StatusNotFound, "No expectation with the specified ID")
+			return
+		}
+
+		if wait := r.URL.Query().Get("wait"); wait != "" {
+			shouldWait, err := strconv.ParseBool(wait)
+			if err != nil {
+				routes.WriteError(w, r, http.StatusBadRequest, "Invalid wait query parameter value")
+				return
+			}
+
+			if shouldWait && e.Status == expectationStatusPending {
+				select {
+				case <-settled:
+				case <-r.Context().Done():
+					return
+				}
+
+				if e, _, ok = es.get(id); !ok {
+					routes.WriteError(w, r, http.StatusNotFound, "No expectation with the specified ID")
+					return
+				}
+			}
+		}
+
+		writeJSON(w, e)
+	}
+}
+
+// handleAdminRemoveExpectation removes the expectation with the ID specified
+// in the request path.
+func handleAdminRemoveExpectation(es *ExpectationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleAdminRemoveExpectation endpoint hit")
+
+		id := r.PathValue("id")
+		if !es.remove(id) {
+			routes.WriteError(w, r, http.StatusNotFound, "No expectation with the specified ID")
+			return
+		}
+
+		adminLog(r).WithField("expectation_id", id).Info("handleAdminRemoveExpectation: expectation removed")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
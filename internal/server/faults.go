@@ -0,0 +1,465 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/fault"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// Admin API endpoint patterns used to manage fault injection at runtime.
+const (
+	adminAPIV1FaultsEndpointPattern string = "/admin/api/v1/faults"
+	adminAPIV1FaultEndpointPattern  string = "/admin/api/v1/faults/{route}"
+)
+
+// Fault types recorded for client requests. Injected latency is recorded
+// separately since it may be combined with any of these.
+const (
+	faultTypeError    string = "error"
+	faultTypeReset    string = "reset"
+	faultTypeTruncate string = "truncate"
+	faultTypeSlowDrip string = "slow_drip"
+	faultTypeHang     string = "hang"
+)
+
+// Sources of the fault injection settings in effect for a route.
+const (
+	faultSourceOverride        string = "override"
+	faultSourceConfig          string = "config"
+	faultSourceDefaultOverride string = "default override"
+	faultSourceDefaultConfig   string = "default config"
+)
+
+// Defaults used for fault injection settings which are not specified.
+const (
+	defaultSlowDripChunkBytes int           = 1
+	defaultSlowDripInterval   time.Duration = time.Second
+)
+
+// faultContextKey is the context key used to record the faults injected for
+// a client request.
+type faultContextKey struct{}
+
+// faultFromContext returns the faults injected for the client request, or
+// nil if no faults were injected.
+func faultFromContext(ctx context.Context) *capture.FaultDetails {
+	details, _ := ctx.Value(faultContextKey{}).(*capture.FaultDetails)
+	return details
+}
+
+// faultSettings determines the fault injection settings in effect for each
+// route from the configuration file and the runtime overrides.
+type faultSettings struct {
+	configured map[string]config.FaultInjection
+	overrides  *Overrides[config.FaultInjection]
+}
+
+// lookup returns the fault injection settings in effect for the given route
+// along with their source. Settings specific to the route take precedence
+// over those specified for the default route; within each, runtime
+// overrides take precedence over the configuration file. An empty source
+// indicates that no faults are injected for the route.
+func (fs faultSettings) lookup(routeName string) (config.FaultInjection, string) {
+
+	if f, ok := fs.overrides.get(routeName); ok {
+		return f, faultSourceOverride
+	}
+
+	if f, ok := fs.configured[routeName]; ok {
+		return f, faultSourceConfig
+	}
+
+	if f, ok := fs.overrides.get(config.DefaultRouteName); ok {
+		return f, faultSourceDefaultOverride
+	}
+
+	if f, ok := fs.configured[config.DefaultRouteName]; ok {
+		return f, faultSourceDefaultConfig
+	}
+
+	return config.FaultInjection{}, ""
+}
+
+// chance indicates whether an event with the given percentage chance occurs.
+func chance(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// injectedLatency returns the latency to inject for a request, if any.
+func injectedLatency(lf *config.LatencyFault) time.Duration {
+
+	if lf == nil || !chance(lf.Percent) {
+		return 0
+	}
+
+	delay := time.Duration(lf.DelayMs) * time.Millisecond
+
+	switch lf.Distribution {
+	case config.LatencyDistributionUniform:
+		spread := time.Duration(lf.MaxDelayMs-lf.DelayMs) * time.Millisecond
+		delay += rand.N(spread + 1)
+
+	case config.LatencyDistributionNormal:
+		stdDev := time.Duration(lf.StdDevMs) * time.Millisecond
+		delay += time.Duration(rand.NormFloat64() * float64(stdDev))
+	}
+
+	return max(delay, 0)
+}
+
+// injectedFault returns the type of fault to inject for a request, if any.
+// A single random value is used so that at most one fault is chosen, with
+// each fault chosen for its configured percentage of requests.
+func injectedFault(f config.FaultInjection) string {
+
+	type candidate struct {
+		faultType string
+		percent   float64
+	}
+
+	var candidates []candidate
+	if f.Error != nil {
+		candidates = append(candidates, candidate{faultTypeError, f.Error.Percent})
+	}
+	if f.Reset != nil {
+		candidates = append(candidates, candidate{faultTypeReset, f.Reset.Percent})
+	}
+	if f.Truncate != nil {
+		candidates = append(candidates, candidate{faultTypeTruncate, f.Truncate.Percent})
+	}
+	if f.SlowDrip != nil {
+		candidates = append(candidates, candidate{faultTypeSlowDrip, f.SlowDrip.Percent})
+	}
+	if f.Hang != nil {
+		candidates = append(candidates, candidate{faultTypeHang, f.Hang.Percent})
+	}
+
+	roll := rand.Float64() * 100
+
+	var threshold float64
+	for _, candidate := range candidates {
+		threshold += candidate.percent
+		if roll < threshold {
+			return candidate.faultType
+		}
+	}
+
+	return ""
+}
+
+// applyFaults adds fault injection middleware to each of the given routes.
+// The middleware is added regardless of the configured settings so that
+// faults may be enabled at runtime via the admin API.
+func applyFaults(ctx context.Context, rs *routes.Routes, routeNames []string, settings faultSettings) {
+
+	eligible := make(map[string]bool, len(routeNames))
+	for _, name := range routeNames {
+		eligible[name] = true
+	}
+
+	for i := range *rs {
+		route := &(*rs)[i]
+
+		if !eligible[route.Name] {
+			continue
+		}
+
+		route.Use(faultMiddleware(ctx, route.Name, settings))
+	}
+}
+
+// faultMiddleware injects the faults in effect for the route into a
+// percentage of responses. Injected latency delays the request before it is
+// handled. Requests affected by other faults are still handled (and so are
+// recorded and generate notifications as usual), but the response is
+// buffered and then replaced, discarded or sent in a damaged form. The
+// injected faults are recorded in the request context for use by handlers.
+func faultMiddleware(ctx context.Context, routeName string, settings faultSettings) routes.Middleware {
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			f, source := settings.lookup(routeName)
+			if source == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			latency := injectedLatency(f.Latency)
+			faultType := injectedFault(f)
+
+			if latency == 0 && faultType == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			details := &capture.FaultDetails{
+				Type:      faultType,
+				LatencyMs: latency.Milliseconds(),
+			}
+
+			status := config.DefaultFaultStatus
+			if faultType == faultTypeError {
+				if f.Error.Status != 0 {
+					status = f.Error.Status
+				}
+				details.Status = status
+			}
+
+			ctxLog := log.WithFields(log.Fields{
+				"request_id": routes.RequestIDFromContext(r.Context()),
+				"route":      routeName,
+				"source":     source,
+				"fault":      faultType,
+				"latency":    latency,
+			})
+			ctxLog.Info("faultMiddleware: injecting fault")
+
+			r = r.WithContext(context.WithValue(r.Context(), faultContextKey{}, details))
+
+			if latency > 0 {
+				timer := time.NewTimer(latency)
+				select {
+				case <-timer.C:
+				case <-r.Context().Done():
+					timer.Stop()
+					ctxLog.Debug("faultMiddleware: client disconnected during injected latency")
+					return
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+
+			if faultType == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Slow and hanging responses are expected to exceed the server
+			// write timeout; that is the point.
+			if faultType == faultTypeSlowDrip || faultType == faultTypeHang {
+				err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+				if err != nil && !errors.Is(err, http.ErrNotSupported) {
+					ctxLog.Debugf("faultMiddleware: failed to clear write deadline: %v", err)
+				}
+			}
+
+			buf := fault.NewBuffer()
+			next.ServeHTTP(buf, r)
+
+			switch faultType {
+			case faultTypeError:
+				if f.Error.Body == "" {
+					routes.WriteError(w, r, status, "")
+					return
+				}
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(status)
+				if _, err := w.Write([]byte(f.Error.Body)); err != nil {
+					ctxLog.Debugf("faultMiddleware: failed to write error response: %v", err)
+				}
+
+			case faultTypeReset:
+				panic(http.ErrAbortHandler)
+
+			case faultTypeTruncate:
+				n := f.Truncate.Bytes
+				if n == 0 {
+					n = buf.Len() / 2
+				}
+				if err := buf.SendTruncated(w, n); err != nil {
+					ctxLog.Debugf("faultMiddleware: %v", err)
+				}
+				panic(http.ErrAbortHandler)
+
+			case faultTypeSlowDrip:
+				chunkBytes := f.SlowDrip.ChunkBytes
+				if chunkBytes == 0 {
+					chunkBytes = defaultSlowDripChunkBytes
+				}
+				interval := time.Duration(f.SlowDrip.IntervalMs) * time.Millisecond
+				if interval == 0 {
+					interval = defaultSlowDripInterval
+				}
+
+				sendCtx, cancel := context.WithCancel(r.Context())
+				stop := context.AfterFunc(ctx, cancel)
+				err := buf.SendSlowly(sendCtx, w, chunkBytes, interval)
+				stop()
+				cancel()
+
+				if err != nil {
+					ctxLog.Debugf("faultMiddleware: slow response not completed: %v", err)
+					panic(http.ErrAbortHandler)
+				}
+
+			case faultTypeHang:
+				var timeout <-chan time.Time
+				if f.Hang.TimeoutMs > 0 {
+					timer := time.NewTimer(time.Duration(f.Hang.TimeoutMs) * time.Millisecond)
+					defer timer.Stop()
+					timeout = timer.C
+				}
+
+				select {
+				case <-timeout:
+					ctxLog.Debug("faultMiddleware: hang timeout reached, closing connection")
+				case <-r.Context().Done():
+					ctxLog.Debug("faultMiddleware: client disconnected from hanging request")
+				case <-ctx.Done():
+					ctxLog.Debug("faultMiddleware: hanging request ended due to shutdown")
+				}
+				panic(http.ErrAbortHandler)
+			}
+		})
+	}
+}
+
+// faultRouteSettings is the fault injection settings in effect for a route,
+// as returned by the admin API.
+type faultRouteSettings struct {
+
+	// Faults is the fault injection settings in effect for the route.
+	Faults config.FaultInjection `json:"faults"`
+
+	// Route is the route name.
+	Route string `json:"route"`
+
+	// Source indicates where the settings were specified; this is empty if
+	// no faults are injected for the route.
+	Source string `json:"source"`
+}
+
+// faultSettingsList is the list of fault injection settings returned by the
+// admin API.
+type faultSettingsList struct {
+
+	// Configured is the collection of settings specified via the
+	// configuration file, keyed by route name.
+	Configured map[string]config.FaultInjection `json:"configured"`
+
+	// Overrides is the collection of settings specified at runtime, keyed
+	// by route name.
+	Overrides map[string]config.FaultInjection `json:"overrides"`
+
+	// Routes is the list of settings in effect for each route which
+	// supports fault injection.
+	Routes []faultRouteSettings `json:"routes"`
+}
+
+// handleFaults lists the fault injection settings specified via the
+// configuration file and the admin API along with the settings in effect for
+// each route which supports fault injection.
+func handleFaults(settings faultSettings, routeNames []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleFaults endpoint hit")
+
+		configured := settings.configured
+		if configured == nil {
+			configured = map[string]config.FaultInjection{}
+		}
+
+		list := faultSettingsList{
+			Configured: configured,
+			Overrides:  settings.overrides.all(),
+			Routes:     make([]faultRouteSettings, 0, len(routeNames)),
+		}
+
+		sorted := append([]string(nil), routeNames...)
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			f, source := settings.lookup(name)
+			list.Routes = append(list.Routes, faultRouteSettings{
+				Faults: f,
+				Route:  name,
+				Source: source,
+			})
+		}
+
+		writeJSON(w, list)
+	}
+}
+
+// handleFault returns, replaces (PUT) or removes (DELETE) the runtime fault
+// injection settings for the route specified in the request path. The
+// default route name may be used to specify settings for all routes without
+// settings of their own. Removing the runtime settings for a route restores
+// the settings from the configuration file.
+func handleFault(settings faultSettings, routeNames []string) http.HandlerFunc {
+
+	eligible := map[string]bool{config.DefaultRouteName: true}
+	for _, name := range routeNames {
+		eligible[name] = true
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleFault endpoint hit")
+
+		routeName := r.PathValue("route")
+		if !eligible[routeName] {
+			routes.WriteError(w, r, http.StatusNotFound, "No route supporting fault injection with the specified name")
+			return
+		}
+
+		ctxLog := adminLog(r).WithField("route", routeName)
+
+		switch r.Method {
+		case http.MethodPut:
+			var f config.FaultInjection
+			if !decodeJSONRequest(w, r, &f) {
+				return
+			}
+
+			if err := config.ValidateFaultInjection(f); err != nil {
+				routes.WriteError(w, r, http.StatusUnprocessableEntity, err.Error())
+				return
+			}
+
+			settings.overrides.Set(routeName, f)
+			ctxLog.Infof("handleFault: fault injection settings replaced: %+v", f)
+
+		case http.MethodDelete:
+			if !settings.overrides.Remove(routeName) {
+				routes.WriteError(w, r, http.StatusNotFound, "No runtime fault injection settings for the specified route")
+				return
+			}
+			ctxLog.Info("handleFault: fault injection settings removed")
+		}
+
+		f, source := settings.lookup(routeName)
+		writeJSON(w, faultRouteSettings{
+			Faults: f,
+			Route:  routeName,
+			Source: source,
+		})
+	}
+}
+
+// echoRouteNames returns the names of the routes served by echoHandler: the
+// built-in echo routes and the routes defined via the configuration file.
+// These routes support fault injection and runtime response rules.
+func echoRouteNames(cfg *config.Config) []string {
+	names := []string{"echo", "echo-json"}
+	for _, route := range cfg.Routes {
+		names = append(names, route.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	textTemplate "text/template"
+
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/export"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/yamlconv"
+	"github.com/golang/gddo/httputil/header"
+)
+
+// echoFormatQueryParam is the query string parameter clients may use to
+// request a specific format for client request details.
+const echoFormatQueryParam string = "format"
+
+// echoFormatMediaTypes maps media types which clients may request via the
+// Accept header to the corresponding format.
+var echoFormatMediaTypes = map[string]string{
+	"text/plain":           config.EchoFormatText,
+	"application/json":     config.EchoFormatJSON,
+	"application/yaml":     config.EchoFormatYAML,
+	"application/x-yaml":   config.EchoFormatYAML,
+	"text/yaml":            config.EchoFormatYAML,
+	"text/x-yaml":          config.EchoFormatYAML,
+	"application/har+json": config.EchoFormatHAR,
+}
+
+// echoFormatContentTypes maps each format to the Content-Type used for
+// responses.
+var echoFormatContentTypes = map[string]string{
+	config.EchoFormatText: "text/plain; charset=utf-8",
+	config.EchoFormatJSON: "application/json; charset=utf-8",
+	config.EchoFormatYAML: "application/yaml; charset=utf-8",
+	config.EchoFormatHAR:  "application/json; charset=utf-8",
+}
+
+// negotiateEchoFormat determines the format used for client request details
+// returned to the client. A format specified via the format query parameter
+// takes precedence, followed by the media type with the highest quality
+// value in the Accept header. The default format is used if neither
+// specifies a supported format (e.g., the Accept header is not provided or
+// only lists wildcards).
+func negotiateEchoFormat(r *http.Request, defaultFormat string) (string, error) {
+
+	if format := r.URL.Query().Get(echoFormatQueryParam); format != "" {
+		if !config.ValidEchoFormat(format) {
+			return "", fmt.Errorf(
+				"unsupported format %q; supported formats: %s",
+				format,
+				strings.Join(config.EchoFormats, ", "),
+			)
+		}
+		return format, nil
+	}
+
+	format, bestQ := defaultFormat, 0.0
+	for _, spec := range header.ParseAccept(r.Header, "Accept") {
+		candidate, ok := echoFormatMediaTypes[strings.ToLower(spec.Value)]
+		if !ok {
+			if !strings.HasSuffix(spec.Value, "/*") {
+				continue
+			}
+			candidate = defaultFormat
+		}
+
+		if spec.Q > bestQ {
+			format, bestQ = candidate, spec.Q
+		}
+	}
+
+	return format, nil
+}
+
+// writeEchoDetails writes the client request details to the given writer
+// using the specified format. The template is used for the text format.
+func writeEchoDetails(w io.Writer, format string, tmpl *textTemplate.Template, clientRequest capture.Request) error {
+
+	var document interface{} = clientRequest
+
+	switch format {
+	case config.EchoFormatText:
+		return tmpl.Execute(w, clientRequest)
+
+	case config.EchoFormatHAR:
+		document = export.NewHAREntry(clientRequest)
+	}
+
+	// Request details are not embedded in HTML, so escaping of HTML
+	// characters (e.g., the & in query strings) is disabled.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(document); err != nil {
+		return fmt.Errorf("failed to encode client request details: %w", err)
+	}
+
+	data := buf.Bytes()
+	if format == config.EchoFormatYAML {
+		var err error
+		data, err = yamlconv.FromJSON(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode client request details: %w", err)
+		}
+	}
+
+	_, err := w.Write(data)
+
+	return err
+}
@@ -0,0 +1,469 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
+
+	"github.com/TylerBrock/colorjson"
+	"github.com/apex/log"
+)
+
+// API endpoint patterns supported by this application
+//
+// TODO: Find a better location for these values
+const (
+	apiV1EchoEndpointPattern     string = "/api/v1/echo"
+	apiV1EchoJSONEndpointPattern string = "/api/v1/echo/json"
+)
+
+// MB is a convenience constant that represents 1 Megabyte, which so happens
+// to be the limit we're placing on request bodies (in order to help limit the
+// impact from misconfigured http clients).
+// TODO: Find a better location for this constant
+const MB int64 = 1048576
+
+// flushResponse sends any buffered response data to the client. The caller's
+// name is used as the prefix for log messages. false is returned if the
+// http.ResponseWriter cannot be flushed.
+func flushResponse(w http.ResponseWriter, caller string) bool {
+
+	// Manually flush http.ResponseWriter
+	// https://blog.simon-frey.eu/manual-flush-golang-http-responsewriter/
+	f, ok := w.(http.Flusher)
+	if !ok {
+		log.Warn(caller + ": http.Flusher interface not available, cannot flush http.ResponseWriter")
+		log.Warn(caller + ": Not flushing http.ResponseWriter may cause a noticeable delay between requests")
+
+		return false
+	}
+
+	log.Debug(caller + ": Manually flushing http.ResponseWriter")
+	f.Flush()
+
+	return true
+}
+
+// newClientRequestDetails records the details common to all client requests.
+// The request body is not read. The request headers are copied so that the
+// captured client request may be shared (e.g., with the notifications
+// manager) while the request is still being handled.
+func newClientRequestDetails(r *http.Request, ipResolver *clientip.Resolver) capture.Request {
+
+	clientIP := ipResolver.Resolve(r)
+
+	// Work around Teams choosing to ignore time.RFC3339 designation and
+	// display as localtime by explicitly converting to localtime
+	receivedAt := time.Now()
+
+	return capture.Request{
+		RequestID:        routes.RequestIDFromContext(r.Context()),
+		CaptureID:        newCaptureID(),
+		ReceivedAt:       receivedAt,
+		Datestamp:        receivedAt.Format("2006-01-02 15:04:05"),
+		RequestURL:       requestURL(r),
+		Protocol:         r.Proto,
+		ConnectionReused: routes.ConnectionRequestFromContext(r.Context()) > 1,
+		TLS:              capture.NewTLSDetails(r.TLS),
+		EndpointPath:     r.URL.Path,
+		HTTPMethod:       r.Method,
+		ClientIPAddress:  clientIP.ClientIP,
+		ClientIPSource:   clientIP.Source,
+		ProxyChain:       clientIP.ProxyChain,
+		Headers:          r.Header.Clone(),
+		Fault:            faultFromContext(r.Context()),
+	}
+}
+
+// newCaptureID generates a random 128-bit capture ID encoded as a hex string.
+func newCaptureID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("newCaptureID: failed to generate capture ID: %v", err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// handleIndex receives our HTML template and our defined routes as a pointer.
+// Both are used to generate a dynamic index of the available routes or
+// "endpoints" for users to target with test payloads. A pointer is used because
+// by the time this handler is defined, the full set of routes has *not* been
+// defined. Using a pointer, we are able to access the complete collection
+// of defined routes when this handler is finally called.
+func handleIndex(tmpls *TemplateSet, rs *routes.Routes) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		ctxLog := log.WithFields(log.Fields{
+			"url_path":   r.URL.Path,
+			"num_routes": len(*rs),
+		})
+
+		ctxLog.Debug("handleIndex endpoint hit")
+
+		// https://github.com/golang/go/issues/4799
+		// https://github.com/golang/go/commit/1a819be59053fa1d6b76cb9549c9a117758090ee
+		if r.URL.Path != "/" {
+			ctxLog.Debug("Rejecting request not explicitly handled by a route")
+			routes.WriteError(w, r, http.StatusNotFound, "")
+			return
+		}
+
+		for _, route := range *rs {
+			log.Debugf("route: %+v", route)
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		err := tmpls.Index().Execute(w, *rs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			ctxLog.Error(err.Error())
+		}
+
+	}
+
+}
+
+// echoOptions controls how echoHandler processes requests for a route. The
+// built-in echo routes and user-defined routes are all served by echoHandler
+// using different options.
+type echoOptions struct {
+
+	// Response controls the response returned to clients. If nil, the client
+	// request details are echoed back to the client.
+	Response *config.ResponseRule
+
+	// ResponseOverrides is the collection of response rules specified at
+	// runtime via the admin API. A rule specified for the route takes
+	// precedence over Response.
+	ResponseOverrides *Overrides[config.ResponseRule]
+
+	// Name is the route name, used to look up runtime response rules.
+	Name string
+
+	// Expectations is the collection of expected client requests checked
+	// against each client request received by the route.
+	Expectations *ExpectationStore
+
+	// Feed broadcasts each client request received by the route to history
+	// stream clients.
+	Feed *capture.Feed
+
+	// OnCapture, if set, is called with each client request received by the
+	// route.
+	OnCapture func(capture.Request)
+
+	// Formatter controls how the request body is processed.
+	Formatter string
+
+	// Pattern is the route pattern, used to collect path parameters.
+	Pattern string
+
+	// Notifiers is the list of notifiers used for requests received by the
+	// route. A nil value indicates that all enabled notifiers are used.
+	Notifiers []string
+
+	// EnabledNotifiers is the list of notifiers enabled for the application.
+	EnabledNotifiers []string
+
+	// ResponseFormat is the default format used for client request details
+	// returned to the client. Clients may request a different format.
+	ResponseFormat string
+
+	// OutputFormat is the format used for client request details written to
+	// Output.
+	OutputFormat string
+
+	// Output is where client request details are written in addition to the
+	// HTTP response (e.g., stdout or an output file).
+	Output io.Writer
+
+	// ColoredJSONIndent controls how many spaces are used when indenting
+	// colorized JSON output.
+	ColoredJSONIndent int
+
+	// ColoredJSON indicates whether formatted JSON output is colorized.
+	ColoredJSON bool
+}
+
+// echoHandler echos back the HTTP request received by the client, both via
+// HTTP response and stdout, records the client request details in the request
+// history and submits them to the notifications manager. Allowed methods are
+// enforced by the routes package before requests reach this handler.
+func echoHandler(
+	_ context.Context,
+	tmpls *TemplateSet,
+	opts echoOptions,
+	ipResolver *clientip.Resolver,
+	reqHistory *capture.Store,
+	notifyWorkQueue chan<- capture.Request,
+) http.HandlerFunc {
+
+	paramNames := routes.PatternParams(opts.Pattern)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		ourResponse := capture.Request{}
+
+		response := opts.Response
+		if rule, ok := opts.ResponseOverrides.get(opts.Name); ok {
+			response = &rule
+		}
+
+		// The same template is used for the response and output even if the
+		// template is reloaded while the request is handled.
+		tmpl := tmpls.Echo()
+
+		// Static response bodies are returned in place of the client request
+		// details; the details are still written to the output target.
+		staticResponse := response != nil && response.Body != ""
+
+		responseFormat, err := negotiateEchoFormat(r, opts.ResponseFormat)
+		if err != nil {
+			log.Debugf("echoHandler: %v", err)
+			routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		if !staticResponse {
+			w.Header().Add("Vary", "Accept")
+			w.Header().Set("Content-Type", echoFormatContentTypes[responseFormat])
+		}
+
+		// TODO: Consider moving this "up" so that it can receive values as
+		// arguments instead of relying on them to be defined in the local
+		// scope?
+		writeDetails := func() {
+			if !staticResponse {
+				err := writeEchoDetails(w, responseFormat, tmpl, ourResponse)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					log.Errorf("error occurred while trying to write client request details: %v", err)
+
+					// We force a a return here since it is unlikely that we
+					// should execute any other code after failing to
+					// generate/write out the client request details
+					return
+				}
+			}
+
+			if err := writeEchoDetails(opts.Output, opts.OutputFormat, tmpl, ourResponse); err != nil {
+				log.Errorf("echoHandler: error writing client request details to output: %v", err)
+			}
+
+			flushResponse(w, "echoHandler")
+
+		}
+
+		// Record in request history and send to Notification Manager for
+		// further processing
+		notify := func() {
+			targets := ourResponse.NotifyTargets(opts.EnabledNotifiers)
+			reqHistory.Add(ourResponse, targets)
+			opts.Expectations.observe(ourResponse)
+			opts.Feed.Publish(ourResponse)
+			if opts.OnCapture != nil {
+				opts.OnCapture(ourResponse)
+			}
+
+			notifyOutcome := "none"
+			if len(targets) > 0 {
+				notifyOutcome = capture.NotifyOutcomePending + ": " + strings.Join(targets, ", ")
+			}
+			routes.AddAccessLogField(r, "notify", notifyOutcome)
+
+			go func() { notifyWorkQueue <- ourResponse }()
+		}
+
+		// respondWithError records the error, writes the error and the client
+		// request details to the client and submits the details for
+		// notification. The error message precedes the details for the text
+		// format; machine-readable formats carry the error within the
+		// document instead.
+		respondWithError := func(errorMsg string, statusCode int) {
+			if responseFormat == config.EchoFormatText {
+				http.Error(w, errorMsg, statusCode)
+			} else {
+				w.WriteHeader(statusCode)
+			}
+			log.Error("echoHandler: " + errorMsg)
+
+			writeDetails()
+			notify()
+		}
+
+		log.Debug("echoHandler: echoHandler endpoint hit")
+
+		ourResponse = newClientRequestDetails(r, ipResolver)
+		ourResponse.Notifiers = opts.Notifiers
+
+		if len(paramNames) > 0 {
+			ourResponse.PathParams = make(map[string]string, len(paramNames))
+			for _, name := range paramNames {
+				ourResponse.PathParams[name] = r.PathValue(name)
+			}
+		}
+
+		// Limit request body to 1 MB
+		r.Body = http.MaxBytesReader(w, r.Body, 1*MB)
+
+		// read everything from the (size-limited) request body so that we
+		// can display it in a raw format
+		requestBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			errorMsg := fmt.Sprintf("Error reading request body: %s", err)
+			ourResponse.BodyError = errorMsg
+			respondWithError(errorMsg, http.StatusBadRequest)
+
+			return
+		}
+
+		ourResponse.Body = string(requestBody)
+
+		// Requests without a body using methods which are not expected to
+		// provide one are echoed as-is.
+		bodyExpected := len(requestBody) > 0 ||
+			(r.Method != http.MethodGet && r.Method != http.MethodHead)
+
+		switch {
+		case !bodyExpected:
+
+		case opts.Formatter == config.FormatterJSON:
+
+			// replace the Body with a new io.ReadCloser to allow later
+			// access to r.Body for JSON-decoding purposes
+			r.Body = io.NopCloser(bytes.NewReader(requestBody))
+
+			formattedBody, err := formatJSONBody(w, r, requestBody, opts.ColoredJSON, opts.ColoredJSONIndent)
+			if err != nil {
+				errorPrefix := "JSON parse error"
+
+				// At this point we're potentially dealing with a
+				// `malformedRequest` type of error. We can reference
+				// recorded `status` and `msg` fields to provide more
+				// information.
+				var mr *malformedRequest
+				if errors.As(err, &mr) {
+					log.WithFields(log.Fields{
+						"msg":    mr.msg,
+						"status": mr.status,
+					}).Error(errorPrefix)
+
+					ourResponse.FormattedBodyError = fmt.Sprintf("%s: %s", errorPrefix, mr.msg)
+					respondWithError(mr.msg, mr.status)
+
+					return
+				}
+
+				errorMsg := fmt.Sprintf("%s: %s", errorPrefix, err.Error())
+				ourResponse.FormattedBodyError = errorMsg
+				respondWithError(errorMsg, http.StatusInternalServerError)
+
+				return
+			}
+
+			ourResponse.FormattedBody = formattedBody
+
+		default:
+			ourResponse.FormattedBodyError = fmt.Sprintf(
+				"This endpoint does not apply JSON formatting to the request body.\n"+
+					"Use the %q endpoint for JSON payload testing.",
+				apiV1EchoJSONEndpointPattern,
+			)
+		}
+
+		// Apply response rules (if any) before writing out the response.
+		if response != nil {
+			for name, value := range response.Headers {
+				w.Header().Set(name, value)
+			}
+
+			if response.ContentType != "" {
+				w.Header().Set("Content-Type", response.ContentType)
+			}
+
+			if response.Status != 0 {
+				w.WriteHeader(response.Status)
+			}
+
+			if staticResponse {
+				if _, err := io.WriteString(w, response.Body); err != nil {
+					log.Errorf("echoHandler: error writing response body: %v", err)
+				}
+			}
+		}
+
+		// If we made it this far, then presumably our data structure
+		// "ourResponse" is fully populated and we can write it out in the
+		// requested formats
+		writeDetails()
+		notify()
+	}
+}
+
+// requestURL reconstructs the absolute URL requested by the client.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// formatJSONBody decodes the JSON request body and returns a formatted copy
+// of the payload, colorized if requested.
+func formatJSONBody(w http.ResponseWriter, r *http.Request, requestBody []byte, coloredJSON bool, coloredJSONIndent int) (string, error) {
+
+	// Decode request body into JSON using helper function
+	var decodedJSON map[string]interface{}
+	if err := decodeJSONBody(w, r, &decodedJSON); err != nil {
+		return "", err
+	}
+
+	switch coloredJSON {
+	case true:
+		// Make a custom formatter with indent set
+		colorJSONFormatter := colorjson.NewFormatter()
+		colorJSONFormatter.Indent = coloredJSONIndent
+
+		// Marshall into Colorized JSON
+		jsonBytes, err := colorJSONFormatter.Marshal(decodedJSON)
+		if err != nil {
+			return "", err
+		}
+
+		return string(jsonBytes), nil
+
+	default:
+		// https://golang.org/pkg/encoding/json/#Indent
+		var prettyJSON bytes.Buffer
+		if err := json.Indent(&prettyJSON, requestBody, "", "\t"); err != nil {
+			return "", err
+		}
+
+		return prettyJSON.String(), nil
+	}
+}
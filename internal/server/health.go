@@ -0,0 +1,206 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/logfile"
+	"github.com/atc0005/bounce/internal/notify"
+)
+
+const (
+	healthzEndpointPattern string = "/healthz"
+	readyzEndpointPattern  string = "/readyz"
+)
+
+// Health check status values.
+const (
+	healthStatusOK   string = "ok"
+	healthStatusFail string = "fail"
+)
+
+// Names of the checks performed by the readiness endpoint.
+const (
+	readyCheckNotifyMgr      string = "notify_manager"
+	readyCheckNotifyQueues   string = "notify_queues"
+	readyCheckStorage        string = "storage"
+	readyCheckNotifyFailures string = "notify_failures"
+)
+
+// healthCheck is the result of a single health check.
+type healthCheck struct {
+
+	// Name is the name of the check.
+	Name string `json:"name"`
+
+	// Status is either ok or fail.
+	Status string `json:"status"`
+
+	// Detail describes the result of the check.
+	Detail string `json:"detail"`
+}
+
+// healthReport is the response for the liveness and readiness endpoints.
+type healthReport struct {
+
+	// Status is ok if all checks passed, fail otherwise.
+	Status string `json:"status"`
+
+	// Checks is the result of each check performed, if any.
+	Checks []healthCheck `json:"checks,omitempty"`
+}
+
+// newHealthCheck returns a healthCheck with the given name and detail. The
+// check fails if failed is true.
+func newHealthCheck(name string, failed bool, detail string) healthCheck {
+	status := healthStatusOK
+	if failed {
+		status = healthStatusFail
+	}
+
+	return healthCheck{
+		Name:   name,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// checkNotifyMgr checks that the notifications manager is running and has
+// recently completed an iteration of its main loop.
+func checkNotifyMgr(status *notify.Status, now time.Time) healthCheck {
+	if !status.Running() {
+		return newHealthCheck(readyCheckNotifyMgr, true, "notifications manager is not running")
+	}
+
+	age := now.Sub(status.LastHeartbeat()).Round(time.Millisecond)
+	if age > config.NotifyMgrHeartbeatTimeout {
+		return newHealthCheck(
+			readyCheckNotifyMgr,
+			true,
+			fmt.Sprintf("notifications manager unresponsive for %v", age),
+		)
+	}
+
+	return newHealthCheck(
+		readyCheckNotifyMgr,
+		false,
+		fmt.Sprintf("notifications manager running; last active %v ago", age),
+	)
+}
+
+// checkNotifyQueues checks that none of the queues used by the notifications
+// manager are full.
+func checkNotifyQueues(status *notify.Status) healthCheck {
+	var saturated []string
+	for _, queue := range status.QueueDepths() {
+		if queue.Capacity > 0 && queue.Count >= queue.Capacity {
+			saturated = append(saturated, fmt.Sprintf("%s (%d/%d)", queue.Name, queue.Count, queue.Capacity))
+		}
+	}
+
+	if len(saturated) > 0 {
+		return newHealthCheck(
+			readyCheckNotifyQueues,
+			true,
+			"saturated queues: "+strings.Join(saturated, ", "),
+		)
+	}
+
+	return newHealthCheck(readyCheckNotifyQueues, false, "no saturated queues")
+}
+
+// checkStorage checks that the log and output files (if used) are writable.
+// Request history is retained in memory and is not checked.
+func checkStorage(files []*logfile.File) healthCheck {
+	var checked []string
+	var problems []string
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+
+		checked = append(checked, file.Path())
+		if err := file.Writable(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	switch {
+	case len(problems) > 0:
+		return newHealthCheck(readyCheckStorage, true, strings.Join(problems, "; "))
+	case len(checked) == 0:
+		return newHealthCheck(readyCheckStorage, false, "no output files in use")
+	default:
+		return newHealthCheck(readyCheckStorage, false, "writable: "+strings.Join(checked, ", "))
+	}
+}
+
+// checkNotifyFailures checks that the most recent notification attempts did
+// not all fail. The check is skipped if the threshold is 0.
+func checkNotifyFailures(status *notify.Status, threshold int) healthCheck {
+	if threshold == 0 {
+		return newHealthCheck(readyCheckNotifyFailures, false, "check disabled")
+	}
+
+	failures := status.ConsecutiveFailures()
+
+	return newHealthCheck(
+		readyCheckNotifyFailures,
+		failures >= threshold,
+		fmt.Sprintf("%d consecutive failed notification attempts (threshold %d)", failures, threshold),
+	)
+}
+
+// handleHealthz reports that this application is running and able to handle
+// requests.
+func handleHealthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleHealthz endpoint hit")
+
+		writeJSON(w, healthReport{Status: healthStatusOK})
+	}
+}
+
+// handleReadyz reports whether this application is ready to handle
+// requests, along with the result of each check performed. If any check
+// fails, the response status code is 503 (Service Unavailable).
+func handleReadyz(cfg *config.Config, status *notify.Status, files []*logfile.File) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleReadyz endpoint hit")
+
+		report := healthReport{
+			Status: healthStatusOK,
+			Checks: []healthCheck{
+				checkNotifyMgr(status, time.Now()),
+				checkNotifyQueues(status),
+				checkStorage(files),
+				checkNotifyFailures(status, cfg.ReadyFailureThreshold),
+			},
+		}
+
+		statusCode := http.StatusOK
+		for _, check := range report.Checks {
+			if check.Status == healthStatusFail {
+				report.Status = healthStatusFail
+				statusCode = http.StatusServiceUnavailable
+				log.WithFields(log.Fields{
+					"check":  check.Name,
+					"detail": check.Detail,
+				}).Warn("handleReadyz: readiness check failed")
+			}
+		}
+
+		writeJSONStatus(w, statusCode, report)
+	}
+}
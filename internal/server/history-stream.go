@@ -0,0 +1,131 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/routes"
+	"github.com/atc0005/bounce/internal/sse"
+)
+
+// apiV1HistoryStreamEndpointPattern is the API endpoint pattern used to
+// stream captured client requests as they are received.
+const apiV1HistoryStreamEndpointPattern string = "/api/v1/history/stream"
+
+// HistoryStreamEventType is the event type used for each captured client
+// request sent via the history stream.
+const HistoryStreamEventType string = "request"
+
+// historyStreamBufferSize is the number of captured client requests buffered
+// for each history stream client. Captured client requests are not sent to
+// clients which fall further behind.
+const historyStreamBufferSize int = 100
+
+// historyStreamKeepAliveInterval is how often a comment is sent to history
+// stream clients while no client requests are captured. This prevents idle
+// streams from being closed by proxies.
+const historyStreamKeepAliveInterval time.Duration = 15 * time.Second
+
+// handleHistoryStream streams captured client requests to the client as
+// Server-Sent Events as they are received. Each event contains the captured
+// client request as JSON. The method and path query parameters limit the
+// client requests sent, using the same syntax as the history export
+// endpoint. Credential headers are redacted as for the request history.
+// Streams end when the application is shutdown.
+func handleHistoryStream(ctx context.Context, reqHistory *capture.Store, feed *capture.Feed) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleHistoryStream endpoint hit")
+
+		query := r.URL.Query()
+		filter := capture.Filter{
+			Method: query.Get(exportMethodQueryParam),
+			Path:   query.Get(exportPathQueryParam),
+		}
+		if err := filter.Validate(); err != nil {
+			routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		requests, unsubscribe := feed.Subscribe(historyStreamBufferSize)
+		defer unsubscribe()
+
+		ctxLog := log.WithFields(log.Fields{
+			"request_id": routes.RequestIDFromContext(r.Context()),
+			"method":     filter.Method,
+			"path":       filter.Path,
+		})
+
+		// Event streams are long-lived, so the server write timeout does
+		// not apply.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			ctxLog.Debugf("handleHistoryStream: failed to clear write deadline: %v", err)
+		}
+
+		w.Header().Set("Content-Type", sse.ContentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if !flushResponse(w, "handleHistoryStream") {
+			return
+		}
+
+		ctxLog.Info("handleHistoryStream: stream started")
+
+		keepAlive := time.NewTicker(historyStreamKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		var sent int
+		for {
+			var data []byte
+			select {
+			case clientRequest := <-requests:
+				if !filter.Match(clientRequest) {
+					continue
+				}
+
+				body, err := json.Marshal(reqHistory.Redact(clientRequest))
+				if err != nil {
+					ctxLog.Errorf("handleHistoryStream: failed to encode client request: %v", err)
+					continue
+				}
+
+				data = sse.Event{
+					ID:    clientRequest.RequestID,
+					Event: HistoryStreamEventType,
+					Data:  string(body),
+				}.Encode()
+				sent++
+
+			case <-keepAlive.C:
+				data = []byte(": keep-alive\n\n")
+
+			case <-r.Context().Done():
+				ctxLog.WithField("requests_sent", sent).Info("handleHistoryStream: client disconnected")
+				return
+
+			case <-ctx.Done():
+				ctxLog.WithField("requests_sent", sent).Info("handleHistoryStream: stream ended due to shutdown")
+				return
+			}
+
+			if _, err := w.Write(data); err != nil {
+				ctxLog.Debugf("handleHistoryStream: failed to write event: %v", err)
+				return
+			}
+			if !flushResponse(w, "handleHistoryStream") {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/export"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// API endpoint patterns used to access captured client requests.
+const (
+	apiV1HistoryEndpointPattern            string = "/api/v1/history"
+	apiV1HistoryEntryEndpointPattern       string = "/api/v1/history/{id}"
+	apiV1HistoryExportEndpointPattern      string = "/api/v1/history/export"
+	apiV1HistoryEntryExportEndpointPattern string = "/api/v1/history/{id}/export"
+)
+
+// Query string parameters used to select the client requests exported and
+// the export format.
+const (
+	exportFormatQueryParam string = "format"
+	exportMethodQueryParam string = "method"
+	exportPathQueryParam   string = "path"
+	exportSinceQueryParam  string = "since"
+	exportLimitQueryParam  string = "limit"
+)
+
+// defaultExportFormat is the export format used if not specified by the
+// client.
+const defaultExportFormat string = export.FormatHAR
+
+// writeJSON writes the given value to the client as indented JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus writes the given value as an indented JSON response using
+// the specified status code.
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Errorf("writeJSON: failed to write JSON response: %v", err)
+	}
+}
+
+// decodeJSONRequest decodes the JSON request body into dst. If the body is
+// invalid, an error response is written and false is returned.
+func decodeJSONRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+
+	err := decodeJSONBody(w, r, dst)
+	if err == nil {
+		return true
+	}
+
+	var mr *malformedRequest
+	if errors.As(err, &mr) {
+		routes.WriteError(w, r, mr.status, mr.msg)
+		return false
+	}
+
+	routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+
+	return false
+}
+
+// handleHistory lists all captured client requests retained in memory.
+func handleHistory(rh *capture.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleHistory endpoint hit")
+
+		writeJSON(w, rh.List())
+	}
+}
+
+// handleHistoryEntry returns the captured client request with the request ID
+// specified in the request path.
+func handleHistoryEntry(rh *capture.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleHistoryEntry endpoint hit")
+
+		entry, ok := rh.Get(r.PathValue("id"))
+		if !ok {
+			routes.WriteError(w, r, http.StatusNotFound, "No client request recorded with the specified request ID")
+			return
+		}
+
+		writeJSON(w, entry)
+	}
+}
+
+// exportFormat returns the export format specified via the query string, or
+// the default export format if not specified.
+func exportFormat(query url.Values) (string, error) {
+
+	format := query.Get(exportFormatQueryParam)
+	switch {
+	case format == "":
+		return defaultExportFormat, nil
+	case !export.ValidFormat(format):
+		return "", fmt.Errorf(
+			"unsupported export format %q; supported formats: %s",
+			format,
+			strings.Join(export.Formats, ", "),
+		)
+	default:
+		return format, nil
+	}
+}
+
+// exportFilter returns the filter specified via the query string used to
+// select the client requests exported.
+func exportFilter(query url.Values) (capture.Filter, error) {
+
+	filter := capture.Filter{
+		Method: query.Get(exportMethodQueryParam),
+		Path:   query.Get(exportPathQueryParam),
+	}
+
+	if value := query.Get(exportSinceQueryParam); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return capture.Filter{}, fmt.Errorf(
+				"invalid %s value %q; RFC 3339 timestamp expected",
+				exportSinceQueryParam,
+				value,
+			)
+		}
+		filter.Since = since
+	}
+
+	if value := query.Get(exportLimitQueryParam); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return capture.Filter{}, fmt.Errorf("invalid %s value %q", exportLimitQueryParam, value)
+		}
+		filter.Limit = limit
+	}
+
+	if err := filter.Validate(); err != nil {
+		return capture.Filter{}, err
+	}
+
+	return filter, nil
+}
+
+// writeExport writes the given client requests to the client using the
+// specified export format.
+func writeExport(w http.ResponseWriter, format string, requests []capture.Request) {
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, format, requests); err != nil {
+		log.Errorf("writeExport: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentTypes[format])
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Errorf("writeExport: failed to write export: %v", err)
+	}
+}
+
+// handleHistoryExport exports the captured client requests selected by the
+// filter specified via the query string (all retained client requests by
+// default) using the requested export format.
+func handleHistoryExport(rh *capture.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleHistoryExport endpoint hit")
+
+		query := r.URL.Query()
+
+		format, err := exportFormat(query)
+		if err != nil {
+			routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		filter, err := exportFilter(query)
+		if err != nil {
+			routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		writeExport(w, format, filter.Apply(capture.Requests(rh.List())))
+	}
+}
+
+// handleHistoryEntryExport exports the captured client request with the
+// request ID specified in the request path using the requested export
+// format.
+func handleHistoryEntryExport(rh *capture.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("handleHistoryEntryExport endpoint hit")
+
+		format, err := exportFormat(r.URL.Query())
+		if err != nil {
+			routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		entry, ok := rh.Get(r.PathValue("id"))
+		if !ok {
+			routes.WriteError(w, r, http.StatusNotFound, "No client request recorded with the specified request ID")
+			return
+		}
+
+		writeExport(w, format, []capture.Request{entry.Request})
+	}
+}
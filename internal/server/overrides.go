@@ -0,0 +1,68 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import "sync"
+
+// Overrides is a collection of per-route settings changed at runtime
+// via the admin API, keyed by route name. Overrides take precedence over the
+// configuration file and are retained when the configuration is reloaded.
+type Overrides[T any] struct {
+	settings map[string]T
+	mu       sync.RWMutex
+}
+
+// NewOverrides creates a new, empty Overrides.
+func NewOverrides[T any]() *Overrides[T] {
+	return &Overrides[T]{
+		settings: make(map[string]T),
+	}
+}
+
+// get returns the override for the given route name, if any.
+func (ro *Overrides[T]) get(routeName string) (T, bool) {
+	ro.mu.RLock()
+	defer ro.mu.RUnlock()
+
+	value, ok := ro.settings[routeName]
+
+	return value, ok
+}
+
+// Set replaces the override for the given route name.
+func (ro *Overrides[T]) Set(routeName string, value T) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	ro.settings[routeName] = value
+}
+
+// Remove discards the override for the given route name, indicating whether
+// an override was present.
+func (ro *Overrides[T]) Remove(routeName string) bool {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	_, ok := ro.settings[routeName]
+	delete(ro.settings, routeName)
+
+	return ok
+}
+
+// all returns a copy of all overrides.
+func (ro *Overrides[T]) all() map[string]T {
+	ro.mu.RLock()
+	defer ro.mu.RUnlock()
+
+	settings := make(map[string]T, len(ro.settings))
+	for routeName, value := range ro.settings {
+		settings[routeName] = value
+	}
+
+	return settings
+}
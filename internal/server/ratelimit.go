@@ -0,0 +1,105 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/ratelimit"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// applyRateLimits adds middleware to each route to enforce the rate limits
+// configured for the route. Routes without any enabled limits are left as-is.
+func applyRateLimits(rs *routes.Routes, cfg *config.Config, ipResolver *clientip.Resolver) {
+
+	for i := range *rs {
+		route := &(*rs)[i]
+
+		settings := cfg.RateLimitFor(route.Name)
+
+		clientLimiter := ratelimit.NewLimiter(settings.ClientPerMinute, settings.ClientBurst)
+		routeLimiter := ratelimit.NewLimiter(settings.RoutePerMinute, settings.RouteBurst)
+
+		if !clientLimiter.Enabled() && !routeLimiter.Enabled() {
+			log.Debugf("applyRateLimits: no rate limits for route %s", route.Name)
+			continue
+		}
+
+		log.Debugf("applyRateLimits: route %s rate limited: %+v", route.Name, settings)
+
+		route.Use(rateLimitMiddleware(route.Name, clientLimiter, routeLimiter, ipResolver))
+	}
+}
+
+// rateLimitMiddleware only passes requests on to the next handler if both the
+// per-client and per-route rate limits permit it. Requests exceeding either
+// limit receive a 429 response with a Retry-After header. The per-client
+// limit is only charged for requests permitted by both limits. To avoid
+// flooding the logs, only the first rejection in a series of consecutive
+// rejections is logged at a level higher than debug.
+func rateLimitMiddleware(
+	routeName string,
+	clientLimiter *ratelimit.Limiter,
+	routeLimiter *ratelimit.Limiter,
+	ipResolver *clientip.Resolver,
+) routes.Middleware {
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			clientIP := ipResolver.GetIP(r)
+
+			limit := "client"
+			result := clientLimiter.Allow(clientIP)
+			if result.Allowed {
+				limit = "route"
+				result = routeLimiter.Allow(routeName)
+
+				// Clients are not charged for requests rejected due to the
+				// per-route limit.
+				if !result.Allowed {
+					clientLimiter.Refund(clientIP)
+				}
+			}
+
+			if result.Allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctxLog := log.WithFields(log.Fields{
+				"request_id":  routes.RequestIDFromContext(r.Context()),
+				"route":       routeName,
+				"url_path":    r.URL.Path,
+				"http_method": r.Method,
+				"client_ip":   clientIP,
+				"limit":       limit,
+				"retry_after": result.RetryAfter,
+				"denied":      result.Denied,
+			})
+
+			if result.Denied == 1 {
+				ctxLog.Warn("rateLimitMiddleware: rate limit exceeded, rejecting requests")
+			} else {
+				ctxLog.Debug("rateLimitMiddleware: rate limit exceeded, request rejected")
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(result.RetryAfter)))
+			routes.WriteError(w, r, http.StatusTooManyRequests, fmt.Sprintf(
+				"Rate limit exceeded; please retry after %d seconds",
+				ratelimit.RetryAfterSeconds(result.RetryAfter),
+			))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/ratelimit"
+)
+
+func TestRateLimitMiddlewareCombinedLimits(t *testing.T) {
+
+	ipResolver, err := clientip.NewResolver(nil, clientip.HeaderXForwardedFor)
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+
+	// Limits are low enough that no tokens are refilled during the test.
+	clientLimiter := ratelimit.NewLimiter(1, 2)
+	routeLimiter := ratelimit.NewLimiter(1, 1)
+
+	handler := rateLimitMiddleware("echo", clientLimiter, routeLimiter, ipResolver)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	)
+
+	send := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/echo", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	// The first request uses the only route token, so the route limit
+	// rejects all further requests regardless of the client.
+	steps := []struct {
+		remoteAddr string
+		wantStatus int
+	}{
+		{remoteAddr: "192.0.2.1:50000", wantStatus: http.StatusNoContent},
+		{remoteAddr: "192.0.2.1:50000", wantStatus: http.StatusTooManyRequests},
+		{remoteAddr: "192.0.2.1:50000", wantStatus: http.StatusTooManyRequests},
+		{remoteAddr: "192.0.2.2:50000", wantStatus: http.StatusTooManyRequests},
+		{remoteAddr: "192.0.2.1:50000", wantStatus: http.StatusTooManyRequests},
+	}
+
+	for i, step := range steps {
+		if got := send(step.remoteAddr); got != step.wantStatus {
+			t.Errorf("request %d from %s: status = %d, want %d", i+1, step.remoteAddr, got, step.wantStatus)
+		}
+	}
+
+	// Requests rejected by the route limit are not charged to the client, so
+	// the second token of the first client and both tokens of the second
+	// client remain available.
+	for _, tc := range []struct {
+		client string
+		tokens int
+	}{
+		{client: "192.0.2.1", tokens: 1},
+		{client: "192.0.2.2", tokens: 2},
+	} {
+		for i := 0; i < tc.tokens; i++ {
+			if result := clientLimiter.Allow(tc.client); !result.Allowed {
+				t.Fatalf("client %s: token %d of %d not available after route limit rejections", tc.client, i+1, tc.tokens)
+			}
+		}
+		if result := clientLimiter.Allow(tc.client); result.Allowed {
+			t.Errorf("client %s: more than %d tokens available", tc.client, tc.tokens)
+		}
+	}
+}
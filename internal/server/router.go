@@ -0,0 +1,326 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/logfile"
+	"github.com/atc0005/bounce/internal/notify"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// Deps are the long-lived values used by route handlers. These are
+// shared by each router created for the application and are not replaced
+// when the configuration is reloaded, with the exception of IPResolver.
+type Deps struct {
+	// Templates are the response templates used by the echo handlers.
+	Templates *TemplateSet
+
+	// Output is where formatted request details are written.
+	Output io.Writer
+
+	// IPResolver determines the client IP Address for requests. A new
+	// Resolver is created from the trusted proxy settings when the
+	// configuration is reloaded.
+	IPResolver *clientip.Resolver
+
+	// History records captured client requests.
+	History *capture.Store
+
+	// NotifyWorkQueue receives captured client requests for notification.
+	NotifyWorkQueue chan<- capture.Request
+
+	// FaultOverrides are the fault injection settings applied at runtime.
+	FaultOverrides *Overrides[config.FaultInjection]
+
+	// ResponseOverrides are the response rules applied at runtime.
+	ResponseOverrides *Overrides[config.ResponseRule]
+
+	// NotifyStatus is the shared notification manager status.
+	NotifyStatus *notify.Status
+
+	// Files are the output files checked by the readiness endpoint.
+	Files []*logfile.File
+
+	// Expectations are the expectations evaluated against captured
+	// requests.
+	Expectations *ExpectationStore
+
+	// Feed broadcasts captured requests to history stream clients.
+	Feed *capture.Feed
+
+	// OnCapture, if set, is called with each captured client request.
+	OnCapture func(capture.Request)
+}
+
+// Router serves requests using the current handler (a ServeMux along with
+// the middleware applied to all requests), which is replaced when the
+// configuration is reloaded. Requests already in progress complete using the
+// previous handler.
+type Router struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+// ServeHTTP dispatches the request to the current handler.
+func (rr *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*rr.handler.Load()).ServeHTTP(w, r)
+}
+
+// Swap replaces the current handler.
+func (rr *Router) Swap(handler http.Handler) {
+	rr.handler.Store(&handler)
+}
+
+// NewRouter creates a ServeMux with the built-in routes, the routes defined
+// via the configuration file and the per-route middleware specified by the
+// configuration. Per-route state (e.g., rate limits) is specific to each
+// ServeMux.
+//
+// If the admin API is enabled and dedicated admin listeners are specified, a
+// separate ServeMux with the admin API routes is also returned. Otherwise the
+// admin API routes (if enabled) are registered with the first ServeMux and
+// the second return value is nil.
+func NewRouter(ctx context.Context, cfg *config.Config, deps Deps) (*http.ServeMux, *http.ServeMux, error) {
+
+	// SETUP ROUTES
+	// See handlers.go for handler definitions
+
+	var ourRoutes routes.Routes
+	ourRoutes.Add(routes.Route{
+		Name:           "index",
+		Description:    "Main page, fallback for unspecified routes",
+		Pattern:        "/",
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    handleIndex(deps.Templates, &ourRoutes),
+	})
+
+	ourRoutes.Add(routes.Route{
+		Name:           "echo",
+		Description:    "Prints received values as-is to stdout and returns them via HTTP response",
+		Pattern:        apiV1EchoEndpointPattern,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		HandlerFunc: echoHandler(
+			ctx,
+			deps.Templates,
+			echoOptions{
+				Name:              "echo",
+				Expectations:      deps.Expectations,
+				Feed:              deps.Feed,
+				OnCapture:         deps.OnCapture,
+				Pattern:           apiV1EchoEndpointPattern,
+				ResponseOverrides: deps.ResponseOverrides,
+				Formatter:         config.FormatterRaw,
+				EnabledNotifiers:  cfg.EnabledNotifiers(),
+				ResponseFormat:    cfg.ResponseFormat,
+				OutputFormat:      cfg.OutputFormat,
+				Output:            deps.Output,
+				ColoredJSON:       cfg.ColorizedJSON,
+				ColoredJSONIndent: cfg.ColorizedJSONIndent,
+			},
+			deps.IPResolver,
+			deps.History,
+			deps.NotifyWorkQueue,
+		),
+	})
+
+	ourRoutes.Add(routes.Route{
+		Name:           "echo-json",
+		Description:    "Prints formatted JSON response to stdout and via HTTP response",
+		Pattern:        apiV1EchoJSONEndpointPattern,
+		AllowedMethods: []string{http.MethodPost},
+		HandlerFunc: echoHandler(
+			ctx,
+			deps.Templates,
+			echoOptions{
+				Name:              "echo-json",
+				Expectations:      deps.Expectations,
+				Feed:              deps.Feed,
+				OnCapture:         deps.OnCapture,
+				Pattern:           apiV1EchoJSONEndpointPattern,
+				ResponseOverrides: deps.ResponseOverrides,
+				Formatter:         config.FormatterJSON,
+				EnabledNotifiers:  cfg.EnabledNotifiers(),
+				ResponseFormat:    cfg.ResponseFormat,
+				OutputFormat:      cfg.OutputFormat,
+				Output:            deps.Output,
+				ColoredJSON:       cfg.ColorizedJSON,
+				ColoredJSONIndent: cfg.ColorizedJSONIndent,
+			},
+			deps.IPResolver,
+			deps.History,
+			deps.NotifyWorkQueue,
+		),
+	})
+
+	ourRoutes.Add(routes.Route{
+		Name:           "echo-ws",
+		Description:    "Echoes WebSocket text and binary messages back to the client",
+		Pattern:        apiV1EchoWebSocketEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc: websocketEchoHandler(
+			ctx,
+			newWebSocketOptions(cfg),
+			deps.IPResolver,
+			deps.History,
+		),
+	})
+
+	sseOpts, err := loadSSEOptions(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load Server-Sent Events settings: %w", err)
+	}
+
+	ourRoutes.Add(routes.Route{
+		Name:           "events",
+		Description:    "Streams Server-Sent Events on a schedule",
+		Pattern:        apiV1EventsEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    sseHandler(ctx, sseOpts, deps.IPResolver),
+	})
+
+	// Routes defined via the configuration file are served by the same
+	// handler as our built-in echo routes.
+	addUserRoutes(ctx, &ourRoutes, cfg, deps)
+
+	if deps.History.Enabled() {
+		ourRoutes.Add(routes.Route{
+			Name:           "history",
+			Description:    "Lists recently captured client requests as JSON",
+			Pattern:        apiV1HistoryEndpointPattern,
+			AllowedMethods: []string{http.MethodGet},
+			HandlerFunc:    handleHistory(deps.History),
+		})
+
+		ourRoutes.Add(routes.Route{
+			Name:           "history-entry",
+			Description:    "Returns the captured client request with the specified request ID as JSON",
+			Pattern:        apiV1HistoryEntryEndpointPattern,
+			AllowedMethods: []string{http.MethodGet},
+			HandlerFunc:    handleHistoryEntry(deps.History),
+		})
+
+		ourRoutes.Add(routes.Route{
+			Name:           "history-export",
+			Description:    "Exports recently captured client requests as a HAR archive, curl or HTTPie commands or a .http file",
+			Pattern:        apiV1HistoryExportEndpointPattern,
+			AllowedMethods: []string{http.MethodGet},
+			HandlerFunc:    handleHistoryExport(deps.History),
+		})
+
+		ourRoutes.Add(routes.Route{
+			Name:           "history-entry-export",
+			Description:    "Exports the captured client request with the specified request ID",
+			Pattern:        apiV1HistoryEntryExportEndpointPattern,
+			AllowedMethods: []string{http.MethodGet},
+			HandlerFunc:    handleHistoryEntryExport(deps.History),
+		})
+
+		ourRoutes.Add(routes.Route{
+			Name:           "history-stream",
+			Description:    "Streams captured client requests as Server-Sent Events as they are received",
+			Pattern:        apiV1HistoryStreamEndpointPattern,
+			AllowedMethods: []string{http.MethodGet},
+			HandlerFunc:    handleHistoryStream(ctx, deps.History, deps.Feed),
+		})
+	}
+
+	// Expectations can only be registered via the admin API.
+	if cfg.AdminEnabled() {
+		ourRoutes.Add(routes.Route{
+			Name:           "expectations",
+			Description:    "Lists expected client requests as JSON",
+			Pattern:        apiV1ExpectationsEndpointPattern,
+			AllowedMethods: []string{http.MethodGet},
+			HandlerFunc:    handleExpectations(deps.Expectations),
+		})
+
+		ourRoutes.Add(routes.Route{
+			Name:           "expectation",
+			Description:    "Returns whether an expectation was met, with the closest mismatches",
+			Pattern:        apiV1ExpectationEndpointPattern,
+			AllowedMethods: []string{http.MethodGet},
+			HandlerFunc:    handleExpectation(deps.Expectations),
+		})
+	}
+
+	ourRoutes.Add(routes.Route{
+		Name:           "healthz",
+		Description:    "Reports whether the application is running (liveness)",
+		Pattern:        healthzEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    handleHealthz(),
+	})
+
+	ourRoutes.Add(routes.Route{
+		Name:           "readyz",
+		Description:    "Reports whether the application is ready to handle requests (readiness) as JSON",
+		Pattern:        readyzEndpointPattern,
+		AllowedMethods: []string{http.MethodGet},
+		HandlerFunc:    handleReadyz(cfg, deps.NotifyStatus, deps.Files),
+	})
+
+	faults := faultSettings{
+		configured: cfg.Faults,
+		overrides:  deps.FaultOverrides,
+	}
+	faultRoutes := echoRouteNames(cfg)
+
+	// Per-route middleware is applied in order from outermost to innermost.
+	// CORS is applied first so that preflight requests are answered before
+	// any other checks. Rate limits wrap access control so that floods of
+	// unauthenticated requests are throttled as well. Fault injection is
+	// innermost.
+	applyCORS(&ourRoutes, cfg.CORS)
+	applyRateLimits(&ourRoutes, cfg, deps.IPResolver)
+
+	if err := applyAccessControl(&ourRoutes, cfg.AccessControl, deps.IPResolver); err != nil {
+		return nil, nil, fmt.Errorf("failed to apply access control settings: %w", err)
+	}
+
+	applyFaults(ctx, &ourRoutes, faultRoutes, faults)
+
+	mux := http.NewServeMux()
+	if err := ourRoutes.RegisterWithServeMux(mux); err != nil {
+		return nil, nil, fmt.Errorf("failed to register routes: %w", err)
+	}
+
+	if !cfg.AdminEnabled() {
+		return mux, nil, nil
+	}
+
+	// The admin API is served via the same ServeMux under the admin path
+	// prefix unless dedicated admin listeners are specified.
+	adminRoutes, err := newAdminRoutes(cfg, deps, faults, faultRoutes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adminMux := mux
+	if len(cfg.AdminListen) > 0 {
+		adminMux = http.NewServeMux()
+	}
+
+	if err := adminRoutes.RegisterWithServeMux(adminMux); err != nil {
+		return nil, nil, fmt.Errorf("failed to register admin routes: %w", err)
+	}
+
+	if adminMux == mux {
+		return mux, nil, nil
+	}
+
+	return mux, adminMux, nil
+
+}
@@ -0,0 +1,398 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	textTemplate "text/template"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
+	"github.com/atc0005/bounce/internal/sse"
+	"github.com/atc0005/bounce/internal/tmplfuncs"
+)
+
+// apiV1EventsEndpointPattern is the endpoint used to stream Server-Sent
+// Events.
+const apiV1EventsEndpointPattern string = "/api/v1/events"
+
+// Query parameters used to override the Server-Sent Events settings for a
+// single request.
+const (
+	sseIntervalQueryParam        string = "interval"
+	sseEventQueryParam           string = "event"
+	sseRetryQueryParam           string = "retry"
+	sseDisconnectAfterQueryParam string = "disconnect_after"
+	sseDisconnectModeQueryParam  string = "disconnect_mode"
+)
+
+// sseLastEventIDHeader is the header used by clients to provide the ID of
+// the last event received when reconnecting.
+const sseLastEventIDHeader string = "Last-Event-ID"
+
+// sseEventData is the value the data template is executed against for each
+// generated event.
+type sseEventData struct {
+
+	// Time is the time the event was generated.
+	Time time.Time
+
+	// Request is the client request which opened the event stream.
+	Request capture.Request
+
+	// Event is the event type, if any.
+	Event string
+
+	// Sequence is the event number, starting at 1. The sequence is also
+	// used as the event ID so that streams resume where they left off.
+	Sequence int
+}
+
+// sseOptions controls how sseHandler streams events.
+type sseOptions struct {
+
+	// DataTemplate generates the data of each event. The template is not
+	// used if recorded events are provided.
+	DataTemplate *textTemplate.Template
+
+	// Event is the event type of generated events.
+	Event string
+
+	// DisconnectMode controls how clients are disconnected.
+	DisconnectMode string
+
+	// Recorded is the list of recorded events replayed in place of
+	// generated events, if any.
+	Recorded []sse.Event
+
+	// Interval is the amount of time between events.
+	Interval time.Duration
+
+	// Retry is the reconnection time in milliseconds requested from the
+	// client. A value of 0 omits the reconnection time.
+	Retry int
+
+	// DisconnectAfter is the number of events sent before the client is
+	// disconnected. A value of 0 disables disconnects.
+	DisconnectAfter int
+}
+
+// loadSSEOptions returns the Server-Sent Events settings specified by the
+// configuration, loading the data template or recorded events file.
+func loadSSEOptions(cfg *config.Config) (sseOptions, error) {
+
+	opts := sseOptions{
+		Event:           cfg.SSEEvent,
+		DisconnectMode:  cfg.SSEDisconnectMode,
+		Interval:        cfg.SSEEventInterval(),
+		Retry:           cfg.SSERetry,
+		DisconnectAfter: cfg.SSEDisconnectAfter,
+	}
+
+	if cfg.SSEEventsFile != "" {
+		f, err := os.Open(filepath.Clean(cfg.SSEEventsFile))
+		if err != nil {
+			return sseOptions{}, fmt.Errorf("failed to open events file: %w", err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Errorf("loadSSEOptions: failed to close events file: %v", err)
+			}
+		}()
+
+		events, err := sse.Parse(f)
+		if err != nil {
+			return sseOptions{}, fmt.Errorf("failed to parse events file %q: %w", cfg.SSEEventsFile, err)
+		}
+		if len(events) == 0 {
+			return sseOptions{}, fmt.Errorf("events file %q does not contain any events", cfg.SSEEventsFile)
+		}
+		opts.Recorded = events
+
+		return opts, nil
+	}
+
+	text, err := readTemplateFile(&templateFile{path: cfg.SSEDataTemplate}, sseDataTemplateText)
+	if err != nil {
+		return sseOptions{}, err
+	}
+
+	tmpl, err := textTemplate.New("sseHandler").
+		Funcs(textTemplate.FuncMap(tmplfuncs.FuncMap())).
+		Parse(text)
+	if err != nil {
+		return sseOptions{}, fmt.Errorf("failed to parse Server-Sent Events data template %q: %w", cfg.SSEDataTemplate, err)
+	}
+
+	sample := sseEventData{
+		Time:     sampleClientRequest.ReceivedAt,
+		Request:  sampleClientRequest,
+		Event:    cfg.SSEEvent,
+		Sequence: 1,
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return sseOptions{}, fmt.Errorf("failed to validate Server-Sent Events data template %q: %w", cfg.SSEDataTemplate, err)
+	}
+	opts.DataTemplate = tmpl
+
+	return opts, nil
+}
+
+// withOverrides returns a copy of the options with any overrides specified
+// via query parameters applied. The interval and retry values are given in
+// milliseconds, matching the configuration settings.
+func (opts sseOptions) withOverrides(query url.Values) (sseOptions, error) {
+
+	parse := func(name string, target *int) error {
+		value := query.Get(name)
+		if value == "" {
+			return nil
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s query parameter %q; a whole number is required", name, value)
+		}
+		*target = n
+
+		return nil
+	}
+
+	interval := int(opts.Interval / time.Millisecond)
+	if err := parse(sseIntervalQueryParam, &interval); err != nil {
+		return sseOptions{}, err
+	}
+	if interval < config.MinSSEInterval {
+		return sseOptions{}, fmt.Errorf(
+			"invalid %s query parameter %d; value must be at least %d",
+			sseIntervalQueryParam,
+			interval,
+			config.MinSSEInterval,
+		)
+	}
+	opts.Interval = time.Duration(interval) * time.Millisecond
+
+	if err := parse(sseRetryQueryParam, &opts.Retry); err != nil {
+		return sseOptions{}, err
+	}
+
+	if err := parse(sseDisconnectAfterQueryParam, &opts.DisconnectAfter); err != nil {
+		return sseOptions{}, err
+	}
+
+	if query.Has(sseEventQueryParam) {
+		opts.Event = query.Get(sseEventQueryParam)
+	}
+
+	if mode := query.Get(sseDisconnectModeQueryParam); mode != "" {
+		if !config.ValidSSEDisconnectMode(mode) {
+			return sseOptions{}, fmt.Errorf(
+				"invalid %s query parameter %q; supported modes: %s, %s",
+				sseDisconnectModeQueryParam,
+				mode,
+				config.SSEDisconnectModeClose,
+				config.SSEDisconnectModeReset,
+			)
+		}
+		opts.DisconnectMode = mode
+	}
+
+	return opts, nil
+}
+
+// eventSource returns a function providing the events to stream, resuming
+// after the event with the given ID (if any). The function returns false
+// once no events remain.
+func (opts sseOptions) eventSource(request capture.Request, lastEventID string) func() (sse.Event, bool, error) {
+
+	if opts.Recorded != nil {
+
+		// Recorded events are resumed after the last event with a matching
+		// ID. All events are replayed if no event matches.
+		next := 0
+		if lastEventID != "" {
+			for i, event := range opts.Recorded {
+				if event.ID == lastEventID {
+					next = i + 1
+				}
+			}
+		}
+
+		return func() (sse.Event, bool, error) {
+			if next >= len(opts.Recorded) {
+				return sse.Event{}, false, nil
+			}
+			event := opts.Recorded[next]
+			next++
+
+			return event, true, nil
+		}
+	}
+
+	// Generated events use the sequence number as the ID. Streams restart
+	// from the beginning if the ID is not a sequence number.
+	sequence, err := strconv.Atoi(lastEventID)
+	if err != nil || sequence < 0 {
+		sequence = 0
+	}
+
+	return func() (sse.Event, bool, error) {
+		sequence++
+
+		var data bytes.Buffer
+		err := opts.DataTemplate.Execute(&data, sseEventData{
+			Time:     time.Now(),
+			Request:  request,
+			Event:    opts.Event,
+			Sequence: sequence,
+		})
+		if err != nil {
+			return sse.Event{}, false, fmt.Errorf("failed to generate event data: %w", err)
+		}
+
+		return sse.Event{
+			ID:    strconv.Itoa(sequence),
+			Event: opts.Event,
+			Data:  data.String(),
+		}, true, nil
+	}
+}
+
+// sseHandler streams Server-Sent Events to the client on a schedule. Events
+// are generated using the data template or replayed from a file of recorded
+// events. Clients which reconnect using the Last-Event-ID header resume
+// after the last event received. Clients may be disconnected after a number
+// of events in order to test reconnection handling. Streams end when the
+// application is shutdown.
+func sseHandler(ctx context.Context, defaults sseOptions, ipResolver *clientip.Resolver) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log.Debug("sseHandler: endpoint hit")
+
+		opts, err := defaults.withOverrides(r.URL.Query())
+		if err != nil {
+			log.Debugf("sseHandler: %v", err)
+			routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		request := newClientRequestDetails(r, ipResolver)
+		lastEventID := r.Header.Get(sseLastEventIDHeader)
+
+		ctxLog := log.WithFields(log.Fields{
+			"request_id":    request.RequestID,
+			"client_ip":     request.ClientIPAddress,
+			"last_event_id": lastEventID,
+		})
+
+		nextEvent := opts.eventSource(request, lastEventID)
+		event, ok, err := nextEvent()
+		switch {
+		case err != nil:
+			ctxLog.Errorf("sseHandler: %v", err)
+			routes.WriteError(w, r, http.StatusInternalServerError, "")
+			return
+
+		// Clients do not reconnect after receiving a 204 response, so
+		// replays of recorded events end here once all events have been
+		// received.
+		case !ok:
+			ctxLog.Info("sseHandler: no events remain for client")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Event streams are long-lived, so the server write timeout does
+		// not apply.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			ctxLog.Debugf("sseHandler: failed to clear write deadline: %v", err)
+		}
+
+		w.Header().Set("Content-Type", sse.ContentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		ctxLog.WithFields(log.Fields{
+			"interval":         opts.Interval,
+			"disconnect_after": opts.DisconnectAfter,
+			"disconnect_mode":  opts.DisconnectMode,
+		}).Info("sseHandler: stream started")
+
+		if opts.Retry > 0 {
+			event.Retry = opts.Retry
+		}
+
+		timer := time.NewTimer(opts.Interval)
+		defer timer.Stop()
+
+		var sent int
+		for {
+			if _, err := w.Write(event.Encode()); err != nil {
+				ctxLog.Debugf("sseHandler: failed to write event: %v", err)
+				return
+			}
+			if !flushResponse(w, "sseHandler") {
+				return
+			}
+			sent++
+
+			ctxLog.WithFields(log.Fields{
+				"id":    event.ID,
+				"event": event.Event,
+			}).Debug("sseHandler: event sent")
+
+			if opts.DisconnectAfter > 0 && sent >= opts.DisconnectAfter {
+				ctxLog.WithFields(log.Fields{
+					"events_sent":     sent,
+					"disconnect_mode": opts.DisconnectMode,
+				}).Info("sseHandler: disconnecting client")
+
+				if opts.DisconnectMode == config.SSEDisconnectModeReset {
+					// The server closes the connection (or resets the
+					// HTTP/2 stream) without completing the response.
+					panic(http.ErrAbortHandler)
+				}
+				return
+			}
+
+			select {
+			case <-timer.C:
+				timer.Reset(opts.Interval)
+			case <-r.Context().Done():
+				ctxLog.WithField("events_sent", sent).Info("sseHandler: client disconnected")
+				return
+			case <-ctx.Done():
+				ctxLog.WithField("events_sent", sent).Info("sseHandler: stream ended due to shutdown")
+				return
+			}
+
+			event, ok, err = nextEvent()
+			switch {
+			case err != nil:
+				ctxLog.Errorf("sseHandler: %v", err)
+				return
+			case !ok:
+				ctxLog.WithField("events_sent", sent).Info("sseHandler: all recorded events sent")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	htmlTemplate "html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	textTemplate "text/template"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
+	"github.com/atc0005/bounce/internal/tmplfuncs"
+)
+
+// TemplateReloadInterval is how often template files are checked for changes
+// when automatic reloading is enabled.
+const TemplateReloadInterval time.Duration = 2 * time.Second
+
+// TemplateSet holds the parsed echo and index page templates. Templates are
+// swapped atomically when reloaded, so handlers should retrieve the current
+// template once per request.
+type TemplateSet struct {
+	echo  atomic.Pointer[textTemplate.Template]
+	index atomic.Pointer[htmlTemplate.Template]
+
+	// mu serializes reloads.
+	mu sync.Mutex
+
+	echoFile  templateFile
+	indexFile templateFile
+}
+
+// templateFile tracks a user-supplied template file in order to detect
+// changes.
+type templateFile struct {
+	modTime time.Time
+	path    string
+	size    int64
+}
+
+// changed indicates whether the template file has changed since it was last
+// loaded.
+func (tf templateFile) changed() (bool, error) {
+	fi, err := os.Stat(tf.path)
+	if err != nil {
+		return false, err
+	}
+
+	return !fi.ModTime().Equal(tf.modTime) || fi.Size() != tf.size, nil
+}
+
+// sampleClientRequest is used to validate the echo template by executing it
+// once before it is used.
+var sampleClientRequest = capture.Request{
+	RequestID:       "0123456789abcdef0123456789abcdef",
+	CaptureID:       "fedcba9876543210fedcba9876543210",
+	ReceivedAt:      time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+	RequestURL:      "https://localhost:8443/api/v1/echo/json",
+	Protocol:        "HTTP/2.0",
+	Datestamp:       "2020-01-01 00:00:00",
+	EndpointPath:    apiV1EchoJSONEndpointPattern,
+	HTTPMethod:      http.MethodPost,
+	ClientIPAddress: "127.0.0.1",
+	ClientIPSource:  "RemoteAddr",
+	Headers:         http.Header{"Content-Type": []string{"application/json"}},
+	Body:            `{"message": "template validation"}`,
+	FormattedBody:   "{\n\t\"message\": \"template validation\"\n}",
+	ProxyChain:      []string{"127.0.0.1"},
+	TLS: &capture.TLSDetails{
+		Version:     "TLS 1.3",
+		CipherSuite: "TLS_AES_128_GCM_SHA256",
+		ALPN:        "h2",
+		ServerName:  "localhost",
+	},
+}
+
+// sampleRoutes is used to validate the index page template by executing it
+// once before it is used.
+var sampleRoutes = routes.Routes{
+	{
+		Name:           "echo",
+		Description:    "Prints received values as-is to stdout and returns them via HTTP response",
+		Pattern:        apiV1EchoEndpointPattern,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+	},
+}
+
+// LoadTemplates parses and validates the built-in templates or the template
+// files specified in the configuration.
+func LoadTemplates(cfg *config.Config) (*TemplateSet, error) {
+
+	ts := TemplateSet{
+		echoFile:  templateFile{path: cfg.EchoTemplate},
+		indexFile: templateFile{path: cfg.IndexTemplate},
+	}
+
+	if err := ts.loadEcho(); err != nil {
+		return nil, err
+	}
+
+	if err := ts.loadIndex(); err != nil {
+		return nil, err
+	}
+
+	return &ts, nil
+}
+
+// Echo returns the current echo template.
+func (ts *TemplateSet) Echo() *textTemplate.Template {
+	return ts.echo.Load()
+}
+
+// Index returns the current index page template.
+func (ts *TemplateSet) Index() *htmlTemplate.Template {
+	return ts.index.Load()
+}
+
+// readTemplateFile reads the given template file, returning the built-in
+// template text if no file is specified.
+func readTemplateFile(tf *templateFile, builtin string) (string, error) {
+
+	if tf.path == "" {
+		return builtin, nil
+	}
+
+	fi, err := os.Stat(tf.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	text, err := os.ReadFile(filepath.Clean(tf.path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	tf.modTime = fi.ModTime()
+	tf.size = fi.Size()
+
+	return string(text), nil
+}
+
+// loadEcho parses and validates the echo template. The current template is
+// left in place if the new template is invalid.
+func (ts *TemplateSet) loadEcho() error {
+
+	tf := ts.echoFile
+	text, err := readTemplateFile(&tf, handleEchoTemplateText)
+	if err != nil {
+		return err
+	}
+
+	// Record the file details even if the template turns out to be invalid
+	// so that it is not reloaded again until it changes.
+	ts.echoFile = tf
+
+	tmpl, err := textTemplate.New("echoHandler").
+		Funcs(textTemplate.FuncMap(tmplfuncs.FuncMap())).
+		Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse echo template %q: %w", tf.path, err)
+	}
+
+	if err := tmpl.Execute(io.Discard, sampleClientRequest); err != nil {
+		return fmt.Errorf("failed to validate echo template %q: %w", tf.path, err)
+	}
+
+	ts.echo.Store(tmpl)
+
+	return nil
+}
+
+// loadIndex parses and validates the index page template. The current
+// template is left in place if the new template is invalid.
+func (ts *TemplateSet) loadIndex() error {
+
+	tf := ts.indexFile
+	text, err := readTemplateFile(&tf, handleIndexTemplateText)
+	if err != nil {
+		return err
+	}
+
+	// Record the file details even if the template turns out to be invalid
+	// so that it is not reloaded again until it changes.
+	ts.indexFile = tf
+
+	tmpl, err := htmlTemplate.New("indexPage").
+		Funcs(htmlTemplate.FuncMap(tmplfuncs.FuncMap())).
+		Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse index template %q: %w", tf.path, err)
+	}
+
+	if err := tmpl.Execute(io.Discard, sampleRoutes); err != nil {
+		return fmt.Errorf("failed to validate index template %q: %w", tf.path, err)
+	}
+
+	ts.index.Store(tmpl)
+
+	return nil
+}
+
+// Replace replaces the templates with those from the given TemplateSet
+// (e.g., after the configuration is reloaded).
+func (ts *TemplateSet) Replace(other *TemplateSet) {
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.echoFile = other.echoFile
+	ts.indexFile = other.indexFile
+	ts.echo.Store(other.Echo())
+	ts.index.Store(other.Index())
+}
+
+// reloadChanged reloads any template files which have changed since they
+// were last loaded. Templates which fail to load are left in place.
+func (ts *TemplateSet) reloadChanged() {
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	reload := func(name string, tf templateFile, load func() error) {
+		if tf.path == "" {
+			return
+		}
+
+		changed, err := tf.changed()
+		switch {
+		case err != nil:
+			log.Errorf("reloadChanged: unable to check %s template file %q: %v", name, tf.path, err)
+			return
+		case !changed:
+			return
+		}
+
+		if err := load(); err != nil {
+			log.Errorf("reloadChanged: keeping current %s template: %v", name, err)
+			return
+		}
+
+		log.Infof("reloadChanged: reloaded %s template from %q", name, tf.path)
+	}
+
+	reload("echo", ts.echoFile, ts.loadEcho)
+	reload("index", ts.indexFile, ts.loadIndex)
+}
+
+// TemplateReloader checks template files for changes at a regular interval
+// and reloads them until the context is cancelled.
+func TemplateReloader(ctx context.Context, ts *TemplateSet, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debug("TemplateReloader: context cancelled, stopping")
+			return
+
+		case <-ticker.C:
+			ts.reloadChanged()
+		}
+	}
+}
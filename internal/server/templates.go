@@ -5,7 +5,7 @@
 // Licensed under the MIT License. See LICENSE file in the project root for
 // full license information.
 
-package main
+package server
 
 const handleIndexTemplateText string = `
 <!doctype html>
@@ -87,6 +87,7 @@ const handleIndexTemplateText string = `
     <th>Pattern</th>
     <th>Description</th>
     <th>Allowed Methods</th>
+    <th>Access</th>
   </tr>
 {{range .}}
   <tr>
@@ -94,6 +95,7 @@ const handleIndexTemplateText string = `
     <td><a href="{{ .Pattern }}"><code>{{ .Pattern }}</code></a></td>
 	<td><code>{{ .Description }}</td>
 	<td>{{range .AllowedMethods}}<code>{{ . }}</code> {{end}}</td>
+	<td>{{if .Protected}}Protected: {{range .AccessControls}}<code>{{ . }}</code> {{end}}{{else}}Public{{end}}</td>
   </tr>
 {{else}}
 <tr>
@@ -101,6 +103,7 @@ const handleIndexTemplateText string = `
   <td><code>N/A</code></td>
   <td><code>N/A</code></td>
   <td><code>N/A</code></td>
+  <td><code>N/A</code></td>
 </tr>
 {{end}}
 </table>
@@ -121,9 +124,20 @@ const handleIndexTemplateText string = `
 
 const handleEchoTemplateText string = `
 Request received: {{if .Datestamp }}{{ .Datestamp }}{{end}}
+Request ID: {{if .RequestID }}{{ .RequestID }}{{end}}
 Endpoint path requested by client: {{if .EndpointPath }}{{ .EndpointPath }}{{end}}
+{{- if .PathParams }}
+Path parameters: {{ range $key, $value := .PathParams }}
+  * {{ $key }}: {{ $value }}{{end}}
+{{- end}}
 HTTP Method used by client: {{if .HTTPMethod }}{{ .HTTPMethod }}{{end}}
-Client IP Address: {{if .ClientIPAddress }}{{ .ClientIPAddress }}{{end}}
+Protocol: {{if .Protocol }}{{ .Protocol }}{{end}} ({{if .ConnectionReused }}reused{{else}}new{{end}} connection)
+TLS: {{with .TLS }}{{ .Version }}, {{ .CipherSuite }}{{if .ALPN }}, ALPN {{ .ALPN }}{{end}}{{if .ServerName }}, SNI {{ .ServerName }}{{end}}{{if .Resumed }}, resumed session{{end}}{{else}}None{{end}}
+{{- with .Fault }}
+Injected fault: {{if .Type }}{{ .Type }}{{else}}none{{end}}{{if .Status }} (status {{ .Status }}){{end}}{{if .LatencyMs }}, {{ .LatencyMs }}ms latency{{end}}
+{{- end}}
+Client IP Address: {{if .ClientIPAddress }}{{ .ClientIPAddress }}{{end}}{{if .ClientIPSource }} (via {{ .ClientIPSource }}){{end}}
+Proxy chain: {{range $index, $hop := .ProxyChain }}{{if $index}} -> {{end}}{{ $hop }}{{else}}None{{end}}
 
 Headers:
 
@@ -167,3 +181,7 @@ Error formatting request body:
 {{- end}}
 
 `
+
+// sseDataTemplateText is the built-in template used to generate the data of
+// each Server-Sent Event.
+const sseDataTemplateText string = `{"sequence": {{ .Sequence }}, "time": {{ .Time | toJSON }}, "request_id": {{ .Request.RequestID | toJSON }}}`
@@ -0,0 +1,97 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
+)
+
+// Defaults applied to user-defined routes if not specified via the
+// configuration file.
+var (
+	defaultUserRouteMethods   = []string{http.MethodGet, http.MethodPost}
+	defaultUserRouteFormatter = config.FormatterRaw
+)
+
+// userRouteDescription is used for user-defined routes without a
+// description of their own.
+const userRouteDescription string = "User-defined echo route"
+
+// addUserRoutes adds a route for each user-defined route specified via the
+// configuration file. These routes are served by echoHandler using the
+// options specified for each route.
+func addUserRoutes(
+	ctx context.Context,
+	rs *routes.Routes,
+	cfg *config.Config,
+	deps Deps,
+) {
+
+	for _, routeCfg := range cfg.Routes {
+
+		methods := routeCfg.Methods
+		if len(methods) == 0 {
+			methods = defaultUserRouteMethods
+		}
+
+		opts := echoOptions{
+			Name:              routeCfg.Name,
+			Pattern:           routeCfg.Pattern,
+			Formatter:         routeCfg.Formatter,
+			Response:          routeCfg.Response,
+			ResponseOverrides: deps.ResponseOverrides,
+			Expectations:      deps.Expectations,
+			Feed:              deps.Feed,
+			OnCapture:         deps.OnCapture,
+			EnabledNotifiers:  cfg.EnabledNotifiers(),
+			ResponseFormat:    cfg.ResponseFormat,
+			OutputFormat:      cfg.OutputFormat,
+			Output:            deps.Output,
+			ColoredJSON:       cfg.ColorizedJSON,
+			ColoredJSONIndent: cfg.ColorizedJSONIndent,
+		}
+
+		if opts.Formatter == "" {
+			opts.Formatter = defaultUserRouteFormatter
+		}
+
+		// A nil list indicates that all enabled notifiers are used; an
+		// explicitly empty list disables notifications for the route.
+		if routeCfg.Notifiers != nil {
+			opts.Notifiers = make([]string, 0, len(*routeCfg.Notifiers))
+			opts.Notifiers = append(opts.Notifiers, *routeCfg.Notifiers...)
+		}
+
+		description := routeCfg.Description
+		if description == "" {
+			description = userRouteDescription
+		}
+
+		log.Debugf("addUserRoutes: adding route %s (%s)", routeCfg.Name, routeCfg.Pattern)
+
+		rs.Add(routes.Route{
+			Name:           routeCfg.Name,
+			Description:    description,
+			Pattern:        routeCfg.Pattern,
+			AllowedMethods: methods,
+			HandlerFunc: echoHandler(
+				ctx,
+				deps.Templates,
+				opts,
+				deps.IPResolver,
+				deps.History,
+				deps.NotifyWorkQueue,
+			),
+		})
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/atc0005/bounce/capture"
+	"github.com/atc0005/bounce/internal/clientip"
+	"github.com/atc0005/bounce/internal/config"
+	"github.com/atc0005/bounce/internal/routes"
+	"github.com/atc0005/bounce/internal/tmplfuncs"
+	"github.com/atc0005/bounce/internal/websocket"
+)
+
+// apiV1EchoWebSocketEndpointPattern is the endpoint used to echo WebSocket
+// messages.
+const apiV1EchoWebSocketEndpointPattern string = "/api/v1/echo/ws"
+
+// Query parameters used to override the WebSocket echo settings for a
+// single connection.
+const (
+	websocketDelayQueryParam        string = "delay"
+	websocketCloseAfterQueryParam   string = "close_after"
+	websocketPingIntervalQueryParam string = "ping_interval"
+)
+
+// websocketCloseTimeout is the amount of time to wait for the client to
+// reply to a close frame sent by the server.
+const websocketCloseTimeout time.Duration = 5 * time.Second
+
+// websocketLogDataLength is the maximum number of characters of each text
+// message included in log messages.
+const websocketLogDataLength int = 200
+
+// websocketOptions controls how websocketEchoHandler handles connections.
+type websocketOptions struct {
+
+	// Delay is the amount of time to wait before echoing each message.
+	Delay time.Duration
+
+	// PingInterval is the amount of time between ping frames sent to the
+	// client. A value of 0 disables pings.
+	PingInterval time.Duration
+
+	// MaxMessageSize is the maximum size in bytes of a message received
+	// from the client.
+	MaxMessageSize int64
+
+	// CloseAfter is the number of messages echoed before the connection is
+	// closed. A value of 0 disables the limit.
+	CloseAfter int
+}
+
+// settings returns the options using the same units as the configuration
+// settings and query parameters.
+func (opts websocketOptions) settings() capture.WebSocketSettings {
+	return capture.WebSocketSettings{
+		DelayMilliseconds:   opts.Delay.Milliseconds(),
+		CloseAfter:          opts.CloseAfter,
+		PingIntervalSeconds: int64(opts.PingInterval / time.Second),
+		MaxMessageSize:      opts.MaxMessageSize,
+	}
+}
+
+// newWebSocketOptions returns the WebSocket echo settings specified by the
+// configuration.
+func newWebSocketOptions(cfg *config.Config) websocketOptions {
+	return websocketOptions{
+		Delay:          cfg.WebSocketEchoDelay(),
+		PingInterval:   cfg.WebSocketPingPeriod(),
+		MaxMessageSize: int64(cfg.WebSocketMaxMessageSize),
+		CloseAfter:     cfg.WebSocketCloseAfter,
+	}
+}
+
+// withOverrides returns a copy of the options with any overrides specified
+// via query parameters applied. The delay is given in milliseconds and the
+// ping interval in seconds, matching the configuration settings.
+func (opts websocketOptions) withOverrides(query url.Values) (websocketOptions, error) {
+
+	parse := func(name string) (int, bool, error) {
+		value := query.Get(name)
+		if value == "" {
+			return 0, false, nil
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, false, fmt.Errorf("invalid %s query parameter %q; a whole number is required", name, value)
+		}
+
+		return n, true, nil
+	}
+
+	if n, ok, err := parse(websocketDelayQueryParam); err != nil {
+		return websocketOptions{}, err
+	} else if ok {
+		opts.Delay = time.Duration(n) * time.Millisecond
+	}
+
+	if n, ok, err := parse(websocketCloseAfterQueryParam); err != nil {
+		return websocketOptions{}, err
+	} else if ok {
+		opts.CloseAfter = n
+	}
+
+	if n, ok, err := parse(websocketPingIntervalQueryParam); err != nil {
+		return websocketOptions{}, err
+	} else if ok {
+		opts.PingInterval = time.Duration(n) * time.Second
+	}
+
+	return opts, nil
+}
+
+// websocketRecorder records the progress of a WebSocket connection in the
+// request history. Updates may be made concurrently.
+type websocketRecorder struct {
+	conn       *websocket.Conn
+	reqHistory *capture.Store
+	captureID  string
+	session    capture.WebSocketSession
+	mu         sync.Mutex
+}
+
+// update applies the given changes to the session along with the current
+// frame counts and records the result in the request history.
+func (wr *websocketRecorder) update(apply func(s *capture.WebSocketSession)) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	apply(&wr.session)
+	wr.session.FramesReceived = wr.conn.FramesReceived()
+	wr.session.FramesSent = wr.conn.FramesSent()
+
+	wr.reqHistory.SetWebSocketSession(wr.captureID, wr.session)
+}
+
+// websocketEchoHandler upgrades requests to WebSocket connections and echoes
+// each text and binary message back to the client using the same message
+// type. Each message is logged and the connection is recorded as a session
+// in the request history. Connections are closed when the application is
+// shutdown.
+func websocketEchoHandler(
+	ctx context.Context,
+	defaults websocketOptions,
+	ipResolver *clientip.Resolver,
+	reqHistory *capture.Store,
+) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log.Debug("websocketEchoHandler: endpoint hit")
+
+		opts, err := defaults.withOverrides(r.URL.Query())
+		if err != nil {
+			log.Debugf("websocketEchoHandler: %v", err)
+			routes.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		handshake := newClientRequestDetails(r, ipResolver)
+
+		ctxLog := log.WithFields(log.Fields{
+			"request_id": handshake.RequestID,
+			"client_ip":  handshake.ClientIPAddress,
+		})
+
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			var handshakeErr *websocket.HandshakeError
+			if errors.As(err, &handshakeErr) {
+				ctxLog.Debugf("websocketEchoHandler: rejecting request: %v", err)
+				routes.WriteError(w, r, handshakeErr.Status, handshakeErr.Message)
+				return
+			}
+			ctxLog.Errorf("websocketEchoHandler: %v", err)
+			return
+		}
+		defer func() {
+			if err := conn.Close(); err != nil {
+				ctxLog.Debugf("websocketEchoHandler: failed to close connection: %v", err)
+			}
+		}()
+
+		conn.SetReadLimit(opts.MaxMessageSize)
+
+		reqHistory.Add(handshake, nil)
+
+		recorder := &websocketRecorder{
+			conn:       conn,
+			reqHistory: reqHistory,
+			captureID:  handshake.CaptureID,
+			session: capture.WebSocketSession{
+				ConnectedAt: time.Now(),
+				Options:     opts.settings(),
+			},
+		}
+		recorder.update(func(*capture.WebSocketSession) {})
+
+		ctxLog.WithFields(log.Fields{
+			"delay":         opts.Delay,
+			"close_after":   opts.CloseAfter,
+			"ping_interval": opts.PingInterval,
+		}).Info("websocketEchoHandler: session started")
+
+		conn.SetPongHandler(func(appData []byte) {
+			ctxLog.WithField("bytes", len(appData)).Debug("websocketEchoHandler: pong received")
+			recorder.update(func(s *capture.WebSocketSession) { s.PongsReceived++ })
+		})
+
+		// closeSession sends a close frame and limits how long we wait for
+		// the client to reply.
+		closeSession := func(code int, reason string) {
+			if err := conn.WriteClose(code, reason); err != nil {
+				return
+			}
+			ctxLog.WithFields(log.Fields{
+				"code":   code,
+				"reason": reason,
+			}).Info("websocketEchoHandler: closing session")
+			if err := conn.SetReadDeadline(time.Now().Add(websocketCloseTimeout)); err != nil {
+				ctxLog.Debugf("websocketEchoHandler: failed to set read deadline: %v", err)
+			}
+		}
+
+		// Pings are sent and application shutdown is handled separately
+		// from reading messages.
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			var pings <-chan time.Time
+			if opts.PingInterval > 0 {
+				ticker := time.NewTicker(opts.PingInterval)
+				defer ticker.Stop()
+				pings = ticker.C
+			}
+
+			for {
+				select {
+				case <-done:
+					return
+
+				case <-ctx.Done():
+					closeSession(websocket.CloseGoingAway, "server shutting down")
+					return
+
+				case <-pings:
+					if err := conn.Ping(nil); err != nil {
+						return
+					}
+					ctxLog.Debug("websocketEchoHandler: ping sent")
+					recorder.update(func(s *capture.WebSocketSession) { s.PingsSent++ })
+				}
+			}
+		}()
+
+		var echoed int
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				recordClose(recorder, err)
+				break
+			}
+
+			msgLog := ctxLog.WithFields(log.Fields{
+				"type":  msgType.String(),
+				"bytes": len(data),
+			})
+			if msgType == websocket.TextMessage {
+				msgLog = msgLog.WithField("data", tmplfuncs.Truncate(websocketLogDataLength, string(data)))
+			}
+			msgLog.Info("websocketEchoHandler: message received")
+
+			recorder.update(func(s *capture.WebSocketSession) {
+				s.BytesReceived += int64(len(data))
+				switch msgType {
+				case websocket.TextMessage:
+					s.TextMessagesReceived++
+				case websocket.BinaryMessage:
+					s.BinaryMessagesReceived++
+				}
+			})
+
+			if opts.Delay > 0 {
+				select {
+				case <-time.After(opts.Delay):
+				case <-ctx.Done():
+				}
+			}
+
+			// Messages received after a close frame was sent are read (in
+			// order to receive the client's reply), but not echoed.
+			switch err := conn.WriteMessage(msgType, data); {
+			case errors.Is(err, websocket.ErrCloseSent):
+				continue
+			case err != nil:
+				ctxLog.Errorf("websocketEchoHandler: failed to echo message: %v", err)
+				recorder.update(func(s *capture.WebSocketSession) { s.Error = err.Error() })
+				return
+			}
+
+			echoed++
+			recorder.update(func(s *capture.WebSocketSession) {
+				s.MessagesSent++
+				s.BytesSent += int64(len(data))
+			})
+
+			if opts.CloseAfter > 0 && echoed >= opts.CloseAfter {
+				closeSession(websocket.CloseNormalClosure, "message limit reached")
+			}
+		}
+
+		recorder.update(func(s *capture.WebSocketSession) {
+			closedAt := time.Now()
+			s.ClosedAt = &closedAt
+		})
+
+		ctxLog.WithFields(log.Fields{
+			"messages_echoed": echoed,
+			"frames_received": conn.FramesReceived(),
+			"frames_sent":     conn.FramesSent(),
+		}).Info("websocketEchoHandler: session ended")
+	}
+}
+
+// recordClose records the reason the connection ended.
+func recordClose(recorder *websocketRecorder, err error) {
+	var closeErr *websocket.CloseError
+
+	recorder.update(func(s *capture.WebSocketSession) {
+		switch {
+		case errors.As(err, &closeErr):
+			s.CloseCode = closeErr.Code
+			s.CloseReason = closeErr.Reason
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			s.Error = "connection closed without a close frame"
+		default:
+			s.Error = err.Error()
+		}
+	})
+}
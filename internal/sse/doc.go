@@ -0,0 +1,16 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package sse provides types and functions used to encode and parse
+Server-Sent Events using the text/event-stream format described by the HTML
+Living Standard.
+
+Recorded event streams (e.g., captured using curl) may be parsed using Parse
+so that the events can be replayed to clients.
+*/
+package sse
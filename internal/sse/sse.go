@@ -0,0 +1,217 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type used for event streams.
+const ContentType string = "text/event-stream"
+
+// Event is a single Server-Sent Event.
+type Event struct {
+
+	// ID is the event ID. Clients provide the ID of the last event received
+	// via the Last-Event-ID header when reconnecting. The ID is omitted if
+	// empty.
+	ID string `json:"id,omitempty"`
+
+	// Event is the event type. Clients treat events without a type as
+	// message events. The type is omitted if empty.
+	Event string `json:"event,omitempty"`
+
+	// Data is the event payload. Multi-line payloads are sent using one data
+	// field per line.
+	Data string `json:"data"`
+
+	// Retry is the reconnection time in milliseconds requested from the
+	// client. The reconnection time is omitted if 0.
+	Retry int `json:"retry,omitempty"`
+}
+
+// Encode returns the event in the text/event-stream format, including the
+// trailing blank line which dispatches the event. Line breaks within the ID
+// and event type are replaced with spaces as they cannot be represented.
+func (e Event) Encode() []byte {
+
+	var buf bytes.Buffer
+
+	singleLine := strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+	if e.ID != "" {
+		buf.WriteString("id: " + singleLine.Replace(e.ID) + "\n")
+	}
+
+	if e.Event != "" {
+		buf.WriteString("event: " + singleLine.Replace(e.Event) + "\n")
+	}
+
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.Itoa(e.Retry) + "\n")
+	}
+
+	data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(e.Data)
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+
+	buf.WriteString("\n")
+
+	return buf.Bytes()
+}
+
+// Parse reads events in the text/event-stream format. Events are separated
+// by blank lines. Comments and unknown fields are ignored. Unlike clients,
+// blocks without data fields are retained (e.g., to replay an event which
+// only sets the reconnection time) as long as at least one field is set.
+func Parse(r io.Reader) ([]Event, error) {
+
+	var events []Event
+
+	er := NewReader(r)
+	for {
+		event, err := er.Next()
+		switch {
+		case errors.Is(err, io.EOF):
+			return events, nil
+		case err != nil:
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+}
+
+// Reader reads events in the text/event-stream format one at a time (e.g.,
+// from a response body as events are received). Events are read using the
+// same rules as Parse.
+type Reader struct {
+	scanner *bufio.Scanner
+	lineNum int
+}
+
+// NewReader creates a new Reader which reads events from r.
+func NewReader(r io.Reader) *Reader {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	scanner.Split(scanLines)
+
+	return &Reader{scanner: scanner}
+}
+
+// Next blocks until the next event is read. io.EOF is returned once the
+// stream ends without further events.
+func (er *Reader) Next() (Event, error) {
+
+	var current Event
+	var dataLines []string
+	var hasData bool
+
+	// dispatch returns the current event, indicating whether any field was
+	// set.
+	dispatch := func() (Event, bool) {
+		if hasData {
+			current.Data = strings.Join(dataLines, "\n")
+		}
+
+		return current, hasData || current != (Event{})
+	}
+
+	for er.scanner.Scan() {
+		er.lineNum++
+		line := er.scanner.Text()
+
+		if er.lineNum == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+
+		switch {
+		case line == "":
+			if event, ok := dispatch(); ok {
+				return event, nil
+			}
+			continue
+		case strings.HasPrefix(line, ":"):
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+
+		switch field {
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				current.ID = value
+			}
+		case "event":
+			current.Event = value
+		case "data":
+			dataLines = append(dataLines, value)
+			hasData = true
+		case "retry":
+			retry, err := strconv.Atoi(value)
+			if err != nil || retry < 0 {
+				return Event{}, fmt.Errorf("invalid retry value %q on line %d", value, er.lineNum)
+			}
+			current.Retry = retry
+		}
+	}
+
+	if err := er.scanner.Err(); err != nil {
+		return Event{}, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	// A final event without a trailing blank line is not dispatched by
+	// clients, but is retained here as recorded files often omit it.
+	if event, ok := dispatch(); ok {
+		return event, nil
+	}
+
+	return Event{}, io.EOF
+}
+
+// scanLines is a bufio.SplitFunc which splits on any of the line endings
+// permitted by the text/event-stream format: CRLF, LF or CR.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		// A CR at the end of the buffer may be followed by an LF.
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package tmplfuncs provides helper functions for use with user-supplied
+text/template and html/template templates.
+
+Functions accept the value they operate on as the final argument so that
+they may be used in pipelines:
+
+	{{ .Body | jsonpath "$.alert.name" }}
+	{{ .Body | truncate 80 }}
+	{{ .Headers | redact "Authorization,Cookie" | toJSON }}
+	{{ .Headers | header "User-Agent" }}
+
+Functions are forgiving of unexpected input (e.g., a request body which is not
+valid JSON) so that a single malformed request does not prevent a template
+from rendering.
+*/
+package tmplfuncs
@@ -0,0 +1,299 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package tmplfuncs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redacted is the value used in place of redacted values.
+const Redacted string = "[REDACTED]"
+
+// truncateSuffix is appended to values shortened by truncate.
+const truncateSuffix string = "..."
+
+// FuncMap provides the helper functions provided by this package, keyed by
+// the name used to call them from a template. The result may be converted
+// to a text/template or html/template FuncMap.
+func FuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"jsonpath": JSONPath,
+		"truncate": Truncate,
+		"redact":   Redact,
+		"toJSON":   ToJSON,
+		"header":   Header,
+	}
+}
+
+// JSONPath returns the value found at the given path within a JSON document.
+// The document may be provided as a string or byte slice of JSON or as an
+// already decoded value (e.g., the result of another JSONPath call). Paths
+// use dot notation for object keys and brackets for array indexes, with an
+// optional leading $ (e.g., $.alerts[0].labels.severity). Keys containing
+// dots may be given in brackets as quoted strings (e.g., $["a.b"]).
+//
+// nil is returned if the document is not valid JSON or the path does not
+// exist. An error is returned only for an invalid path.
+func JSONPath(path string, document interface{}) (interface{}, error) {
+
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := decodeJSON(document)
+	if !ok {
+		return nil, nil
+	}
+
+	for _, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if segment.isIndex {
+				return nil, nil
+			}
+			if value, ok = v[segment.key]; !ok {
+				return nil, nil
+			}
+
+		case []interface{}:
+			if !segment.isIndex || segment.index < 0 || segment.index >= len(v) {
+				return nil, nil
+			}
+			value = v[segment.index]
+
+		default:
+			return nil, nil
+		}
+	}
+
+	return value, nil
+}
+
+// pathSegment is a single object key or array index within a JSONPath path.
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath splits the given path into object key and array index segments.
+func parsePath(path string) ([]pathSegment, error) {
+
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid JSON path %q: %s", path, reason)
+	}
+
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+
+	var segments []pathSegment
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, invalid("empty key")
+			}
+			segments = append(segments, pathSegment{key: rest[:end]})
+			rest = rest[end:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, invalid("missing closing bracket")
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+
+			if strings.HasPrefix(inner, `"`) || strings.HasPrefix(inner, `'`) {
+				if len(inner) < 2 || inner[len(inner)-1] != inner[0] {
+					return nil, invalid("unterminated quoted key")
+				}
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+				continue
+			}
+
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, invalid(fmt.Sprintf("array index %q is not a number", inner))
+			}
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+
+		default:
+			// Permit a leading key without the dot (e.g., "alerts[0]").
+			if len(segments) > 0 {
+				return nil, invalid(fmt.Sprintf("unexpected character %q", rest[0]))
+			}
+			rest = "." + rest
+		}
+	}
+
+	return segments, nil
+}
+
+// decodeJSON decodes the given value if provided as a string or byte slice
+// of JSON. Other values are returned as-is.
+func decodeJSON(document interface{}) (interface{}, bool) {
+
+	var data []byte
+	switch v := document.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return document, true
+	}
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+// Truncate shortens the given value to at most length characters, appending
+// "..." if the value was shortened. Values which are not strings are
+// formatted using their default format first.
+func Truncate(length int, value interface{}) string {
+
+	s, ok := value.(string)
+	if !ok {
+		s = fmt.Sprint(value)
+	}
+
+	if length <= 0 {
+		return ""
+	}
+
+	if utf8.RuneCountInString(s) <= length {
+		return s
+	}
+
+	runes := []rune(s)
+
+	return string(runes[:length]) + truncateSuffix
+}
+
+// Redact replaces the values of the named headers or JSON object keys with
+// Redacted. Names are given as a comma-separated list and are matched
+// case-insensitively. The value may be an http.Header, a decoded JSON value
+// or a string of JSON, in which case a string of JSON is returned. JSON
+// objects are redacted at any depth. Other values are returned unmodified.
+func Redact(names string, value interface{}) interface{} {
+
+	redactNames := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			redactNames[strings.ToLower(name)] = true
+		}
+	}
+
+	switch v := value.(type) {
+	case http.Header:
+		redacted := make(http.Header, len(v))
+		for name, values := range v {
+			if !redactNames[strings.ToLower(name)] {
+				redacted[name] = values
+				continue
+			}
+			redacted[name] = make([]string, len(values))
+			for i := range values {
+				redacted[name][i] = Redacted
+			}
+		}
+		return redacted
+
+	case string, []byte:
+		decoded, ok := decodeJSON(v)
+		if !ok {
+			return value
+		}
+		encoded, err := ToJSON(redactJSON(decoded, redactNames))
+		if err != nil {
+			return value
+		}
+		return encoded
+
+	default:
+		return redactJSON(value, redactNames)
+	}
+}
+
+// redactJSON returns a copy of the given decoded JSON value with the values
+// of matching object keys replaced.
+func redactJSON(value interface{}, names map[string]bool) interface{} {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if names[strings.ToLower(key)] {
+				redacted[key] = Redacted
+				continue
+			}
+			redacted[key] = redactJSON(item, names)
+		}
+		return redacted
+
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactJSON(item, names)
+		}
+		return redacted
+
+	default:
+		return value
+	}
+}
+
+// ToJSON encodes the given value as compact JSON. HTML characters are not
+// escaped.
+func ToJSON(value interface{}) (string, error) {
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return "", fmt.Errorf("failed to encode value as JSON: %w", err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// Header returns the values of the named header as a comma-separated list.
+// The name is matched case-insensitively. An empty string is returned if the
+// header is not present or the value is not an http.Header.
+func Header(name string, headers interface{}) string {
+
+	var h http.Header
+	switch v := headers.(type) {
+	case http.Header:
+		h = v
+	case map[string][]string:
+		h = http.Header(v)
+	default:
+		return ""
+	}
+
+	return strings.Join(h.Values(name), ", ")
+}
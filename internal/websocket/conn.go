@@ -0,0 +1,457 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+// Supported data message types.
+const (
+	TextMessage   MessageType = MessageType(opText)
+	BinaryMessage MessageType = MessageType(opBinary)
+)
+
+// String returns the name of the message type.
+func (mt MessageType) String() string {
+	switch mt {
+	case TextMessage:
+		return "text"
+	case BinaryMessage:
+		return "binary"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(mt))
+	}
+}
+
+// Frame opcodes defined by RFC 6455.
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+)
+
+// Close status codes defined by RFC 6455.
+const (
+	CloseNormalClosure    int = 1000
+	CloseGoingAway        int = 1001
+	CloseProtocolError    int = 1002
+	CloseUnsupportedData  int = 1003
+	CloseNoStatusReceived int = 1005
+	CloseInvalidPayload   int = 1007
+	ClosePolicyViolation  int = 1008
+	CloseMessageTooBig    int = 1009
+	CloseInternalError    int = 1011
+)
+
+// DefaultReadLimit is the default maximum size in bytes of a message read
+// from the client.
+const DefaultReadLimit int64 = 1 << 20
+
+// maxControlPayload is the maximum payload size of control frames.
+const maxControlPayload int = 125
+
+// readBufferSize is the minimum size of the buffer used to read frames.
+const readBufferSize int = 4096
+
+// writeTimeout is the amount of time allowed to write a frame.
+const writeTimeout time.Duration = 10 * time.Second
+
+// ErrCloseSent is returned when attempting to write a message after a close
+// frame was sent.
+var ErrCloseSent = errors.New("websocket: close frame already sent")
+
+// CloseError is returned by ReadMessage once a close frame is received from
+// the client or the connection is closed due to a protocol violation.
+type CloseError struct {
+
+	// Code is the close status code. CloseNoStatusReceived is used if the
+	// client did not provide a status code.
+	Code int
+
+	// Reason is the optional close reason.
+	Reason string
+}
+
+// Error describes the close status.
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: connection closed (%d)", e.Code)
+	}
+
+	return fmt.Sprintf("websocket: connection closed (%d): %s", e.Code, e.Reason)
+}
+
+// Conn is a server-side WebSocket connection. Messages may be written
+// concurrently with reading, but ReadMessage must not be called
+// concurrently.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// writeMu serializes frames written to the connection.
+	writeMu   sync.Mutex
+	closeSent bool
+
+	readLimit   int64
+	pongHandler func(appData []byte)
+
+	framesReceived atomic.Int64
+	framesSent     atomic.Int64
+}
+
+// newConn creates a Conn for the given connection which has completed the
+// opening handshake.
+func newConn(conn net.Conn, br *bufio.Reader) *Conn {
+	return &Conn{
+		conn:      conn,
+		br:        br,
+		readLimit: DefaultReadLimit,
+	}
+}
+
+// SetReadLimit sets the maximum size in bytes of a message read from the
+// client. Larger messages cause the connection to be closed with
+// CloseMessageTooBig.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPongHandler sets a function called by ReadMessage for each pong frame
+// received from the client.
+func (c *Conn) SetPongHandler(h func(appData []byte)) {
+	c.pongHandler = h
+}
+
+// SetReadDeadline sets the deadline for reading from the connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// RemoteAddr returns the address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// FramesReceived returns the number of frames received from the client,
+// including control frames.
+func (c *Conn) FramesReceived() int64 {
+	return c.framesReceived.Load()
+}
+
+// FramesSent returns the number of frames sent to the client, including
+// control frames.
+func (c *Conn) FramesSent() int64 {
+	return c.framesSent.Load()
+}
+
+// Close closes the underlying connection without sending a close frame.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage reads the next data message from the client, reassembling
+// fragmented messages. Ping frames are answered and pong frames are passed
+// to the pong handler. Once a close frame is received, a close frame is sent
+// in reply (unless already sent) and a *CloseError is returned. Protocol
+// violations cause a close frame to be sent and a *CloseError describing the
+// violation to be returned.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+
+	var msgType MessageType
+	var msg []byte
+	var inMessage bool
+
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				_ = c.WriteClose(closeErr.Code, closeErr.Reason)
+			}
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload); err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			continue
+
+		case opPong:
+			if c.pongHandler != nil {
+				c.pongHandler(f.payload)
+			}
+			continue
+
+		case opClose:
+			closeErr := parseClosePayload(f.payload)
+			reply := closeErr
+			switch {
+			case closeErr.Code == CloseNoStatusReceived:
+				err = c.writeFrame(opClose, nil)
+			case !validCloseCode(closeErr.Code), !utf8.ValidString(closeErr.Reason):
+				reply = &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+				err = c.WriteClose(reply.Code, reply.Reason)
+			default:
+				err = c.WriteClose(closeErr.Code, "")
+			}
+			if err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			return 0, nil, closeErr
+
+		case opText, opBinary:
+			if inMessage {
+				return 0, nil, c.fail(CloseProtocolError, "new message started before the previous message was complete")
+			}
+			inMessage = true
+			msgType = MessageType(f.opcode)
+			msg = f.payload
+
+		case opContinuation:
+			if !inMessage {
+				return 0, nil, c.fail(CloseProtocolError, "continuation frame received without a message in progress")
+			}
+			if int64(len(msg))+int64(len(f.payload)) > c.readLimit {
+				return 0, nil, c.fail(CloseMessageTooBig, "message too large")
+			}
+			msg = append(msg, f.payload...)
+
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+		}
+
+		if !f.fin {
+			continue
+		}
+
+		if msgType == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, c.fail(CloseInvalidPayload, "text message is not valid UTF-8")
+		}
+
+		return msgType, msg, nil
+	}
+}
+
+// fail sends a close frame with the given code and reason and returns a
+// *CloseError describing the failure.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.WriteClose(code, reason)
+
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// frame is a single frame read from the client.
+type frame struct {
+	payload []byte
+	opcode  byte
+	fin     bool
+}
+
+// readFrame reads and unmasks the next frame from the client.
+func (c *Conn) readFrame() (frame, error) {
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		fin:    header[0]&0x80 != 0,
+		opcode: header[0] & 0x0F,
+	}
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+
+	switch {
+	case header[0]&0x70 != 0:
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set without a negotiated extension"}
+	case !masked:
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "frames from the client must be masked"}
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		if ext[0]&0x80 != 0 {
+			return frame{}, &CloseError{Code: CloseProtocolError, Reason: "invalid payload length"}
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if f.opcode >= opClose && (!f.fin || length > int64(maxControlPayload)) {
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "control frames must not be fragmented or exceed 125 bytes"}
+	}
+
+	if length > c.readLimit {
+		return frame{}, &CloseError{Code: CloseMessageTooBig, Reason: "message too large"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return frame{}, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return frame{}, err
+	}
+
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+
+	c.framesReceived.Add(1)
+
+	return f, nil
+}
+
+// WriteMessage sends a data message to the client as a single frame.
+func (c *Conn) WriteMessage(mt MessageType, data []byte) error {
+	if mt != TextMessage && mt != BinaryMessage {
+		return fmt.Errorf("websocket: unsupported message type %d", int(mt))
+	}
+
+	return c.writeFrame(byte(mt), data)
+}
+
+// Ping sends a ping frame with the given application data to the client.
+func (c *Conn) Ping(appData []byte) error {
+	if len(appData) > maxControlPayload {
+		return fmt.Errorf("websocket: ping application data exceeds %d bytes", maxControlPayload)
+	}
+
+	return c.writeFrame(opPing, appData)
+}
+
+// WriteClose sends a close frame with the given status code and reason to
+// the client. Only one close frame is sent; ErrCloseSent is returned for
+// further attempts. Callers should continue reading until ReadMessage
+// returns the client's reply (or a read deadline expires) before closing
+// the connection.
+func (c *Conn) WriteClose(code int, reason string) error {
+	// Reasons are truncated to fit in a control frame. The code takes up two
+	// bytes.
+	reason = truncateUTF8(reason, maxControlPayload-2)
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	return c.writeFrame(opClose, payload)
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a multi-byte
+// UTF-8 encoded character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+// writeFrame writes a single unmasked frame to the client.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	header := make([]byte, 0, 10)
+	header = append(header, 0x80|opcode)
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	buffers := net.Buffers{header, payload}
+	if _, err := buffers.WriteTo(c.conn); err != nil {
+		return err
+	}
+
+	c.framesSent.Add(1)
+
+	return nil
+}
+
+// parseClosePayload parses the status code and reason from the payload of a
+// close frame.
+func parseClosePayload(payload []byte) *CloseError {
+	switch len(payload) {
+	case 0:
+		return &CloseError{Code: CloseNoStatusReceived}
+	case 1:
+		// A lone byte cannot be a valid status code.
+		return &CloseError{Code: 0}
+	default:
+		return &CloseError{
+			Code:   int(binary.BigEndian.Uint16(payload)),
+			Reason: string(payload[2:]),
+		}
+	}
+}
+
+// validCloseCode indicates whether the given status code may be sent in a
+// close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// clientFrame encodes a frame as sent by a client. The payload is masked
+// unless masked is false.
+func clientFrame(first byte, payload []byte, masked bool) []byte {
+
+	frame := []byte{first}
+
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	if !masked {
+		return append(frame, payload...)
+	}
+
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+func TestReadFrame(t *testing.T) {
+
+	large := bytes.Repeat([]byte("a"), 300)
+
+	tests := []struct {
+		name        string
+		input       []byte
+		readLimit   int64
+		wantOpcode  byte
+		wantFin     bool
+		wantPayload []byte
+		wantCode    int
+		wantErr     error
+	}{
+		{
+			name:        "masked text frame",
+			input:       clientFrame(0x80|opText, []byte("hello"), true),
+			wantOpcode:  opText,
+			wantFin:     true,
+			wantPayload: []byte("hello"),
+		},
+		{
+			name:        "fragment without fin",
+			input:       clientFrame(opBinary, []byte{0x00, 0xFF}, true),
+			wantOpcode:  opBinary,
+			wantFin:     false,
+			wantPayload: []byte{0x00, 0xFF},
+		},
+		{
+			name:        "16-bit extended payload length",
+			input:       clientFrame(0x80|opBinary, large, true),
+			wantOpcode:  opBinary,
+			wantFin:     true,
+			wantPayload: large,
+		},
+		{
+			name:        "empty ping",
+			input:       clientFrame(0x80|opPing, nil, true),
+			wantOpcode:  opPing,
+			wantFin:     true,
+			wantPayload: []byte{},
+		},
+		{
+			name:     "unmasked frame",
+			input:    clientFrame(0x80|opText, []byte("hello"), false),
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:     "reserved bits set",
+			input:    clientFrame(0x80|0x40|opText, []byte("hello"), true),
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:     "fragmented control frame",
+			input:    clientFrame(opPing, []byte("ping"), true),
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:     "oversized control frame",
+			input:    clientFrame(0x80|opPing, bytes.Repeat([]byte("p"), maxControlPayload+1), true),
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:     "64-bit payload length with most significant bit set",
+			input:    []byte{0x80 | opBinary, 0x80 | 127, 0x80, 0, 0, 0, 0, 0, 0, 1},
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:      "payload exceeds read limit",
+			input:     clientFrame(0x80|opBinary, large, true),
+			readLimit: 100,
+			wantCode:  CloseMessageTooBig,
+		},
+		{
+			name:    "truncated header",
+			input:   []byte{0x80 | opText},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated payload",
+			input:   clientFrame(0x80|opText, []byte("hello"), true)[:8],
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "no data",
+			input:   nil,
+			wantErr: io.EOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			c := newConn(nil, bufio.NewReader(bytes.NewReader(tt.input)))
+			if tt.readLimit > 0 {
+				c.SetReadLimit(tt.readLimit)
+			}
+
+			f, err := c.readFrame()
+
+			switch {
+			case tt.wantCode != 0:
+				var closeErr *CloseError
+				if !errors.As(err, &closeErr) {
+					t.Fatalf("readFrame() error = %v, want *CloseError", err)
+				}
+				if closeErr.Code != tt.wantCode {
+					t.Errorf("CloseError.Code = %d, want %d", closeErr.Code, tt.wantCode)
+				}
+				return
+
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("readFrame() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+
+			case err != nil:
+				t.Fatalf("readFrame() error = %v", err)
+			}
+
+			if f.opcode != tt.wantOpcode {
+				t.Errorf("opcode = %#x, want %#x", f.opcode, tt.wantOpcode)
+			}
+			if f.fin != tt.wantFin {
+				t.Errorf("fin = %t, want %t", f.fin, tt.wantFin)
+			}
+			if !bytes.Equal(f.payload, tt.wantPayload) {
+				t.Errorf("payload = %q, want %q", f.payload, tt.wantPayload)
+			}
+			if got := c.FramesReceived(); got != 1 {
+				t.Errorf("FramesReceived() = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestWriteCloseTruncatesReason(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		reason     string
+		wantReason string
+	}{
+		{
+			name:       "short reason",
+			reason:     "bye",
+			wantReason: "bye",
+		},
+		{
+			name:       "ASCII reason",
+			reason:     strings.Repeat("a", 200),
+			wantReason: strings.Repeat("a", maxControlPayload-2),
+		},
+		// 62 two-byte characters fill 124 bytes, one more than fits
+		// alongside the status code.
+		{
+			name:       "multi-byte reason",
+			reason:     strings.Repeat("é", 62),
+			wantReason: strings.Repeat("é", 61),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			server, client := net.Pipe()
+			defer func() { _ = client.Close() }()
+
+			c := newConn(server, nil)
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- c.WriteClose(CloseGoingAway, tt.reason)
+				_ = server.Close()
+			}()
+
+			frame, err := io.ReadAll(client)
+			if err != nil {
+				t.Fatalf("failed to read close frame: %v", err)
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("WriteClose() error = %v", err)
+			}
+
+			if len(frame) < 4 || frame[0] != 0x80|opClose || int(frame[1]) != len(frame)-2 {
+				t.Fatalf("malformed close frame % x", frame)
+			}
+
+			payload := frame[2:]
+			if code := int(binary.BigEndian.Uint16(payload)); code != CloseGoingAway {
+				t.Errorf("code = %d, want %d", code, CloseGoingAway)
+			}
+
+			reason := string(payload[2:])
+			if !utf8.ValidString(reason) {
+				t.Errorf("reason %q is not valid UTF-8", reason)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+
+			if err := c.WriteClose(CloseNormalClosure, ""); !errors.Is(err, ErrCloseSent) {
+				t.Errorf("second WriteClose() error = %v, want %v", err, ErrCloseSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package websocket provides a minimal server-side implementation of the
+WebSocket protocol (RFC 6455) sufficient for echoing messages back to
+clients.
+
+Requests are upgraded to WebSocket connections using Upgrade. Fragmented
+messages are reassembled and ping and close frames received from the client
+are answered automatically when reading messages. Extensions (e.g.,
+permessage-deflate) and subprotocols are not negotiated, and WebSocket over
+HTTP/2 (RFC 8441) is not supported.
+*/
+package websocket
@@ -0,0 +1,164 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by RFC 6455
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// supportedVersion is the only version of the WebSocket protocol supported
+// by this package.
+const supportedVersion string = "13"
+
+// acceptGUID is appended to the key provided by the client in order to
+// calculate the Sec-WebSocket-Accept response header value.
+const acceptGUID string = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// keyLength is the length in bytes of the decoded Sec-WebSocket-Key value.
+const keyLength int = 16
+
+// HandshakeError is returned by Upgrade if the request is not a valid
+// WebSocket opening handshake. Status is the HTTP status code which should
+// be returned to the client. Any headers needed by the client (e.g.,
+// Sec-WebSocket-Version) have already been set on the response.
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+// Error returns the reason the handshake failed.
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// IsUpgradeRequest indicates whether the client requested an upgrade to the
+// WebSocket protocol.
+func IsUpgradeRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the WebSocket opening handshake and takes over the
+// underlying connection. Headers already set on the response (e.g., a
+// request ID) are included with the handshake response. Any read or write
+// deadlines set on the connection by the http server are cleared. The
+// request is not modified if a *HandshakeError is returned; the caller is
+// responsible for responding to the client.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+
+	switch {
+	case r.ProtoMajor != 1 || !r.ProtoAtLeast(1, 1):
+		return nil, &HandshakeError{
+			Status:  http.StatusHTTPVersionNotSupported,
+			Message: fmt.Sprintf("WebSocket connections require HTTP/1.1, %s was used", r.Proto),
+		}
+
+	case r.Method != http.MethodGet:
+		return nil, &HandshakeError{
+			Status:  http.StatusMethodNotAllowed,
+			Message: "WebSocket connections require the GET method",
+		}
+
+	case !IsUpgradeRequest(r):
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "websocket")
+		return nil, &HandshakeError{
+			Status:  http.StatusUpgradeRequired,
+			Message: "WebSocket connections require the Connection: Upgrade and Upgrade: websocket headers",
+		}
+
+	case r.Header.Get("Sec-WebSocket-Version") != supportedVersion:
+		w.Header().Set("Sec-WebSocket-Version", supportedVersion)
+		return nil, &HandshakeError{
+			Status:  http.StatusUpgradeRequired,
+			Message: fmt.Sprintf("unsupported WebSocket version %q", r.Header.Get("Sec-WebSocket-Version")),
+		}
+	}
+
+	key := strings.TrimSpace(r.Header.Get("Sec-WebSocket-Key"))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != keyLength {
+		return nil, &HandshakeError{
+			Status:  http.StatusBadRequest,
+			Message: "missing or invalid Sec-WebSocket-Key header",
+		}
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: failed to take over connection: %w", err)
+	}
+
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("websocket: failed to clear connection deadlines: %w", err)
+	}
+
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	resp.WriteString("Upgrade: websocket\r\n")
+	resp.WriteString("Connection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	for name, values := range w.Header() {
+		switch http.CanonicalHeaderKey(name) {
+		case "Upgrade", "Connection", "Sec-Websocket-Accept", "Content-Type", "Content-Length", "Vary":
+			continue
+		}
+		for _, value := range values {
+			resp.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	resp.WriteString("\r\n")
+
+	if err := netConn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("websocket: failed to set write deadline: %w", err)
+	}
+
+	if _, err := netConn.Write([]byte(resp.String())); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("websocket: failed to write handshake response: %w", err)
+	}
+
+	// Data sent by the client immediately after the handshake may already
+	// be buffered.
+	br := brw.Reader
+	if br.Size() < readBufferSize {
+		br = bufio.NewReaderSize(br, readBufferSize)
+	}
+
+	return newConn(netConn, br), nil
+}
+
+// acceptKey calculates the Sec-WebSocket-Accept value for the given
+// Sec-WebSocket-Key value.
+func acceptKey(key string) string {
+	h := sha1.New() //nolint:gosec // required by RFC 6455
+	h.Write([]byte(key + acceptGUID))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken indicates whether the comma-separated values of the
+// named header include the given token (case-insensitive).
+func headerContainsToken(h http.Header, name string, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
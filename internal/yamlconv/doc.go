@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package yamlconv converts JSON documents to YAML. The order of object keys is
+preserved, multi-line strings are emitted as literal block scalars and other
+strings are quoted where needed so that they are not interpreted as another
+type (e.g., "true" or "1.0").
+
+This package is intended for presenting JSON encoded values as YAML; it is
+not a general purpose YAML encoder.
+*/
+package yamlconv
@@ -0,0 +1,300 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bounce
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package yamlconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// indentWidth is the number of spaces used for each level of indentation.
+const indentWidth int = 2
+
+// plainScalar matches strings which may be emitted without quotes.
+var plainScalar = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./ -]*[A-Za-z0-9_./-]$|^[A-Za-z_/]$`)
+
+// reservedScalars are plain strings which YAML parsers interpret as a type
+// other than string.
+var reservedScalars = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true,
+	"off": true, "null": true, "y": true, "n": true, "~": true,
+}
+
+// node is a decoded JSON value. Objects retain the original key order.
+type node struct {
+	value  interface{}
+	keys   []string
+	fields map[string]*node
+	items  []*node
+	kind   kind
+}
+
+// kind is the type of a decoded JSON value.
+type kind int
+
+const (
+	kindScalar kind = iota
+	kindObject
+	kindArray
+)
+
+// FromJSON converts the given JSON document to YAML.
+func FromJSON(data []byte) ([]byte, error) {
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	root, err := decode(dec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to decode JSON: unexpected data after top-level value")
+	}
+
+	var buf bytes.Buffer
+	switch root.kind {
+	case kindScalar:
+		buf.WriteString(scalar(root.value))
+		buf.WriteByte('\n')
+	default:
+		if root.empty() {
+			buf.WriteString(root.emptyValue())
+			buf.WriteByte('\n')
+			break
+		}
+		emit(&buf, root, 0)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decode reads the next JSON value from the decoder.
+func decode(dec *json.Decoder) (*node, error) {
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			n := node{kind: kindObject, fields: make(map[string]*node)}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyTok.(string)
+				if !ok {
+					return nil, fmt.Errorf("unexpected object key %v", keyTok)
+				}
+				child, err := decode(dec)
+				if err != nil {
+					return nil, err
+				}
+				if _, exists := n.fields[key]; !exists {
+					n.keys = append(n.keys, key)
+				}
+				n.fields[key] = child
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &n, nil
+
+		case '[':
+			n := node{kind: kindArray}
+			for dec.More() {
+				child, err := decode(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, child)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &n, nil
+
+		default:
+			return nil, fmt.Errorf("unexpected delimiter %v", t)
+		}
+
+	default:
+		return &node{kind: kindScalar, value: t}, nil
+	}
+}
+
+// empty indicates whether the node is an empty object or array.
+func (n *node) empty() bool {
+	return (n.kind == kindObject && len(n.keys) == 0) ||
+		(n.kind == kindArray && len(n.items) == 0)
+}
+
+// emptyValue returns the flow style representation of an empty object or
+// array.
+func (n *node) emptyValue() string {
+	if n.kind == kindObject {
+		return "{}"
+	}
+
+	return "[]"
+}
+
+// emit writes the given object or array node at the given indentation level.
+func emit(buf *bytes.Buffer, n *node, level int) {
+
+	indent := strings.Repeat(" ", level*indentWidth)
+
+	switch n.kind {
+	case kindObject:
+		for _, key := range n.keys {
+			buf.WriteString(indent)
+			buf.WriteString(scalar(key))
+			buf.WriteByte(':')
+			emitValue(buf, n.fields[key], level)
+		}
+
+	case kindArray:
+		for _, item := range n.items {
+			if item.kind == kindScalar || item.empty() {
+				buf.WriteString(indent)
+				buf.WriteByte('-')
+				emitValue(buf, item, level)
+				continue
+			}
+
+			// Nested collections start on the same line as the sequence
+			// indicator, which takes the place of the first indentation
+			// level of the nested collection.
+			var nested bytes.Buffer
+			emit(&nested, item, level+1)
+			buf.WriteString(indent)
+			buf.WriteString("- ")
+			buf.Write(nested.Bytes()[len(indent)+indentWidth:])
+		}
+	}
+}
+
+// emitValue writes the value following a mapping key or sequence indicator.
+func emitValue(buf *bytes.Buffer, n *node, level int) {
+
+	switch {
+	case n.kind != kindScalar && n.empty():
+		buf.WriteByte(' ')
+		buf.WriteString(n.emptyValue())
+		buf.WriteByte('\n')
+
+	case n.kind != kindScalar:
+		buf.WriteByte('\n')
+		emit(buf, n, level+1)
+
+	default:
+		s, isString := n.value.(string)
+		if isString && blockScalarAllowed(s) {
+			emitBlockScalar(buf, s, level+1)
+			return
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(scalar(n.value))
+		buf.WriteByte('\n')
+	}
+}
+
+// blockScalarAllowed indicates whether the given string can be represented
+// as a literal block scalar.
+func blockScalarAllowed(s string) bool {
+	if !strings.Contains(s, "\n") || strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") {
+		return false
+	}
+
+	for _, r := range s {
+		if r == '\t' || r == '\n' {
+			continue
+		}
+		if r < 0x20 || r == 0x7f || r == '\uFEFF' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// emitBlockScalar writes the given multi-line string as a literal block
+// scalar at the given indentation level.
+func emitBlockScalar(buf *bytes.Buffer, s string, level int) {
+
+	chomping := "-"
+	trimmed := strings.TrimRight(s, "\n")
+	switch len(s) - len(trimmed) {
+	case 0:
+	case 1:
+		chomping = ""
+	default:
+		chomping = "+"
+	}
+
+	buf.WriteString(" |")
+	buf.WriteString(chomping)
+	buf.WriteByte('\n')
+
+	indent := strings.Repeat(" ", level*indentWidth)
+	lines := strings.Split(s, "\n")
+	if chomping != "+" {
+		lines = strings.Split(trimmed, "\n")
+	} else {
+		lines = lines[:len(lines)-1]
+	}
+
+	for _, line := range lines {
+		if line != "" {
+			buf.WriteString(indent)
+			buf.WriteString(line)
+		}
+		buf.WriteByte('\n')
+	}
+}
+
+// scalar returns the YAML representation of the given scalar value.
+func scalar(v interface{}) string {
+
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return t.String()
+	case string:
+		if plainScalar.MatchString(t) && !reservedScalars[strings.ToLower(t)] {
+			return t
+		}
+		// JSON string escapes are valid within YAML double-quoted scalars.
+		// HTML escaping is not needed and would only hinder readability.
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(t); err != nil {
+			return `""`
+		}
+		return strings.TrimSuffix(buf.String(), "\n")
+	default:
+		return fmt.Sprint(t)
+	}
+}